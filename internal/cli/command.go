@@ -4,10 +4,16 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 )
 
 // Command describes a command that can be run using the methods in the
@@ -15,8 +21,34 @@ import (
 type Command struct {
 	Program []string
 	WorkDir string
+	//Env contains environment variables (as "KEY=value") that are set for the
+	//command in addition to those of the current process.
+	Env []string
+	//If not zero, the command is interrupted when it takes longer than this.
+	Timeout time.Duration
+	//If not nil, the command is interrupted when this context is canceled.
+	Context context.Context
+	//ReadOnly declares that the command does not change anything outside of
+	//temporary directories. Such commands are executed even in dry-run mode
+	//(see DryRunCommandRunner).
+	ReadOnly bool
 }
 
+// ErrTimeout is returned (wrapped in a command error) by DefaultCommandRunner
+// when a command exceeds its Timeout.
+var ErrTimeout = errors.New("timed out")
+
+// ErrInterrupted is returned (wrapped in a command error) by the methods of
+// Implementation that run commands, when the Implementation is interrupted
+// (see Implementation.HandleInterrupts) while the command is running. After
+// that, all further commands fail with this error without being started, so
+// that loops over many commands end quickly.
+var ErrInterrupted = errors.New("interrupted")
+
+// When a command is interrupted, it gets this much time to clean up after
+// itself before it is killed.
+const killDelay = 5 * time.Second
+
 type commandError struct {
 	Cmd Command
 	Err error
@@ -34,22 +66,148 @@ func (e commandError) Error() string {
 	)
 }
 
+func (e commandError) Unwrap() error {
+	return e.Err
+}
+
 // CommandRunner is a function that can execute commands given to it.
 // This interface is only useful for unit tests; the default CommandRunner
 // suffices for all regular operation.
 type CommandRunner func(c Command, stdin io.Reader, stdout, stderr io.Writer) error
 
 // DefaultCommandRunner is a CommandRunner that actually executes the command.
+// If $GOFU_TRACE is set, each command is logged (see TraceEnvVar).
+//
+// When the command is interrupted (because of its Timeout or its Context), it
+// receives SIGINT first, so that it can clean up after itself (e.g. `git
+// clone` removes a partial checkout). It is only killed if it does not exit
+// within a few seconds after that.
 func DefaultCommandRunner(c Command, stdin io.Reader, stdout, stderr io.Writer) error {
-	cmd := exec.Command(c.Program[0], c.Program[1:]...)
+	var tail *tailBuffer
+	mode := traceMode()
+	if mode != "" {
+		tail = &tailBuffer{}
+		if stderr == nil {
+			stderr = tail
+		} else {
+			stderr = io.MultiWriter(stderr, tail)
+		}
+	}
+
+	ctx := c.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, c.Timeout, ErrTimeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, c.Program[0], c.Program[1:]...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = killDelay
 	cmd.Stdin = stdin
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
 	cmd.Dir = c.WorkDir
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
 
+	startedAt := time.Now()
 	err := cmd.Run()
+	if err != nil && ctx.Err() != nil {
+		//report why the command was interrupted (ErrTimeout or the cause from
+		//c.Context, e.g. ErrInterrupted) instead of the resulting exit status
+		err = context.Cause(ctx)
+	}
+	if tail != nil {
+		writeTrace(mode, c, startedAt, time.Since(startedAt), err, tail)
+	}
 	if err != nil {
 		err = commandError{c, err}
 	}
 	return err
 }
+
+// HandleInterrupts makes SIGINT and SIGTERM interrupt the commands that are
+// running through this Implementation, instead of terminating us right away,
+// so that the caller can clean up after the commands (if the signal came from
+// the terminal, the commands have received it as well, since they are in the
+// same process group). If no command is running, or once the first signal has
+// been handled, signals terminate us as usual. The returned function stops
+// the signal handling.
+func (i *Implementation) HandleInterrupts() (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			signal.Stop(signals)
+			i.Interrupt()
+			if i.runningCommands.Load() == 0 {
+				//nothing to clean up after, so take the default action
+				if sysSig, ok := sig.(syscall.Signal); ok {
+					syscall.Kill(os.Getpid(), sysSig)
+				}
+			}
+		case <-done:
+			signal.Stop(signals)
+		}
+	}()
+	return func() { close(done) }
+}
+
+// Interrupt cancels all commands that are running through this
+// Implementation with ErrInterrupted. All further commands fail with
+// ErrInterrupted without being started.
+func (i *Implementation) Interrupt() {
+	i.interrupt(ErrInterrupted)
+}
+
+// Interrupted returns whether Interrupt() has been called (usually because we
+// received SIGINT or SIGTERM, see HandleInterrupts).
+func (i *Implementation) Interrupted() bool {
+	return i.interruptCtx.Err() != nil
+}
+
+// runCommand is used by all methods that run commands. It executes the
+// command using the CommandRunner, unless the Implementation has been
+// interrupted before.
+func (i *Implementation) runCommand(c Command, stdin io.Reader, stdout, stderr io.Writer) error {
+	if i.Interrupted() {
+		return commandError{c, ErrInterrupted}
+	}
+
+	ctx := c.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	stopAfter := context.AfterFunc(i.interruptCtx, func() { cancel(ErrInterrupted) })
+	defer stopAfter()
+	c.Context = ctx
+
+	i.runningCommands.Add(1)
+	defer i.runningCommands.Add(-1)
+	return i.commandRunner(c, stdin, stdout, stderr)
+}
+
+// DryRunCommandRunner returns a CommandRunner that only executes commands
+// marked as ReadOnly (using the given CommandRunner). All other commands are
+// given to the report callback instead, and appear to have succeeded without
+// producing any output.
+func DryRunCommandRunner(next CommandRunner, report func(c Command)) CommandRunner {
+	return func(c Command, stdin io.Reader, stdout, stderr io.Writer) error {
+		if c.ReadOnly {
+			return next(c, stdin, stdout, stderr)
+		}
+		report(c)
+		return nil
+	}
+}
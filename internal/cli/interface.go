@@ -6,11 +6,13 @@ package cli
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"sort"
 	"strings"
+	"sync/atomic"
 
 	"golang.org/x/term"
 )
@@ -25,19 +27,27 @@ func init() {
 // SetupInterface prepares the Interface instance with nonstandard file streams
 // or a nonstandard CommandRunner. This is only required for unit tests.
 func SetupInterface(stdin io.Reader, stdout, stderr io.Writer, commandRunner CommandRunner) {
-	Interface = &Implementation{
+	Interface = NewImplementation(stdin, stdout, stderr, commandRunner)
+}
+
+// NewImplementation creates an Implementation that is independent from the
+// global Interface instance, e.g. for using an applet as a library.
+func NewImplementation(stdin io.Reader, stdout, stderr io.Writer, commandRunner CommandRunner) *Implementation {
+	i := &Implementation{
 		stdin:         stdin,
 		stdout:        stdout,
 		stderr:        stderr,
 		stdinBuf:      bufio.NewReader(stdin),
 		commandRunner: commandRunner,
 	}
+	i.interruptCtx, i.interrupt = context.WithCancelCause(context.Background())
 
 	if stdinFile, ok := stdin.(*os.File); ok && term.IsTerminal(int(stdinFile.Fd())) {
-		Interface.tui = &terminalTUI{Interface}
+		i.tui = &terminalTUI{i}
 	} else {
-		Interface.tui = &pipeTUI{Interface}
+		i.tui = &pipeTUI{i}
 	}
+	return i
 }
 
 // Implementation wraps access to the CLI, including input, output and subprocesses.
@@ -48,6 +58,11 @@ type Implementation struct {
 	stdinBuf      *bufio.Reader
 	tui           TUI
 	commandRunner CommandRunner
+	//interruptCtx is canceled by Interrupt(), and runningCommands is used by
+	//HandleInterrupts() (see there).
+	interruptCtx    context.Context
+	interrupt       context.CancelCauseFunc
+	runningCommands atomic.Int64
 	//If this flag is set, only ShowResult() will write into stdout; everything
 	//else that usually goes to stdout goes to stderr instead.
 	//
@@ -67,6 +82,9 @@ type TUI interface {
 	//Query displays a question and a set of answers and allows the user to select
 	//one of the answers. Returns the Return attribute of the selected Choice.
 	Query(prompt string, choices ...Choice) (string, error)
+	//MultiQuery is like Query, but allows the user to select any number of
+	//choices. Returns the Return attributes of all selected Choices.
+	MultiQuery(prompt string, choices ...Choice) ([]string, error)
 	//Print writes the given string (potentially including ANSI escape codes) to
 	//the given writer. At this point, it can be decided whether to strip out the
 	//ANSI escape codes.
@@ -99,21 +117,46 @@ func (i *Implementation) Query(prompt string, choices ...Choice) (string, error)
 	return i.tui.Query(prompt, choices...)
 }
 
+// MultiQuery is like Query, but allows the user to select any number of
+// choices. Returns the Return attributes of all selected Choices.
+func (i *Implementation) MultiQuery(prompt string, choices ...Choice) ([]string, error) {
+	return i.tui.MultiQuery(prompt, choices...)
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // subprocesses
 
+// WrapCommandRunner replaces the CommandRunner of this Implementation with
+// the result of the given function, which receives the previous one.
+func (i *Implementation) WrapCommandRunner(wrap func(CommandRunner) CommandRunner) {
+	i.commandRunner = wrap(i.commandRunner)
+}
+
 // Run executes the given command on the same stdout and stderr.
 func (i *Implementation) Run(c Command) error {
-	return i.commandRunner(c, nil, i.safeStdout(), i.stderr)
+	return i.runCommand(c, nil, i.safeStdout(), i.stderr)
+}
+
+// RunWithInput is like Run, but supplies the given string on the command's stdin.
+func (i *Implementation) RunWithInput(c Command, input string) error {
+	return i.runCommand(c, strings.NewReader(input), i.safeStdout(), i.stderr)
 }
 
 // CaptureStdout executes the given command on the same stderr and captures its stdout.
 func (i *Implementation) CaptureStdout(c Command) (string, error) {
 	var buf bytes.Buffer
-	err := i.commandRunner(c, nil, &buf, i.stderr)
+	err := i.runCommand(c, nil, &buf, i.stderr)
 	return buf.String(), err
 }
 
+// CaptureOutput executes the given command and captures both its stdout and
+// its stderr.
+func (i *Implementation) CaptureOutput(c Command) (stdout, stderr string, err error) {
+	var outBuf, errBuf bytes.Buffer
+	err = i.runCommand(c, nil, &outBuf, &errBuf)
+	return outBuf.String(), errBuf.String(), err
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // output
 
@@ -69,6 +69,8 @@ type Choice struct {
 	Text string
 	//The string to return from Implementation.Query().
 	Return string
+	//Only for MultiQuery(): whether this choice is selected initially.
+	Selected bool
 }
 
 func (c Choice) hasShortcut() bool {
@@ -138,6 +140,78 @@ OUTER:
 	return choices[selected].Return, nil
 }
 
+func (t terminalTUI) MultiQuery(prompt string, choices ...Choice) ([]string, error) {
+	if len(choices) == 0 {
+		panic("no choices")
+	}
+
+	//disable line wrap; unexpected wrapping would confuse our cursor-moving code
+	out := t.i.safeStdout()
+	out.Write([]byte("\x1B[?7l"))
+	defer out.Write([]byte("\x1B[?7h"))
+
+	//display question
+	out.Write([]byte(strings.TrimSuffix(prompt, "\n") + " (Space to select, Enter to confirm)\n"))
+	cursor := 0
+	selected := make([]bool, len(choices))
+	for idx, choice := range choices {
+		selected[idx] = choice.Selected
+	}
+
+	buf := buffer{Input: t.i.stdin}
+OUTER:
+	for {
+		displayMultiChoices(out, choices, selected, cursor)
+
+		switch string(buf.getNextInput()) {
+		case "\r", "\n":
+			break OUTER
+		case " ":
+			selected[cursor] = !selected[cursor]
+		case "a": // toggle all
+			allSelected := true
+			for _, s := range selected {
+				allSelected = allSelected && s
+			}
+			for idx := range selected {
+				selected[idx] = !allSelected
+			}
+		case "\x1B[A": // Up arrow key
+			cursor--
+			if cursor < 0 {
+				cursor = 0
+			}
+		case "\x1B[B": // Down arrow key
+			cursor++
+			if cursor >= len(choices) {
+				cursor = len(choices) - 1
+			}
+		case "\x03": // Ctrl-C
+			return nil, errInterrupted{}
+		}
+
+		//prepare to re-render choices
+		removeDisplayLines(out, len(choices))
+	}
+
+	//clear query display
+	removeDisplayLines(out, len(choices)+1)
+
+	//display question + chosen answers
+	var result, texts []string
+	for idx, choice := range choices {
+		if selected[idx] {
+			result = append(result, choice.Return)
+			texts = append(texts, strings.TrimSpace(choice.Text))
+		}
+	}
+	fmt.Fprintf(out, "%s -> %d selected\n", strings.TrimSuffix(prompt, "\n"), len(result))
+	for _, text := range texts {
+		fmt.Fprintf(out, "  %s\n", text)
+	}
+	return result, nil
+}
+
 func removeDisplayLines(stdout io.Writer, n int) {
 	for range n {
 		stdout.Write([]byte("\x1B[A\x1B[2K"))
@@ -171,6 +245,22 @@ func displayChoices(out io.Writer, choices []Choice, selectedIndex int) {
 	}
 }
 
+func displayMultiChoices(out io.Writer, choices []Choice, selected []bool, cursorIndex int) {
+	for idx, choice := range choices {
+		mark := ' '
+		if selected[idx] {
+			mark = 'x'
+		}
+		text := fmt.Sprintf(" [%c] %s \n", mark, strings.TrimSpace(choice.Text))
+
+		if idx == cursorIndex {
+			fmt.Fprintf(out, "\x1B[0;7m%s\x1B[0m", text)
+		} else {
+			out.Write([]byte(text))
+		}
+	}
+}
+
 var ansiEscapeRx = regexp.MustCompile(`^\x1B\[[\x20-\x3F]*[\x40-\x7E]`)
 
 type buffer struct {
@@ -278,3 +368,49 @@ func (t *pipeTUI) Query(prompt string, choices ...Choice) (string, error) {
 	fmt.Fprintf(t.i.stderr, "%s -> [%s]\n", prompt, str)
 	return "", errors.New("cannot match input with available choices")
 }
+
+// MultiQuery for the pipe TUI reads one line per selected choice (matched
+// like in Query), until an empty line or EOF is encountered. Choices that are
+// selected initially stay selected.
+func (t *pipeTUI) MultiQuery(prompt string, choices ...Choice) ([]string, error) {
+	selected := make([]bool, len(choices))
+	for idx, choice := range choices {
+		selected[idx] = choice.Selected
+	}
+
+	for {
+		str, err := t.i.stdinBuf.ReadString('\n')
+		str = strings.TrimSpace(str)
+		if str == "" {
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			break
+		}
+
+		found := false
+		for idx, choice := range choices {
+			if choice.Text == str || (choice.hasShortcut() && string(choice.Shortcut) == str) {
+				selected[idx] = true
+				found = true
+				fmt.Fprintf(t.i.stderr, "%s -> %s\n", prompt, choice.Text)
+				break
+			}
+		}
+		if !found {
+			fmt.Fprintf(t.i.stderr, "%s -> [%s]\n", prompt, str)
+			return nil, errors.New("cannot match input with available choices")
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	var result []string
+	for idx, choice := range choices {
+		if selected[idx] {
+			result = append(result, choice.Return)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,208 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceEnvVar is the environment variable that enables command tracing in
+// DefaultCommandRunner. If set to "stderr" (or "1"), each command is logged on
+// stderr after it exits. If set to "file", it is logged into the file at
+// TraceLogPath() instead, where `gofu trace last` can find it.
+const TraceEnvVar = "GOFU_TRACE"
+
+const (
+	//Only the end of each command's stderr is logged, since that is where
+	//the error messages usually are.
+	maxTracedStderr = 2048
+	//When the trace log grows beyond this size, it is moved to
+	//TraceLogPath()+".1" (replacing the previous one) and started afresh.
+	maxTraceLogSize = 1 << 20
+)
+
+// TraceEntry describes a single command in the trace log.
+type TraceEntry struct {
+	//Session identifies the gofu process that ran the command, and
+	//Invocation is that process's command line.
+	Session    string    `json:"session"`
+	Invocation []string  `json:"invocation"`
+	StartedAt  time.Time `json:"started_at"`
+	Program    []string  `json:"argv"`
+	WorkDir    string    `json:"workdir,omitempty"`
+	//Duration is in nanoseconds in the JSON.
+	Duration time.Duration `json:"duration"`
+	//ExitCode is -1 if the command could not be started or was killed.
+	ExitCode int `json:"exit_code"`
+	//Error is set if the command failed for reasons other than a non-zero
+	//exit code (e.g. if the program was not found or timed out).
+	Error           string `json:"error,omitempty"`
+	Stderr          string `json:"stderr,omitempty"`
+	StderrTruncated bool   `json:"stderr_truncated,omitempty"`
+}
+
+// String formats the entry for display on a terminal.
+func (e TraceEntry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %7.3fs exit %d: %s", e.StartedAt.Format("15:04:05"), e.Duration.Seconds(), e.ExitCode, strings.Join(e.Program, " "))
+	if e.WorkDir != "" {
+		fmt.Fprintf(&b, " (in %s)", e.WorkDir)
+	}
+	b.WriteString("\n")
+	if e.Error != "" {
+		fmt.Fprintf(&b, "  error: %s\n", e.Error)
+	}
+	stderr := strings.TrimRight(e.Stderr, "\n")
+	if e.StderrTruncated {
+		stderr = "[...]" + stderr
+	}
+	for line := range strings.SplitSeq(stderr, "\n") {
+		if line != "" {
+			//git prints progress with carriage returns; only the last state matters
+			line = line[strings.LastIndex(line, "\r")+1:]
+			fmt.Fprintf(&b, "  | %s\n", line)
+		}
+	}
+	return b.String()
+}
+
+// TraceLogPath returns the path of the trace log file, which is in
+// $XDG_STATE_HOME/gofu (or ~/.local/state/gofu if that is not set).
+func TraceLogPath() (string, error) {
+	stateDir := os.Getenv("XDG_STATE_HOME")
+	if stateDir == "" {
+		homeDir := os.Getenv("HOME")
+		if homeDir == "" {
+			return "", errors.New("neither $XDG_STATE_HOME nor $HOME is set")
+		}
+		stateDir = filepath.Join(homeDir, ".local/state")
+	}
+	return filepath.Join(stateDir, "gofu/trace.log"), nil
+}
+
+var (
+	traceSession = fmt.Sprintf("%d-%d", time.Now().UnixNano(), os.Getpid())
+	//traceMutex serializes writing of trace entries, since commands may be
+	//run concurrently.
+	traceMutex sync.Mutex
+	//traceFailed ensures that problems with the trace log are only reported once.
+	traceFailed sync.Once
+)
+
+// traceMode returns the value of $GOFU_TRACE in normalized form: "stderr",
+// "file", or "" if tracing is disabled.
+func traceMode() string {
+	switch os.Getenv(TraceEnvVar) {
+	case "", "0":
+		return ""
+	case "file":
+		return "file"
+	default:
+		return "stderr"
+	}
+}
+
+// tailBuffer is an io.Writer that keeps only the last bytes written into it.
+type tailBuffer struct {
+	buf       []byte
+	truncated bool
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if excess := len(t.buf) - maxTracedStderr; excess > 0 {
+		t.buf = t.buf[excess:]
+		t.truncated = true
+	}
+	return len(p), nil
+}
+
+// writeTrace logs a command that was run by DefaultCommandRunner.
+func writeTrace(mode string, c Command, startedAt time.Time, duration time.Duration, err error, stderr *tailBuffer) {
+	e := TraceEntry{
+		Session:         traceSession,
+		Invocation:      os.Args,
+		StartedAt:       startedAt,
+		Program:         c.Program,
+		WorkDir:         c.WorkDir,
+		Duration:        duration,
+		Stderr:          string(stderr.buf),
+		StderrTruncated: stderr.truncated,
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		e.ExitCode = 0
+	case errors.As(err, &exitErr):
+		e.ExitCode = exitErr.ExitCode()
+		if e.ExitCode == -1 {
+			e.Error = exitErr.Error() //killed by a signal
+		}
+	default:
+		e.ExitCode = -1
+		e.Error = err.Error()
+	}
+
+	traceMutex.Lock()
+	defer traceMutex.Unlock()
+	if mode == "stderr" {
+		os.Stderr.Write([]byte("[trace] " + e.String()))
+		return
+	}
+	err = appendTraceEntry(e)
+	if err != nil {
+		traceFailed.Do(func() {
+			fmt.Fprintf(os.Stderr, "cannot write trace log: %s\n", err.Error())
+		})
+	}
+}
+
+func appendTraceEntry(e TraceEntry) error {
+	path, err := TraceLogPath()
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(path)
+	switch {
+	case err == nil:
+		if fi.Size()+int64(len(buf)) > maxTraceLogSize {
+			err := os.Rename(path, path+".1")
+			if err != nil {
+				return err
+			}
+		}
+	case os.IsNotExist(err):
+		err := os.MkdirAll(filepath.Dir(path), 0700)
+		if err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+
+	//the log may contain URLs with credentials, so it is only readable by us
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(buf, '\n'))
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
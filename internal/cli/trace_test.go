@@ -0,0 +1,165 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withTraceLog points TraceLogPath() into a temporary directory and returns
+// the path of the trace log.
+func withTraceLog(t *testing.T) string {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	path, err := TraceLogPath()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return path
+}
+
+func readTraceEntries(t *testing.T, path string) []TraceEntry {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer f.Close()
+
+	var entries []TraceEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxTraceLogSize)
+	for scanner.Scan() {
+		var e TraceEntry
+		err := json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			t.Fatalf("cannot parse trace log line %q: %s", scanner.Text(), err.Error())
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err.Error())
+	}
+	return entries
+}
+
+func TestTailBuffer(t *testing.T) {
+	var b tailBuffer
+	b.Write([]byte(strings.Repeat("a", maxTracedStderr-1)))
+	b.Write([]byte("b"))
+	if len(b.buf) != maxTracedStderr || b.truncated {
+		t.Errorf("expected %d bytes without truncation, but got %d bytes (truncated = %t)", maxTracedStderr, len(b.buf), b.truncated)
+	}
+
+	n, err := b.Write([]byte("cd"))
+	if n != 2 || err != nil {
+		t.Errorf("expected Write to report 2 bytes without error, but got %d (err = %v)", n, err)
+	}
+	expected := strings.Repeat("a", maxTracedStderr-3) + "bcd"
+	if string(b.buf) != expected || !b.truncated {
+		t.Errorf("expected the last %d bytes with truncation, but got %q (truncated = %t)", maxTracedStderr, string(b.buf), b.truncated)
+	}
+}
+
+func TestWriteTraceExitCodes(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	path := withTraceLog(t)
+
+	exitErr := exec.Command("sh", "-c", "exit 3").Run()
+	killErr := exec.Command("sh", "-c", "kill -9 $$").Run()
+	testCases := []struct {
+		err           error
+		expectedCode  int
+		expectedError string
+	}{
+		{nil, 0, ""},
+		{exitErr, 3, ""},
+		{killErr, -1, "signal: killed"},
+		{errors.New("exec: \"frobnicate\": executable file not found in $PATH"), -1, "exec: \"frobnicate\": executable file not found in $PATH"},
+	}
+
+	startedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tc := range testCases {
+		stderr := &tailBuffer{}
+		stderr.Write([]byte("some output\n"))
+		c := Command{Program: []string{"git", "status"}, WorkDir: "/tmp"}
+		writeTrace("file", c, startedAt, time.Second, tc.err, stderr)
+	}
+
+	entries := readTraceEntries(t, path)
+	if len(entries) != len(testCases) {
+		t.Fatalf("expected %d entries, but got %d", len(testCases), len(entries))
+	}
+	for idx, tc := range testCases {
+		e := entries[idx]
+		if e.ExitCode != tc.expectedCode || e.Error != tc.expectedError {
+			t.Errorf("expected exit code %d and error %q for %v, but got %d and %q", tc.expectedCode, tc.expectedError, tc.err, e.ExitCode, e.Error)
+		}
+		if e.Session != traceSession || !e.StartedAt.Equal(startedAt) || e.Duration != time.Second ||
+			strings.Join(e.Program, " ") != "git status" || e.WorkDir != "/tmp" || e.Stderr != "some output\n" || e.StderrTruncated {
+			t.Errorf("unexpected entry for %v: %#v", tc.err, e)
+		}
+	}
+}
+
+func TestTraceLogRotation(t *testing.T) {
+	path := withTraceLog(t)
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	//a log that is just below the size limit
+	oldEntry, err := json.Marshal(TraceEntry{Session: "old", Program: []string{"true"}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	oldEntry = append(oldEntry, '\n')
+	var oldContents []byte
+	for len(oldContents)+len(oldEntry) < maxTraceLogSize {
+		oldContents = append(oldContents, oldEntry...)
+	}
+	err = os.WriteFile(path, oldContents, 0600)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = appendTraceEntry(TraceEntry{Session: "new", Program: []string{"false"}, ExitCode: 1})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	rotated, err := os.ReadFile(path + ".1")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(rotated) != string(oldContents) {
+		t.Errorf("expected %s.1 to contain the old log (%d bytes), but got %d bytes", path, len(oldContents), len(rotated))
+	}
+	entries := readTraceEntries(t, path)
+	if len(entries) != 1 || entries[0].Session != "new" {
+		t.Errorf("expected only the new entry in %s, but got %#v", path, entries)
+	}
+
+	//the next entry fits, so it is appended without rotating again
+	err = appendTraceEntry(TraceEntry{Session: "new", Program: []string{"true"}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	entries = readTraceEntries(t, path)
+	if len(entries) != 2 {
+		t.Errorf("expected 2 entries in %s, but got %d", path, len(entries))
+	}
+	rotated, err = os.ReadFile(path + ".1")
+	if err != nil || string(rotated) != string(oldContents) {
+		t.Errorf("expected %s.1 to be unchanged (err = %v)", path, err)
+	}
+}
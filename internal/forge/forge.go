@@ -0,0 +1,350 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+// Package forge contains minimal clients for the APIs of code hosting
+// platforms (GitHub, Gitea/Forgejo and GitLab), covering only what rtree
+// needs from them.
+package forge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Repo describes a repository on a forge.
+type Repo struct {
+	//FullName is the path of the repo on the forge, e.g. "owner/name".
+	FullName string
+	CloneURL string
+	SSHURL   string
+	Archived bool
+	Fork     bool
+	//Parent is only filled by Client.GetRepo(), and only if the repo is a fork.
+	Parent *Repo
+}
+
+// Client is a client for the API of a forge.
+type Client interface {
+	//GetRepo returns the repository with the given full name.
+	GetRepo(ctx context.Context, fullName string) (Repo, error)
+	//ListRepos returns all repositories owned by the given organization or
+	//user (for GitLab: group or user).
+	ListRepos(ctx context.Context, owner string) ([]Repo, error)
+	//CreateRepo creates an empty repository with the given full name. The
+	//owner must be the authenticated user or an organization (for GitLab: a
+	//group) that the user can create repos in.
+	CreateRepo(ctx context.Context, fullName string, private bool) (Repo, error)
+}
+
+// requestTimeout is how long each API request may take, including reading the
+// response body. Callers can impose shorter deadlines through the context.
+const requestTimeout = 30 * time.Second
+
+// ErrNotFound is returned by Client methods when the requested object does not
+// exist (or is not visible to us).
+var ErrNotFound = errors.New("not found")
+
+// NewClient builds a client for the given type of forge ("github", "gitea",
+// "forgejo" or "gitlab"). If apiURL is empty, the default API location for
+// the given host is used. The token may be empty for anonymous access.
+func NewClient(forgeType, host, apiURL, token string) (Client, error) {
+	c := httpClient{
+		client: &http.Client{Timeout: requestTimeout},
+		token:  token,
+	}
+	switch forgeType {
+	case "github":
+		if apiURL == "" {
+			if host == "github.com" {
+				apiURL = "https://api.github.com"
+			} else {
+				apiURL = "https://" + host + "/api/v3"
+			}
+		}
+		c.apiURL = apiURL
+		c.authHeader = "Authorization"
+		c.authPrefix = "Bearer "
+		return githubClient{c}, nil
+	case "gitea", "forgejo":
+		if apiURL == "" {
+			apiURL = "https://" + host + "/api/v1"
+		}
+		c.apiURL = apiURL
+		c.authHeader = "Authorization"
+		c.authPrefix = "token "
+		//Gitea and Forgejo return the same payloads as GitHub for our purposes
+		return githubClient{c}, nil
+	case "gitlab":
+		if apiURL == "" {
+			apiURL = "https://" + host + "/api/v4"
+		}
+		c.apiURL = apiURL
+		c.authHeader = "PRIVATE-TOKEN"
+		return gitlabClient{c}, nil
+	default:
+		return nil, fmt.Errorf("unknown forge type: %q (supported types are github, gitea, forgejo and gitlab)", forgeType)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// shared HTTP plumbing
+
+type httpClient struct {
+	client     *http.Client
+	apiURL     string
+	token      string
+	authHeader string
+	authPrefix string
+}
+
+// getJSON performs a GET request on the given API path and decodes the
+// response body into `data`. Returns the response headers for pagination.
+func (c httpClient) getJSON(ctx context.Context, path string, data any) (http.Header, error) {
+	reqURL := strings.TrimSuffix(c.apiURL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req, data)
+}
+
+// postJSON performs a POST request on the given API path with the given
+// payload, and decodes the response body into `data`.
+func (c httpClient) postJSON(ctx context.Context, path string, payload, data any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	reqURL := strings.TrimSuffix(c.apiURL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	_, err = c.do(req, data)
+	return err
+}
+
+func (c httpClient) do(req *http.Request, data any) (http.Header, error) {
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set(c.authHeader, c.authPrefix+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL, ErrNotFound)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return nil, fmt.Errorf("%s %s: got status %d: %s",
+			req.Method, req.URL, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if data == nil {
+		return resp.Header, nil
+	}
+	err = json.Unmarshal(body, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: cannot decode response: %w", req.Method, req.URL, err)
+	}
+	return resp.Header, nil
+}
+
+// getAllPages calls getJSON for each page of a paginated listing, until an
+// empty page is returned. The path must already contain a query string.
+func getAllPages[T any](ctx context.Context, c httpClient, path string) ([]T, error) {
+	var result []T
+	for page := 1; ; page++ {
+		var data []T
+		_, err := c.getJSON(ctx, fmt.Sprintf("%s&page=%d", path, page), &data)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 {
+			return result, nil
+		}
+		result = append(result, data...)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// GitHub (and Gitea/Forgejo)
+
+type githubClient struct {
+	httpClient
+}
+
+type githubRepo struct {
+	FullName string      `json:"full_name"`
+	CloneURL string      `json:"clone_url"`
+	SSHURL   string      `json:"ssh_url"`
+	Archived bool        `json:"archived"`
+	Fork     bool        `json:"fork"`
+	Parent   *githubRepo `json:"parent"`
+}
+
+func (r githubRepo) toRepo() Repo {
+	result := Repo{
+		FullName: r.FullName,
+		CloneURL: r.CloneURL,
+		SSHURL:   r.SSHURL,
+		Archived: r.Archived,
+		Fork:     r.Fork,
+	}
+	if r.Parent != nil {
+		parent := r.Parent.toRepo()
+		result.Parent = &parent
+	}
+	return result
+}
+
+// GetRepo implements the Client interface.
+func (c githubClient) GetRepo(ctx context.Context, fullName string) (Repo, error) {
+	var data githubRepo
+	_, err := c.getJSON(ctx, "/repos/"+fullName, &data)
+	return data.toRepo(), err
+}
+
+// ListRepos implements the Client interface.
+func (c githubClient) ListRepos(ctx context.Context, owner string) ([]Repo, error) {
+	//NOTE: GitHub uses "per_page", Gitea uses "limit"; and both clamp the
+	//page size to their respective maximum
+	query := "?per_page=100&limit=50"
+	data, err := getAllPages[githubRepo](ctx, c.httpClient, "/orgs/"+url.PathEscape(owner)+"/repos"+query)
+	if errors.Is(err, ErrNotFound) {
+		data, err = getAllPages[githubRepo](ctx, c.httpClient, "/users/"+url.PathEscape(owner)+"/repos"+query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Repo, len(data))
+	for idx, r := range data {
+		result[idx] = r.toRepo()
+	}
+	return result, nil
+}
+
+// CreateRepo implements the Client interface.
+func (c githubClient) CreateRepo(ctx context.Context, fullName string, private bool) (Repo, error) {
+	owner, name, ok := strings.Cut(fullName, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return Repo{}, fmt.Errorf("cannot create repo %q: expected a name like \"owner/repo\"", fullName)
+	}
+
+	//repos for the user and for organizations are created through different endpoints
+	var user struct {
+		Login string `json:"login"`
+	}
+	_, err := c.getJSON(ctx, "/user", &user)
+	if err != nil {
+		return Repo{}, err
+	}
+	path := "/orgs/" + url.PathEscape(owner) + "/repos"
+	if strings.EqualFold(user.Login, owner) {
+		path = "/user/repos"
+	}
+
+	var data githubRepo
+	err = c.postJSON(ctx, path, map[string]any{"name": name, "private": private}, &data)
+	return data.toRepo(), err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// GitLab
+
+type gitlabClient struct {
+	httpClient
+}
+
+type gitlabProject struct {
+	PathWithNamespace string         `json:"path_with_namespace"`
+	HTTPURLToRepo     string         `json:"http_url_to_repo"`
+	SSHURLToRepo      string         `json:"ssh_url_to_repo"`
+	Archived          bool           `json:"archived"`
+	ForkedFromProject *gitlabProject `json:"forked_from_project"`
+}
+
+func (p gitlabProject) toRepo() Repo {
+	result := Repo{
+		FullName: p.PathWithNamespace,
+		CloneURL: p.HTTPURLToRepo,
+		SSHURL:   p.SSHURLToRepo,
+		Archived: p.Archived,
+		Fork:     p.ForkedFromProject != nil,
+	}
+	if p.ForkedFromProject != nil {
+		parent := p.ForkedFromProject.toRepo()
+		result.Parent = &parent
+	}
+	return result
+}
+
+// GetRepo implements the Client interface.
+func (c gitlabClient) GetRepo(ctx context.Context, fullName string) (Repo, error) {
+	var data gitlabProject
+	_, err := c.getJSON(ctx, "/projects/"+url.PathEscape(fullName), &data)
+	return data.toRepo(), err
+}
+
+// ListRepos implements the Client interface.
+func (c gitlabClient) ListRepos(ctx context.Context, owner string) ([]Repo, error) {
+	data, err := getAllPages[gitlabProject](ctx, c.httpClient,
+		"/groups/"+url.PathEscape(owner)+"/projects?per_page=100&include_subgroups=true")
+	if errors.Is(err, ErrNotFound) {
+		data, err = getAllPages[gitlabProject](ctx, c.httpClient, "/users/"+url.PathEscape(owner)+"/projects?per_page=100")
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Repo, len(data))
+	for idx, p := range data {
+		result[idx] = p.toRepo()
+	}
+	return result, nil
+}
+
+// CreateRepo implements the Client interface.
+func (c gitlabClient) CreateRepo(ctx context.Context, fullName string, private bool) (Repo, error) {
+	idx := strings.LastIndex(fullName, "/")
+	if idx <= 0 || idx == len(fullName)-1 {
+		return Repo{}, fmt.Errorf("cannot create project %q: expected a name like \"group/project\"", fullName)
+	}
+	namespacePath, name := fullName[:idx], fullName[idx+1:]
+
+	var namespace struct {
+		ID int `json:"id"`
+	}
+	_, err := c.getJSON(ctx, "/namespaces/"+url.PathEscape(namespacePath), &namespace)
+	if err != nil {
+		return Repo{}, err
+	}
+	visibility := "public"
+	if private {
+		visibility = "private"
+	}
+
+	var data gitlabProject
+	err = c.postJSON(ctx, "/projects", map[string]any{
+		"name":         name,
+		"path":         name,
+		"namespace_id": namespace.ID,
+		"visibility":   visibility,
+	}, &data)
+	return data.toRepo(), err
+}
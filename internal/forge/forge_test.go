@@ -0,0 +1,187 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package forge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestServer returns a server that answers GET requests for the given paths
+// with the given JSON payloads, and checks that the given auth header is set.
+// Other requests are matched by method, path and request body, e.g. `POST
+// /user/repos {"name":"foo"}`.
+func newTestServer(t *testing.T, authHeader, authValue string, responses map[string]string) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if actual := r.Header.Get(authHeader); actual != authValue {
+			t.Errorf("expected %s header %q, but got %q", authHeader, authValue, actual)
+		}
+		if r.Method != http.MethodGet {
+			body, _ := io.ReadAll(r.Body)
+			payload, ok := responses[r.Method+" "+r.URL.EscapedPath()+" "+string(body)]
+			if !ok {
+				http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(payload))
+			return
+		}
+		payload, ok := responses[r.URL.EscapedPath()+"?"+r.URL.RawQuery]
+		if !ok {
+			payload, ok = responses[r.URL.EscapedPath()]
+		}
+		if !ok {
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(payload))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestGitHubGetRepo(t *testing.T) {
+	s := newTestServer(t, "Authorization", "Bearer secret", map[string]string{
+		"/repos/me/git": `{"full_name":"me/git","clone_url":"https://github.com/me/git.git","ssh_url":"git@github.com:me/git.git","fork":true,
+			"parent":{"full_name":"git/git","clone_url":"https://github.com/git/git.git","ssh_url":"git@github.com:git/git.git"}}`,
+	})
+	c, err := NewClient("github", "github.com", s.URL, "secret")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	repo, err := c.GetRepo(context.Background(), "me/git")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !repo.Fork || repo.Parent == nil || repo.Parent.FullName != "git/git" || repo.Parent.CloneURL != "https://github.com/git/git.git" {
+		t.Errorf("unexpected result: %#v", repo)
+	}
+
+	_, err = c.GetRepo(context.Background(), "me/missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, but got %v", err)
+	}
+}
+
+func TestGitLabGetRepo(t *testing.T) {
+	s := newTestServer(t, "PRIVATE-TOKEN", "secret", map[string]string{
+		"/projects/me%2Fgroup%2Fproject": `{"path_with_namespace":"me/group/project","http_url_to_repo":"https://gitlab.com/me/group/project.git",
+			"ssh_url_to_repo":"git@gitlab.com:me/group/project.git","archived":true,
+			"forked_from_project":{"path_with_namespace":"upstream/project","http_url_to_repo":"https://gitlab.com/upstream/project.git"}}`,
+	})
+	c, err := NewClient("gitlab", "gitlab.com", s.URL, "secret")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	repo, err := c.GetRepo(context.Background(), "me/group/project")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !repo.Archived || !repo.Fork || repo.Parent == nil || repo.Parent.FullName != "upstream/project" {
+		t.Errorf("unexpected result: %#v", repo)
+	}
+}
+
+func TestUnknownForgeType(t *testing.T) {
+	_, err := NewClient("sourceforge", "sourceforge.net", "", "")
+	if err == nil {
+		t.Error("expected error for unknown forge type, but got nil")
+	}
+}
+
+func TestGitHubListReposFallsBackToUser(t *testing.T) {
+	s := newTestServer(t, "Authorization", "", map[string]string{
+		"/users/me/repos?per_page=100&limit=50&page=1": `[{"full_name":"me/one","clone_url":"https://github.com/me/one.git"},{"full_name":"me/two","fork":true}]`,
+		"/users/me/repos?per_page=100&limit=50&page=2": `[{"full_name":"me/three","archived":true}]`,
+		"/users/me/repos?per_page=100&limit=50&page=3": `[]`,
+	})
+	c, err := NewClient("github", "github.com", s.URL, "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	repos, err := c.ListRepos(context.Background(), "me")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(repos) != 3 || repos[0].FullName != "me/one" || !repos[1].Fork || !repos[2].Archived {
+		t.Errorf("unexpected result: %#v", repos)
+	}
+}
+
+func TestGitHubCreateRepo(t *testing.T) {
+	s := newTestServer(t, "Authorization", "Bearer secret", map[string]string{
+		"/user": `{"login":"Me"}`,
+		`POST /user/repos {"name":"new","private":true}`:      `{"full_name":"me/new","clone_url":"https://github.com/me/new.git"}`,
+		`POST /orgs/org/repos {"name":"new","private":false}`: `{"full_name":"org/new","clone_url":"https://github.com/org/new.git"}`,
+	})
+	c, err := NewClient("github", "github.com", s.URL, "secret")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	repo, err := c.CreateRepo(context.Background(), "me/new", true)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if repo.FullName != "me/new" {
+		t.Errorf("unexpected result: %#v", repo)
+	}
+	repo, err = c.CreateRepo(context.Background(), "org/new", false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if repo.FullName != "org/new" {
+		t.Errorf("unexpected result: %#v", repo)
+	}
+}
+
+func TestGitLabCreateRepo(t *testing.T) {
+	s := newTestServer(t, "PRIVATE-TOKEN", "secret", map[string]string{
+		"/namespaces/me%2Fgroup": `{"id":42}`,
+		`POST /projects {"name":"project","namespace_id":42,"path":"project","visibility":"private"}`: `{"path_with_namespace":"me/group/project"}`,
+	})
+	c, err := NewClient("gitlab", "gitlab.com", s.URL, "secret")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	repo, err := c.CreateRepo(context.Background(), "me/group/project", true)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if repo.FullName != "me/group/project" {
+		t.Errorf("unexpected result: %#v", repo)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	t.Cleanup(s.Close)
+	c, err := NewClient("gitea", "example.com", s.URL, "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	c.(githubClient).client.Timeout = 50 * time.Millisecond
+
+	_, err = c.GetRepo(context.Background(), "foo/bar")
+	var netErr interface{ Timeout() bool }
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("expected a timeout error, but got %v", err)
+	}
+}
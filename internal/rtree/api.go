@@ -0,0 +1,188 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// The methods in this file are the library counterparts of the rtree
+// subcommands. Unlike the subcommands, they never prompt the user, and they
+// return their results instead of printing them. Each call reads the index
+// file afresh, and writes it back if it was changed. When the context is
+// canceled, commands that are still running (e.g. `git clone`) are interrupted.
+
+// RebuildResult is returned by RTree.Rebuild().
+type RebuildResult struct {
+	//Added contains the repos that were found on disk, but were not in the
+	//index before.
+	Added []*Repo
+	//Missing contains the repos that are in the index, but not on disk. These
+	//are retained in the index.
+	Missing []*Repo
+}
+
+// readIndexForAPI is like readIndex, but reports validation errors as a single error.
+func (t *RTree) readIndexForAPI(ctx context.Context) (*Index, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+	index, errs := t.readIndex()
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return index, nil
+}
+
+// Repos returns all repos in the index.
+func (t *RTree) Repos(ctx context.Context) ([]*Repo, error) {
+	index, err := t.readIndexForAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return index.Repos, nil
+}
+
+// Find returns the repo with the given remote URL (or checkout path), or nil
+// if there is no such repo in the index.
+func (t *RTree) Find(ctx context.Context, url string) (*Repo, error) {
+	index, err := t.readIndexForAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	repo, _, err := index.lookupRepo(url)
+	return repo, err
+}
+
+// Get is like Find, but if the repo is not in the index, it is cloned and
+// added to the index. Unlike `rtree get`, this does not look for forks of the
+// repo: The repo is always cloned into its own checkout path.
+func (t *RTree) Get(ctx context.Context, url string) (*Repo, error) {
+	index, err := t.readIndexForAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	repo, newRepo, err := index.lookupRepo(url)
+	if repo != nil || err != nil {
+		return repo, err
+	}
+
+	err = ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+	err = newRepo.Checkout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	index.Repos = append(index.Repos, &newRepo)
+	newRepo.runHooksAfterCheckout(ctx)
+
+	//the clone has succeeded, so it stays in the index even if the root
+	//commits cannot be determined (`rtree index` will try again later)
+	if !t.dryRun {
+		err = newRepo.LoadRootCommits(ctx)
+		if err != nil {
+			t.ui.ShowWarning(fmt.Sprintf("cannot find root commits of %s: %s", newRepo.AbsolutePath(), err.Error()))
+		}
+	}
+	return &newRepo, index.Write()
+}
+
+// Create initializes a new repo for the given remote URL (which does not need
+// to exist yet), and adds it to the index. Like `rtree new`, it can also
+// create the repo on the forge.
+func (t *RTree) Create(ctx context.Context, url string, opts NewRepoOptions) (*Repo, error) {
+	index, err := t.readIndexForAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return index.CreateRepo(ctx, url, opts)
+}
+
+// Drop deletes the repo with the given remote URL (or checkout path) from disk
+// and from the index. Unlike `rtree drop`, this does not ask for confirmation.
+func (t *RTree) Drop(ctx context.Context, url string) error {
+	index, err := t.readIndexForAPI(ctx)
+	if err != nil {
+		return err
+	}
+	repo, _, err := index.lookupRepo(url)
+	if err != nil {
+		return err
+	}
+	if repo == nil {
+		return fmt.Errorf("no repo for %s in index", url)
+	}
+	return index.removeRepo(repo)
+}
+
+// Import moves the repo at the given path into the repository tree, and adds
+// it to the index. The URL of the given remote (or of "origin", if remoteName
+// is empty) determines the checkout path. A symlink is left at the original
+// location.
+func (t *RTree) Import(ctx context.Context, dirPath, remoteName string) (*Repo, error) {
+	index, err := t.readIndexForAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := t.newRepoForImport(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if remoteName == "" {
+		remoteName = "origin"
+	}
+	remote, exists := repo.Remotes[remoteName]
+	if !exists {
+		return nil, fmt.Errorf("repo at %s has no remote %q", dirPath, remoteName)
+	}
+	checkoutPath, err := remote.URLs[0].CheckoutPath()
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+	err = index.moveIntoTree(&repo, checkoutPath)
+	if err != nil {
+		return nil, err
+	}
+	return &repo, index.Write()
+}
+
+// Rebuild scans the repository tree and updates the index accordingly, like
+// `rtree index`. Unlike `rtree index`, repos that are missing on disk are not
+// restored or removed, but reported in the result, and the remote URLs in the
+// repos' configuration are not reformatted.
+func (t *RTree) Rebuild(ctx context.Context) (RebuildResult, error) {
+	var result RebuildResult
+	index, err := t.readIndexForAPI(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	for _, repo := range index.Repos {
+		_, err := os.Stat(repo.MetadataDirPath())
+		switch {
+		case os.IsNotExist(err):
+			result.Missing = append(result.Missing, repo)
+		case err != nil:
+			return result, err
+		}
+	}
+
+	index.Repos, result.Added, err = index.addPhysicalRepos(ctx, index.Repos)
+	if err != nil {
+		return RebuildResult{}, err
+	}
+	return result, index.Write()
+}
@@ -0,0 +1,269 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestTree creates an RTree for tests that use the Go API instead of
+// Test.Run(). The index file is initialized with the given index.
+func newTestTree(t *testing.T, index Index, cs *CommandSimulator) *RTree {
+	opts := testTreeOptions(t)
+	opts.CommandRunner = cs.Next
+	err := writeIndexFixture(opts.IndexPath, index)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tree, err := New(opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Cleanup(func() {
+		if cs.idx != len(cs.Cmd) {
+			t.Errorf("expected %d commands to be executed, but got %d", len(cs.Cmd), cs.idx)
+		}
+	})
+	return tree
+}
+
+func mustMkdirAll(t *testing.T, path string) {
+	err := os.MkdirAll(path, 0755)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestAPIFindAndGet(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	newPath := filepath.Join(rootPath, "github.com/foo/new")
+	cs := &CommandSimulator{Cmd: []RecordedCommand{
+		Recorded("git clone https://github.com/foo/new " + newPath)[0],
+		{
+			Cmd:    Recorded("@" + newPath + " git rev-list --max-parents=0 --all")[0].Cmd,
+			Stdout: "1234567890123456789012345678901234567890\n",
+		},
+	}}
+	tree := newTestTree(t, testIndexWithTwoRepos, cs)
+	ctx := context.Background()
+
+	repo, err := tree.Find(ctx, "gh:git/git")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if repo == nil || repo.AbsolutePath() != filepath.Join(rootPath, "github.com/git/git") {
+		t.Errorf("expected to find github.com/git/git, but got %#v", repo)
+	}
+
+	repo, err = tree.Find(ctx, "gh:foo/new")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if repo != nil {
+		t.Errorf("expected not to find gh:foo/new, but got %#v", repo)
+	}
+
+	//unlike `rtree get`, this does not offer github.com/foo/bar as a fork candidate
+	repo, err = tree.Get(ctx, "gh:foo/new")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if repo.AbsolutePath() != newPath {
+		t.Errorf("expected repo at %s, but got %s", newPath, repo.AbsolutePath())
+	}
+
+	repos, err := tree.Repos(ctx)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(repos) != 3 || repos[1].CheckoutPath != "github.com/foo/new" || len(repos[1].RootCommits) != 1 {
+		t.Errorf("expected the new repo to be in the index, but got %#v", repos)
+	}
+}
+
+func TestAPIGetCanceled(t *testing.T) {
+	cachePath := withCache(t, false)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	//the context is canceled while the cache is being prepared, so neither
+	//the fetch into the cache nor the clone are started
+	cs := &CommandSimulator{Cmd: []RecordedCommand{
+		{
+			Cmd:        Recorded("git init --quiet --bare " + cachePath)[0].Cmd,
+			SideEffect: cancel,
+		},
+		Recorded("@" + cachePath + " git config gc.pruneExpire never")[0],
+	}}
+	tree := newTestTree(t, testIndexWithTwoRepos, cs)
+
+	_, err := tree.Get(ctx, "gh:foo/new")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected Get() to fail with context.Canceled, but got %v", err)
+	}
+	repos, err := tree.Repos(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(repos) != 2 {
+		t.Errorf("expected the index to be unchanged, but got %#v", repos)
+	}
+}
+
+func TestAPIGetWithoutRootCommits(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	newPath := filepath.Join(rootPath, "github.com/foo/new")
+	cs := &CommandSimulator{Cmd: []RecordedCommand{
+		Recorded("git clone https://github.com/foo/new " + newPath)[0],
+		{
+			Cmd:   Recorded("@" + newPath + " git rev-list --max-parents=0 --all")[0].Cmd,
+			Fails: true,
+		},
+	}}
+	tree := newTestTree(t, testIndexWithTwoRepos, cs)
+	ctx := context.Background()
+
+	//the clone exists on disk, so it must be in the index even though its
+	//root commits are unknown
+	repo, err := tree.Get(ctx, "gh:foo/new")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if repo.AbsolutePath() != newPath || len(repo.RootCommits) != 0 {
+		t.Errorf("expected repo at %s without root commits, but got %#v", newPath, repo)
+	}
+	repos, err := tree.Repos(ctx)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(repos) != 3 || repos[1].CheckoutPath != "github.com/foo/new" {
+		t.Errorf("expected the new repo to be in the index, but got %#v", repos)
+	}
+}
+
+func TestAPIRebuildAndDrop(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	gitPath := filepath.Join(rootPath, "github.com/git/git")
+	newPath := filepath.Join(rootPath, "github.com/foo/new")
+	mustMkdirAll(t, filepath.Join(gitPath, ".git"))
+	mustMkdirAll(t, filepath.Join(newPath, ".git"))
+
+	cs := &CommandSimulator{Cmd: []RecordedCommand{
+		{
+			Cmd:    Recorded("@" + newPath + " git config -l")[0].Cmd,
+			Stdout: "remote.origin.url=gh:foo/new\n",
+		},
+		Recorded("@" + newPath + " git rev-list --max-parents=0 --all")[0],
+		{
+			Cmd:    Recorded("@" + gitPath + " git config -l")[0].Cmd,
+			Stdout: "remote.origin.url=https://github.com/git/git\n",
+		},
+		Recorded("@" + gitPath + " git rev-list --max-parents=0 --all")[0],
+	}}
+	tree := newTestTree(t, testIndexWithTwoRepos, cs)
+	ctx := context.Background()
+
+	//unlike `rtree index`, this does not ask what to do about missing repos
+	result, err := tree.Rebuild(ctx)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(result.Added) != 1 || result.Added[0].CheckoutPath != "github.com/foo/new" {
+		t.Errorf("expected github.com/foo/new to be added, but got %#v", result.Added)
+	}
+	if len(result.Missing) != 1 || result.Missing[0].CheckoutPath != "github.com/foo/bar" {
+		t.Errorf("expected github.com/foo/bar to be missing, but got %#v", result.Missing)
+	}
+
+	//unlike `rtree drop`, this does not ask for confirmation
+	err = tree.Drop(ctx, "gh:foo/new")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := os.Stat(newPath); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be deleted, but got err = %v", newPath, err)
+	}
+	repos, err := tree.Repos(ctx)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(repos) != 2 {
+		t.Errorf("expected two repos in the index, but got %#v", repos)
+	}
+
+	err = tree.Drop(ctx, "gh:foo/new")
+	if err == nil {
+		t.Error("expected second Drop() to fail, but it succeeded")
+	}
+}
+
+func TestAPIImport(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	oldPath := filepath.Join(t.TempDir(), "imported")
+	mustMkdirAll(t, filepath.Join(oldPath, ".git"))
+
+	cs := &CommandSimulator{Cmd: []RecordedCommand{{
+		Cmd: Recorded("@" + oldPath + " git config -l")[0].Cmd,
+		Stdout: "remote.origin.url=https://github.com/foo/imported\n" +
+			"remote.mirror.url=https://example.org/imported\n",
+	}}}
+	tree := newTestTree(t, testIndexWithTwoRepos, cs)
+
+	repo, err := tree.Import(context.Background(), oldPath, "mirror")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	newPath := filepath.Join(rootPath, "example.org/imported")
+	if repo.AbsolutePath() != newPath {
+		t.Errorf("expected repo to be moved to %s, but got %s", newPath, repo.AbsolutePath())
+	}
+	target, err := os.Readlink(oldPath)
+	if err != nil || target != newPath {
+		t.Errorf("expected symlink from %s to %s, but got %q (err = %v)", oldPath, newPath, target, err)
+	}
+}
+
+func TestAPICanceledContext(t *testing.T) {
+	tree := newTestTree(t, testIndexWithTwoRepos, &CommandSimulator{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := tree.Get(ctx, "gh:foo/new")
+	if err != context.Canceled {
+		t.Errorf("expected Get() to fail with %v, but got %v", context.Canceled, err)
+	}
+}
+
+func TestAPIRebuildCanceled(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	newPath := filepath.Join(rootPath, "github.com/foo/new")
+	mustMkdirAll(t, filepath.Join(newPath, ".git"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	//the context is canceled while the repo is being scanned, so its root
+	//commits are not loaded
+	cs := &CommandSimulator{Cmd: []RecordedCommand{{
+		Cmd:        Recorded("@" + newPath + " git config -l")[0].Cmd,
+		Stdout:     "remote.origin.url=gh:foo/new\n",
+		SideEffect: cancel,
+	}}}
+	tree := newTestTree(t, testIndexWithTwoRepos, cs)
+
+	_, err := tree.Rebuild(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected Rebuild() to fail with context.Canceled, but got %v", err)
+	}
+	repos, err := tree.Repos(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(repos) != 2 {
+		t.Errorf("expected the index to be unchanged, but got %#v", repos)
+	}
+}
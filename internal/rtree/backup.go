@@ -0,0 +1,306 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"git.xyrillian.de/gofu/internal/cli"
+)
+
+// BackupManifest describes the contents of a backup created by `rtree backup`.
+type BackupManifest struct {
+	Repos []BackupEntry `json:"repos"`
+}
+
+// BackupEntry describes the unpushed work of a single repo in a BackupManifest.
+type BackupEntry struct {
+	//CheckoutPath is relative to the RootPath, like in the index.
+	CheckoutPath string `json:"path"`
+	//BundlePath is relative to the backup directory.
+	BundlePath string        `json:"bundle"`
+	Refs       []BackupRef   `json:"refs,omitempty"`
+	Stashes    []BackupStash `json:"stashes,omitempty"`
+}
+
+// BackupRef is a ref that contains commits that are not on any remote.
+type BackupRef struct {
+	Name   string `json:"name"`
+	Object string `json:"object"`
+}
+
+// BackupStash is an entry from `git stash list`. Stashes are listed from newest
+// to oldest, like in `git stash list`.
+type BackupStash struct {
+	Commit  string `json:"commit"`
+	Message string `json:"message"`
+}
+
+const (
+	backupManifestName = "manifest.json"
+	//While a bundle is created, each stash is referenced by a temporary ref
+	//below this prefix.
+	backupStashRefPrefix = "refs/rtree-stash/"
+)
+
+// commandBackup implements `rtree backup`.
+func commandBackup(index *Index, backupDir string) error {
+	backupDir, err := filepath.Abs(backupDir)
+	if err != nil {
+		return err
+	}
+
+	var (
+		manifest BackupManifest
+		errs     []error
+	)
+	for _, repo := range index.Repos {
+		if !repo.HasGitDir() {
+			index.tree.ui.ShowWarning(fmt.Sprintf("skipping %s: backups are only supported for Git repos", repo.AbsolutePath()))
+			continue
+		}
+		_, err := os.Stat(repo.GitDirPath())
+		if os.IsNotExist(err) {
+			index.tree.ui.ShowWarning(fmt.Sprintf("skipping %s: not checked out", repo.AbsolutePath()))
+			continue
+		}
+		entry, err := repo.BackupUnpushedWork(backupDir)
+		if err != nil {
+			//keep going, so that the manifest covers at least the other repos
+			errs = append(errs, fmt.Errorf("cannot back up %s: %w", repo.AbsolutePath(), err))
+			continue
+		}
+		if entry != nil {
+			manifest.Repos = append(manifest.Repos, *entry)
+		}
+	}
+
+	buf, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = index.tree.fs.MkdirAll(backupDir, 0755)
+	if err == nil {
+		err = index.tree.fs.WriteFile(filepath.Join(backupDir, backupManifestName), buf, 0644)
+	}
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// BackupUnpushedWork writes all refs with commits that are not on any remote,
+// as well as all stashes, into a bundle below the given backup directory.
+// Returns nil if there is nothing to back up.
+func (r Repo) BackupUnpushedWork(backupDir string) (*BackupEntry, error) {
+	entry := BackupEntry{
+		CheckoutPath: r.CheckoutPath,
+		BundlePath:   r.CheckoutPath + ".bundle",
+	}
+
+	//find refs with unpushed commits
+	out, err := r.tree.ui.CaptureStdout(cli.Command{
+		Program:  []string{"git", "for-each-ref", "--format=%(refname) %(objectname)", "refs/heads", "refs/tags"},
+		WorkDir:  r.AbsolutePath(),
+		ReadOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for line := range strings.SplitSeq(strings.TrimSpace(out), "\n") {
+		refName, object, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		out, err := r.tree.ui.CaptureStdout(cli.Command{
+			Program:  []string{"git", "rev-list", "-n1", refName, "--not", "--remotes"},
+			WorkDir:  r.AbsolutePath(),
+			ReadOnly: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(out) != "" {
+			entry.Refs = append(entry.Refs, BackupRef{Name: refName, Object: object})
+		}
+	}
+
+	//find stashes
+	out, err = r.tree.ui.CaptureStdout(cli.Command{
+		Program:  []string{"git", "stash", "list", "--format=%H %gs"},
+		WorkDir:  r.AbsolutePath(),
+		ReadOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for line := range strings.SplitSeq(strings.TrimSpace(out), "\n") {
+		commit, message, ok := strings.Cut(line, " ")
+		if ok {
+			entry.Stashes = append(entry.Stashes, BackupStash{Commit: commit, Message: message})
+		}
+	}
+
+	if len(entry.Refs) == 0 && len(entry.Stashes) == 0 {
+		return nil, nil
+	}
+	r.tree.ui.ShowProgress(fmt.Sprintf("%s: %d refs, %d stashes", r.AbsolutePath(), len(entry.Refs), len(entry.Stashes)))
+
+	//write bundle (git bundle only includes commits that are reachable from a
+	//ref given by name, so stashes need temporary refs; otherwise a repo with
+	//only stashes would yield an empty bundle, which git refuses to create)
+	bundlePath := filepath.Join(backupDir, entry.BundlePath)
+	err = r.tree.fs.MkdirAll(filepath.Dir(bundlePath), 0755)
+	if err != nil {
+		return nil, err
+	}
+	cmdline := []string{"git", "bundle", "create", "--quiet", bundlePath}
+	for _, ref := range entry.Refs {
+		cmdline = append(cmdline, ref.Name)
+	}
+	var stashRefs []string
+	defer func() {
+		for _, ref := range stashRefs {
+			err := r.tree.ui.Run(cli.Command{Program: []string{"git", "update-ref", "-d", ref}, WorkDir: r.AbsolutePath()})
+			if err != nil {
+				r.tree.ui.ShowWarning(fmt.Sprintf("could not delete temporary ref %s in %s: %s", ref, r.AbsolutePath(), err.Error()))
+			}
+		}
+	}()
+	for idx, stash := range entry.Stashes {
+		ref := fmt.Sprintf("%s%d", backupStashRefPrefix, idx)
+		err := r.tree.ui.Run(cli.Command{Program: []string{"git", "update-ref", ref, stash.Commit}, WorkDir: r.AbsolutePath()})
+		if err != nil {
+			return nil, err
+		}
+		stashRefs = append(stashRefs, ref)
+		cmdline = append(cmdline, ref)
+	}
+	cmdline = append(cmdline, "--not", "--remotes")
+	err = r.tree.ui.Run(cli.Command{Program: cmdline, WorkDir: r.AbsolutePath()})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// commandRestoreBackup implements `rtree restore-backup`.
+func commandRestoreBackup(index *Index, backupDir string) error {
+	backupDir, err := filepath.Abs(backupDir)
+	if err != nil {
+		return err
+	}
+	buf, err := os.ReadFile(filepath.Join(backupDir, backupManifestName))
+	if err != nil {
+		return err
+	}
+	var manifest BackupManifest
+	err = json.Unmarshal(buf, &manifest)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", filepath.Join(backupDir, backupManifestName), err)
+	}
+
+	var errs []error
+	for _, entry := range manifest.Repos {
+		idx := slices.IndexFunc(index.Repos, func(r *Repo) bool { return r.CheckoutPath == entry.CheckoutPath })
+		if idx == -1 {
+			errs = append(errs, fmt.Errorf("cannot restore backup for %s: not in index", entry.CheckoutPath))
+			continue
+		}
+		repo := index.Repos[idx]
+		_, err := os.Stat(repo.GitDirPath())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot restore backup for %s: not checked out (try `rtree index` first)", repo.AbsolutePath()))
+			continue
+		}
+
+		index.tree.ui.ShowProgress(repo.AbsolutePath())
+		err = repo.RestoreUnpushedWork(filepath.Join(backupDir, entry.BundlePath), entry)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RestoreUnpushedWork applies a bundle created by BackupUnpushedWork. Refs
+// that do not exist yet are created, and existing refs are fast-forwarded.
+// Refs that have diverged from the backup are left alone, but the backed-up
+// state remains available below "refs/rtree-backup/".
+func (r Repo) RestoreUnpushedWork(bundlePath string, entry BackupEntry) error {
+	run := func(args ...string) error {
+		return r.tree.ui.Run(cli.Command{Program: args, WorkDir: r.AbsolutePath()})
+	}
+	capture := func(args ...string) (string, error) {
+		out, err := r.tree.ui.CaptureStdout(cli.Command{Program: args, WorkDir: r.AbsolutePath(), ReadOnly: true})
+		return strings.TrimSpace(out), err
+	}
+
+	//fetch everything into a separate namespace first (this also brings in
+	//the objects for the stashes)
+	err := run("git", "fetch", "--quiet", bundlePath, "+refs/*:refs/rtree-backup/*")
+	if err != nil {
+		return err
+	}
+	currentRef, err := capture("git", "symbolic-ref", "-q", "HEAD")
+	if err != nil {
+		currentRef = "" //detached HEAD
+	}
+
+	for _, ref := range entry.Refs {
+		backupRef := "refs/rtree-backup/" + strings.TrimPrefix(ref.Name, "refs/")
+		oldObject, err := capture("git", "rev-parse", "-q", "--verify", ref.Name)
+		switch {
+		case err != nil:
+			//ref does not exist yet
+			err = run("git", "update-ref", ref.Name, ref.Object)
+		case oldObject == ref.Object:
+			//nothing to do
+		case run("git", "merge-base", "--is-ancestor", oldObject, ref.Object) == nil:
+			if ref.Name == currentRef {
+				err = run("git", "merge", "--ff-only", "--quiet", ref.Object)
+			} else {
+				err = run("git", "update-ref", ref.Name, ref.Object, oldObject)
+			}
+		default:
+			r.tree.ui.ShowWarning(fmt.Sprintf(
+				"%s has diverged from the backup, which is available as %s", ref.Name, backupRef))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = run("git", "update-ref", "-d", backupRef)
+		if err != nil {
+			return err
+		}
+	}
+
+	//restore stashes (oldest first, so that they end up in the same order)
+	existingStashes, err := capture("git", "stash", "list", "--format=%H")
+	if err != nil {
+		return err
+	}
+	for idx, stash := range slices.Backward(entry.Stashes) {
+		if !slices.Contains(strings.Fields(existingStashes), stash.Commit) {
+			err := run("git", "stash", "store", "-m", stash.Message, stash.Commit)
+			if err != nil {
+				return err
+			}
+		}
+		//the stash reflog now keeps the commit alive, so the temporary ref
+		//from the bundle is not needed anymore
+		err = run("git", "update-ref", "-d", fmt.Sprintf("refs/rtree-backup/%s%d", strings.TrimPrefix(backupStashRefPrefix, "refs/"), idx))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
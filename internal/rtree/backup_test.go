@@ -0,0 +1,235 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"git.xyrillian.de/gofu/internal/cli"
+)
+
+func recordedIn(workDir string, args ...string) cli.Command {
+	return cli.Command{Program: args, WorkDir: workDir}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	for _, repo := range testIndexWithTwoRepos.Repos {
+		err := os.MkdirAll(filepath.Join(rootPath, repo.CheckoutPath, ".git"), 0755)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	backupDir := t.TempDir()
+	fooPath := filepath.Join(rootPath, "github.com/foo/bar")
+	gitPath := filepath.Join(rootPath, "github.com/git/git")
+	bundlePath := filepath.Join(backupDir, "github.com/git/git.bundle")
+
+	//github.com/foo/bar has nothing to back up, github.com/git/git has an
+	//unpushed branch and a stash
+	Test{
+		Args:        []string{"backup", backupDir},
+		Index:       testIndexWithTwoRepos,
+		ExpectError: ">> " + gitPath + ": 1 refs, 1 stashes\n",
+		ExpectExecution: []RecordedCommand{
+			{
+				Cmd:    recordedIn(fooPath, "git", "for-each-ref", "--format=%(refname) %(objectname)", "refs/heads", "refs/tags"),
+				Stdout: "refs/heads/main 1111111111111111111111111111111111111111\n",
+			},
+			Recorded("@" + fooPath + " git rev-list -n1 refs/heads/main --not --remotes")[0],
+			{Cmd: recordedIn(fooPath, "git", "stash", "list", "--format=%H %gs")},
+			{
+				Cmd: recordedIn(gitPath, "git", "for-each-ref", "--format=%(refname) %(objectname)", "refs/heads", "refs/tags"),
+				Stdout: "refs/heads/master 2222222222222222222222222222222222222222\n" +
+					"refs/heads/wip 3333333333333333333333333333333333333333\n",
+			},
+			Recorded("@" + gitPath + " git rev-list -n1 refs/heads/master --not --remotes")[0],
+			{
+				Cmd:    Recorded("@" + gitPath + " git rev-list -n1 refs/heads/wip --not --remotes")[0].Cmd,
+				Stdout: "3333333333333333333333333333333333333333\n",
+			},
+			{
+				Cmd:    recordedIn(gitPath, "git", "stash", "list", "--format=%H %gs"),
+				Stdout: "4444444444444444444444444444444444444444 WIP on master: abcdef initial commit\n",
+			},
+			Recorded("@" + gitPath + " git update-ref refs/rtree-stash/0 4444444444444444444444444444444444444444")[0],
+			Recorded("@" + gitPath + " git bundle create --quiet " + bundlePath +
+				" refs/heads/wip refs/rtree-stash/0 --not --remotes")[0],
+			Recorded("@" + gitPath + " git update-ref -d refs/rtree-stash/0")[0],
+		},
+	}.Run(t)
+
+	buf, err := os.ReadFile(filepath.Join(backupDir, "manifest.json"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var manifest BackupManifest
+	err = json.Unmarshal(buf, &manifest)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectedManifest := BackupManifest{
+		Repos: []BackupEntry{{
+			CheckoutPath: "github.com/git/git",
+			BundlePath:   "github.com/git/git.bundle",
+			Refs:         []BackupRef{{Name: "refs/heads/wip", Object: "3333333333333333333333333333333333333333"}},
+			Stashes: []BackupStash{{
+				Commit:  "4444444444444444444444444444444444444444",
+				Message: "WIP on master: abcdef initial commit",
+			}},
+		}},
+	}
+	if !reflect.DeepEqual(manifest, expectedManifest) {
+		t.Errorf("expected manifest %#v, but got %#v", expectedManifest, manifest)
+	}
+
+	//restore into a fresh clone where the branch does not exist yet
+	Test{
+		Args:        []string{"restore-backup", backupDir},
+		Index:       testIndexWithTwoRepos,
+		ExpectError: ">> " + gitPath + "\n",
+		ExpectExecution: []RecordedCommand{
+			Recorded("@" + gitPath + " git fetch --quiet " + bundlePath + " +refs/*:refs/rtree-backup/*")[0],
+			{
+				Cmd:    Recorded("@" + gitPath + " git symbolic-ref -q HEAD")[0].Cmd,
+				Stdout: "refs/heads/master\n",
+			},
+			{
+				Cmd:   Recorded("@" + gitPath + " git rev-parse -q --verify refs/heads/wip")[0].Cmd,
+				Fails: true,
+			},
+			Recorded("@" + gitPath + " git update-ref refs/heads/wip 3333333333333333333333333333333333333333")[0],
+			Recorded("@" + gitPath + " git update-ref -d refs/rtree-backup/heads/wip")[0],
+			Recorded("@" + gitPath + " git stash list --format=%H")[0],
+			{Cmd: recordedIn(gitPath, "git", "stash", "store", "-m", "WIP on master: abcdef initial commit", "4444444444444444444444444444444444444444")},
+			Recorded("@" + gitPath + " git update-ref -d refs/rtree-backup/rtree-stash/0")[0],
+		},
+	}.Run(t)
+}
+
+func TestBackupAndRestoreOnlyStashes(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	rootPath := withTemporaryRootPath(t)
+	upstreamPath := filepath.Join(t.TempDir(), "upstream")
+	mustRunGit(t, "init", "--quiet", upstreamPath)
+	mustWriteFile(t, filepath.Join(upstreamPath, "README"), "hello\n")
+	mustRunGit(t, "-C", upstreamPath, "add", "README")
+	mustCommit(t, upstreamPath, "me@example.org", "2026-01-01T00:00:00Z", "initial commit")
+
+	//all commits are pushed, so the stash is the only thing to back up
+	repoPath := filepath.Join(rootPath, "example.org/foo")
+	mustRunGit(t, "clone", "--quiet", "file://"+upstreamPath, repoPath)
+	mustWriteFile(t, filepath.Join(repoPath, "README"), "hello world\n")
+	mustRunGit(t, "-C", repoPath, "-c", "user.name=Someone", "-c", "user.email=me@example.org", "stash", "--quiet")
+	stashCommit := gitRevParse(t, repoPath, "refs/stash")
+
+	run := func(args ...string) {
+		t.Helper()
+		opts := testTreeOptions(t)
+		var stderr bytes.Buffer
+		opts.Stderr = &stderr
+		err := writeIndexFixture(opts.IndexPath, Index{Repos: []*Repo{{
+			CheckoutPath: "example.org/foo",
+			Remotes:      map[string]Remote{"origin": {URLs: []RemoteURL{RemoteURL("file://" + upstreamPath)}}},
+		}}})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		tree, err := New(opts)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		exitCode := tree.exec(args)
+		if exitCode != 0 {
+			t.Fatalf("%v: expected exit code 0, but got %d (stderr: %q)", args, exitCode, stderr.String())
+		}
+	}
+	listRefs := func() string {
+		t.Helper()
+		out, err := exec.Command("git", "-C", repoPath, "for-each-ref", "--format=%(refname)", "refs/rtree-stash", "refs/rtree-backup").Output()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return string(out)
+	}
+
+	backupDir := t.TempDir()
+	run("backup", backupDir)
+	_, err := os.Stat(filepath.Join(backupDir, "example.org/foo.bundle"))
+	if err != nil {
+		t.Errorf("expected bundle to exist, but got: %s", err.Error())
+	}
+	if refs := listRefs(); refs != "" {
+		t.Errorf("expected temporary refs to be deleted after backup, but got %q", refs)
+	}
+
+	//restore into a clone without the stash
+	mustRunGit(t, "-C", repoPath, "stash", "clear")
+	mustRunGit(t, "-C", repoPath, "gc", "--quiet", "--prune=now")
+	run("restore-backup", backupDir)
+	if actual := gitRevParse(t, repoPath, "refs/stash"); actual != stashCommit {
+		t.Errorf("expected stash %s to be restored, but got %s", stashCommit, actual)
+	}
+	if refs := listRefs(); refs != "" {
+		t.Errorf("expected temporary refs to be deleted after restore, but got %q", refs)
+	}
+}
+
+func TestBackupContinuesAfterError(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	for _, repo := range testIndexWithTwoRepos.Repos {
+		mustMkdirAll(t, filepath.Join(rootPath, repo.CheckoutPath, ".git"))
+	}
+	backupDir := t.TempDir()
+	fooPath := filepath.Join(rootPath, "github.com/foo/bar")
+	gitPath := filepath.Join(rootPath, "github.com/git/git")
+	bundlePath := filepath.Join(backupDir, "github.com/git/git.bundle")
+
+	//the failure in github.com/foo/bar is reported, but the manifest is still
+	//written for github.com/git/git
+	Test{
+		Args:          []string{"backup", backupDir},
+		Index:         testIndexWithTwoRepos,
+		ExpectFailure: true,
+		ExpectError: ">> " + gitPath + ": 1 refs, 0 stashes\n" +
+			"!! cannot back up " + fooPath + ": command \"git for-each-ref --format=%(refname) %(objectname) refs/heads refs/tags\" has failed\n",
+		ExpectExecution: []RecordedCommand{
+			{
+				Cmd:   recordedIn(fooPath, "git", "for-each-ref", "--format=%(refname) %(objectname)", "refs/heads", "refs/tags"),
+				Fails: true,
+			},
+			{
+				Cmd:    recordedIn(gitPath, "git", "for-each-ref", "--format=%(refname) %(objectname)", "refs/heads", "refs/tags"),
+				Stdout: "refs/heads/wip 3333333333333333333333333333333333333333\n",
+			},
+			{
+				Cmd:    Recorded("@" + gitPath + " git rev-list -n1 refs/heads/wip --not --remotes")[0].Cmd,
+				Stdout: "3333333333333333333333333333333333333333\n",
+			},
+			{Cmd: recordedIn(gitPath, "git", "stash", "list", "--format=%H %gs")},
+			Recorded("@" + gitPath + " git bundle create --quiet " + bundlePath + " refs/heads/wip --not --remotes")[0],
+		},
+	}.Run(t)
+
+	buf, err := os.ReadFile(filepath.Join(backupDir, "manifest.json"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var manifest BackupManifest
+	err = json.Unmarshal(buf, &manifest)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(manifest.Repos) != 1 || manifest.Repos[0].CheckoutPath != "github.com/git/git" {
+		t.Errorf("expected manifest to contain only github.com/git/git, but got %#v", manifest)
+	}
+}
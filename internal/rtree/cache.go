@@ -0,0 +1,251 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"git.xyrillian.de/gofu/internal/cli"
+)
+
+// The object cache is a single bare repo that all remotes get fetched into,
+// each into its own ref namespace below "refs/rtree/". Because there is only
+// one object store, forks of the same upstream share their objects in the
+// cache. Clones use it with `git clone --reference-if-able`.
+
+// RepoPath returns the location of the cache repo.
+func (c CacheConfig) RepoPath() (string, error) {
+	if c.Path != "" {
+		return c.Path, nil
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "rtree/objects.git"), nil
+}
+
+var refNameUnsafeCharsRx = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// cacheRefPrefix returns the ref namespace in the cache repo for the given
+// remote. Equivalent URLs (see MatchesURL) share the same namespace.
+func cacheRefPrefix(url RemoteURL) string {
+	fields := strings.Split(equivalenceKey(url.CanonicalURL()), "/")
+	for idx, field := range fields {
+		//make sure that this is a valid ref name component (see man:git-check-ref-format(1))
+		field = refNameUnsafeCharsRx.ReplaceAllString(field, "_")
+		if field == "" || strings.HasPrefix(field, ".") || strings.HasSuffix(field, ".lock") || strings.Contains(field, "..") {
+			field = "_" + strings.ReplaceAll(field, ".", "_")
+		}
+		fields[idx] = field
+	}
+	return "refs/rtree/" + strings.Join(fields, "/")
+}
+
+// prepareCacheFor fetches the given remote into the cache repo, and returns
+// the path to the cache repo for use with `git clone --reference-if-able`.
+// Returns an empty string if the cache is disabled. Errors are only reported
+// as warnings, since the clone will work without the cache, too. The fetch is
+// interrupted when the given context is canceled.
+func (t *RTree) prepareCacheFor(ctx context.Context, url RemoteURL) string {
+	if t.Config.Cache == nil {
+		return ""
+	}
+	cachePath, err := t.Config.Cache.RepoPath()
+	if err != nil {
+		t.ui.ShowWarning("cannot use object cache: " + err.Error())
+		return ""
+	}
+
+	_, err = os.Stat(cachePath)
+	if os.IsNotExist(err) {
+		err = t.ui.Run(cli.Command{
+			Program: []string{"git", "init", "--quiet", "--bare", cachePath},
+		})
+		if err == nil {
+			//only `rtree cache gc` may prune objects, since only it can check
+			//whether repos are still borrowing them (see below)
+			err = t.ui.Run(cli.Command{
+				Program: []string{"git", "config", "gc.pruneExpire", "never"},
+				WorkDir: cachePath,
+			})
+		}
+	}
+	if err == nil {
+		prefix := cacheRefPrefix(url)
+		err = t.ui.Run(cli.Command{
+			Program: []string{
+				"git", "fetch", "--quiet", "--no-tags", url.CanonicalURL(),
+				"+refs/heads/*:" + prefix + "/heads/*",
+				"+refs/tags/*:" + prefix + "/tags/*",
+			},
+			WorkDir: cachePath,
+			Context: ctx,
+		})
+	}
+	if err != nil {
+		t.ui.ShowWarning("cannot use object cache: " + err.Error())
+		return ""
+	}
+	return cachePath
+}
+
+// alternatesPath returns the path of the file that lists the object stores
+// that this repo borrows objects from.
+func (r Repo) alternatesPath() string {
+	return filepath.Join(r.GitDirPath(), "objects/info/alternates")
+}
+
+// borrowsFrom returns whether this repo borrows objects from the given repo.
+func (r Repo) borrowsFrom(otherRepoPath string) (bool, error) {
+	buf, err := os.ReadFile(r.alternatesPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	objectsPath := filepath.Join(otherRepoPath, "objects")
+	for line := range strings.SplitSeq(string(buf), "\n") {
+		if filepath.Clean(strings.TrimSpace(line)) == objectsPath {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// dissociateFrom copies all objects that this repo borrows from the given
+// repo into this repo, and stops borrowing from it.
+func (r Repo) dissociateFrom(otherRepoPath string) error {
+	err := r.tree.ui.Run(cli.Command{
+		Program: []string{"git", "repack", "-a", "-d", "-q"},
+		WorkDir: r.AbsolutePath(),
+	})
+	if err != nil {
+		return err
+	}
+
+	buf, err := os.ReadFile(r.alternatesPath())
+	if err != nil {
+		return err
+	}
+	objectsPath := filepath.Join(otherRepoPath, "objects")
+	var remaining []string
+	for line := range strings.SplitSeq(string(buf), "\n") {
+		if line != "" && filepath.Clean(strings.TrimSpace(line)) != objectsPath {
+			remaining = append(remaining, line)
+		}
+	}
+	if len(remaining) == 0 {
+		return r.tree.fs.Remove(r.alternatesPath())
+	}
+	return r.tree.fs.WriteFile(r.alternatesPath(), []byte(strings.Join(remaining, "\n")+"\n"), 0644)
+}
+
+// commandCache implements `rtree cache gc` and `rtree cache dissociate`.
+func commandCache(index *Index, subcommand string) error {
+	t := index.tree
+	if t.Config.Cache == nil {
+		return fmt.Errorf("object cache is not enabled (see `cache` in %s)", t.ConfigPath)
+	}
+	cachePath, err := t.Config.Cache.RepoPath()
+	if err != nil {
+		return err
+	}
+	_, err = os.Stat(cachePath)
+	if os.IsNotExist(err) {
+		return nil //nothing to do
+	}
+
+	//find repos that borrow objects from the cache
+	var borrowers []*Repo
+	for _, repo := range index.Repos {
+		ok, err := repo.borrowsFrom(cachePath)
+		if err != nil {
+			return err
+		}
+		if ok {
+			borrowers = append(borrowers, repo)
+		}
+	}
+
+	switch subcommand {
+	case "dissociate":
+		for _, repo := range borrowers {
+			t.ui.ShowProgress(repo.AbsolutePath())
+			err := repo.dissociateFrom(cachePath)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case "gc":
+		return gcCache(index, cachePath, len(borrowers) > 0)
+	default:
+		return fmt.Errorf("unknown subcommand: rtree cache %s", subcommand)
+	}
+}
+
+// gcCache removes all remotes from the cache that are not in the index
+// anymore, and cleans up the cache repo.
+func gcCache(index *Index, cachePath string, hasBorrowers bool) error {
+	wanted := make(map[string]bool)
+	for _, repo := range index.Repos {
+		for _, remote := range repo.Remotes {
+			for _, url := range remote.URLs {
+				wanted[cacheRefPrefix(url)] = true
+			}
+		}
+	}
+
+	out, err := index.tree.ui.CaptureStdout(cli.Command{
+		Program:  []string{"git", "for-each-ref", "--format=%(refname)", "refs/rtree/"},
+		WorkDir:  cachePath,
+		ReadOnly: true,
+	})
+	if err != nil {
+		return err
+	}
+	var commands strings.Builder
+	for refName := range strings.SplitSeq(strings.TrimSpace(out), "\n") {
+		if refName != "" && !isWantedCacheRef(refName, wanted) {
+			fmt.Fprintf(&commands, "delete %s\n", refName)
+		}
+	}
+	if commands.Len() > 0 {
+		err := index.tree.ui.RunWithInput(cli.Command{
+			Program: []string{"git", "update-ref", "--stdin"},
+			WorkDir: cachePath,
+		}, commands.String())
+		if err != nil {
+			return err
+		}
+	}
+
+	//objects that are not referenced by the cache anymore may still be
+	//needed by repos that borrow objects from it, so we may only prune
+	//when no one is borrowing
+	pruneFlag := "--prune=now"
+	if hasBorrowers {
+		pruneFlag = "--no-prune"
+	}
+	return index.tree.ui.Run(cli.Command{
+		Program: []string{"git", "gc", "--quiet", pruneFlag},
+		WorkDir: cachePath,
+	})
+}
+
+func isWantedCacheRef(refName string, wantedPrefixes map[string]bool) bool {
+	for prefix := range wantedPrefixes {
+		if strings.HasPrefix(refName, prefix+"/heads/") || strings.HasPrefix(refName, prefix+"/tags/") {
+			return true
+		}
+	}
+	return false
+}
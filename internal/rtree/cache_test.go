@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func withCache(t *testing.T, shareObjects bool) string {
+	cachePath := filepath.Join(t.TempDir(), "objects.git")
+	savedConfig := testConfig
+	testConfig = &Configuration{Cache: &CacheConfig{Path: cachePath, ShareObjects: shareObjects}}
+	t.Cleanup(func() { testConfig = savedConfig })
+	return cachePath
+}
+
+func TestCacheRefPrefix(t *testing.T) {
+	testCases := map[RemoteURL]string{
+		"https://github.com/foo/bar":        "refs/rtree/github.com/foo/bar",
+		"git@GitHub.com:foo/bar.git":        "refs/rtree/github.com/foo/bar",
+		"https://example.org/~user/.hidden": "refs/rtree/example.org/_user/__hidden",
+		"https://example.org/foo..bar.lock": "refs/rtree/example.org/_foo__bar_lock",
+		"myserver:repos/foo":                "refs/rtree/myserver/repos/foo",
+		"localhost:x":                       "refs/rtree/localhost/x",
+	}
+	for input, expected := range testCases {
+		if actual := cacheRefPrefix(input); actual != expected {
+			t.Errorf("expected cacheRefPrefix(%q) = %q, but got %q", input, expected, actual)
+		}
+	}
+}
+
+func TestGetWithCache(t *testing.T) {
+	cachePath := withCache(t, false)
+	target := filepath.Join(testRootPath, "/github.com/another/repo")
+	Test{
+		Args:         []string{"get", "gh:another/repo"},
+		Index:        testIndexWithTwoRepos,
+		ExpectOutput: target + "\n",
+		ExpectExecution: Recorded(
+			"git init --quiet --bare "+cachePath,
+			"@"+cachePath+" git config gc.pruneExpire never",
+			"@"+cachePath+" git fetch --quiet --no-tags https://github.com/another/repo"+
+				" +refs/heads/*:refs/rtree/github.com/another/repo/heads/*"+
+				" +refs/tags/*:refs/rtree/github.com/another/repo/tags/*",
+			"git clone --reference-if-able "+cachePath+" --dissociate https://github.com/another/repo "+target,
+			"@"+target+" git rev-list --max-parents=0 --all",
+		),
+		ExpectIndex: &Index{
+			Repos: []*Repo{
+				{
+					CheckoutPath: "github.com/another/repo",
+					Remotes: map[string]Remote{
+						"origin": {URLs: []RemoteURL{"https://github.com/another/repo"}},
+					},
+				},
+				testIndexWithTwoRepos.Repos[0],
+				testIndexWithTwoRepos.Repos[1],
+			},
+		},
+	}.Run(t)
+}
+
+func TestCacheDissociateAndGC(t *testing.T) {
+	cachePath := withCache(t, true)
+	rootPath := withTemporaryRootPath(t)
+	target := filepath.Join(rootPath, "github.com/git/git")
+	for _, dirPath := range []string{cachePath, filepath.Join(target, ".git/objects/info")} {
+		err := os.MkdirAll(dirPath, 0755)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	alternatesPath := filepath.Join(target, ".git/objects/info/alternates")
+	err := os.WriteFile(alternatesPath, []byte(cachePath+"/objects\n"), 0644)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	//as long as the repo borrows objects from the cache, `gc` must not prune
+	listRefs := RecordedCommand{
+		Cmd: Recorded("@" + cachePath + " git for-each-ref --format=%(refname) refs/rtree/")[0].Cmd,
+		Stdout: "refs/rtree/github.com/git/git/heads/master\n" +
+			"refs/rtree/github.com/git/git/tags/v1.0\n" +
+			"refs/rtree/github.com/gone/repo/heads/main\n",
+	}
+	Test{
+		Args:  []string{"cache", "gc"},
+		Index: testIndexWithTwoRepos,
+		ExpectExecution: append([]RecordedCommand{listRefs}, Recorded(
+			"@"+cachePath+" git update-ref --stdin",
+			"@"+cachePath+" git gc --quiet --no-prune",
+		)...),
+	}.Run(t)
+
+	Test{
+		Args:            []string{"cache", "dissociate"},
+		Index:           testIndexWithTwoRepos,
+		ExpectError:     ">> " + target + "\n",
+		ExpectExecution: Recorded("@" + target + " git repack -a -d -q"),
+	}.Run(t)
+	_, err = os.Stat(alternatesPath)
+	if !os.IsNotExist(err) {
+		t.Errorf("expected alternates file to be removed, but got err = %v", err)
+	}
+
+	//afterwards, pruning is allowed
+	Test{
+		Args:  []string{"cache", "gc"},
+		Index: testIndexWithTwoRepos,
+		ExpectExecution: append([]RecordedCommand{listRefs}, Recorded(
+			"@"+cachePath+" git update-ref --stdin",
+			"@"+cachePath+" git gc --quiet --prune=now",
+		)...),
+	}.Run(t)
+}
@@ -0,0 +1,295 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"git.xyrillian.de/gofu/internal/cli"
+)
+
+// RemoteStatus is the result of checking whether a remote URL still works.
+type RemoteStatus int
+
+const (
+	//RemoteOK means that the remote could be queried.
+	RemoteOK RemoteStatus = iota
+	//RemoteMoved means that the remote could be queried, but redirected us to
+	//a different URL (e.g. because the repo was renamed).
+	RemoteMoved
+	//RemoteAuthFailed means that the remote requires credentials that we do
+	//not have. Some forges report deleted or private repos this way.
+	RemoteAuthFailed
+	//RemoteNotFound means that the server exists, but the repo does not.
+	RemoteNotFound
+	//RemoteUnreachable covers all other errors, including timeouts.
+	RemoteUnreachable
+)
+
+// String implements the fmt.Stringer interface.
+func (s RemoteStatus) String() string {
+	switch s {
+	case RemoteOK:
+		return "ok"
+	case RemoteMoved:
+		return "moved"
+	case RemoteAuthFailed:
+		return "authentication failed"
+	case RemoteNotFound:
+		return "not found"
+	default:
+		return "unreachable"
+	}
+}
+
+// RemoteCheck is the result of checking one remote URL of an indexed repo.
+type RemoteCheck struct {
+	Repo       *Repo
+	RemoteName string
+	URL        RemoteURL
+	Status     RemoteStatus
+	//For RemoteMoved, the URL that the remote redirects to.
+	NewURL RemoteURL
+	//For failures, the error message reported by Git.
+	Message string
+}
+
+// unattendedGitEnv is given to Git commands that talk to remotes in the
+// background, where there is no one to answer a credential prompt.
+var unattendedGitEnv = []string{"GIT_TERMINAL_PROMPT=0", "GIT_SSH_COMMAND=ssh -o BatchMode=yes"}
+
+const (
+	remoteCheckTimeout     = 30 * time.Second
+	remoteCheckConcurrency = 8
+)
+
+// CheckRemotes runs `git ls-remote` for each remote URL in the index. Each
+// URL is only checked once, even if it appears in multiple repos. The results
+// are in the order of the index. Mercurial repos are skipped since their
+// remotes cannot be queried by Git.
+func (i *Index) CheckRemotes() []RemoteCheck {
+	var checks []RemoteCheck
+	for _, repo := range i.Repos {
+		if repo.VCS == VCSMercurial {
+			continue
+		}
+		for _, remoteName := range sortedRemoteNames(repo.Remotes) {
+			for _, url := range repo.Remotes[remoteName].URLs {
+				checks = append(checks, RemoteCheck{Repo: repo, RemoteName: remoteName, URL: url})
+			}
+		}
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mutex     sync.Mutex
+		results   = make(map[RemoteURL]RemoteCheck)
+		semaphore = make(chan struct{}, remoteCheckConcurrency)
+		started   = make(map[RemoteURL]bool)
+	)
+	for _, c := range checks {
+		if started[c.URL] {
+			continue
+		}
+		started[c.URL] = true
+		wg.Add(1)
+		go func(url RemoteURL) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			result := i.tree.checkRemoteURL(url)
+			<-semaphore
+			mutex.Lock()
+			results[url] = result
+			mutex.Unlock()
+		}(c.URL)
+	}
+	wg.Wait()
+
+	for idx, c := range checks {
+		result := results[c.URL]
+		checks[idx].Status = result.Status
+		checks[idx].NewURL = result.NewURL
+		checks[idx].Message = result.Message
+	}
+	return checks
+}
+
+// checkRemoteURL queries a single remote URL. Only the fields Status, NewURL
+// and Message of the result are filled.
+func (t *RTree) checkRemoteURL(url RemoteURL) RemoteCheck {
+	_, stderr, err := t.ui.CaptureOutput(cli.Command{
+		Program:  []string{"git", "ls-remote", url.CanonicalURL(), "HEAD"},
+		Env:      unattendedGitEnv,
+		Timeout:  remoteCheckTimeout,
+		ReadOnly: true,
+	})
+	result := classifyRemoteCheck(stderr, err)
+	if result.Status == RemoteMoved {
+		result.NewURL = ParseRemoteURL(string(result.NewURL), t.RemoteAliases)
+	}
+	return result
+}
+
+var (
+	gitRedirectRx         = regexp.MustCompile(`(?m)^warning: redirecting to (\S+)$`)
+	remoteNotFoundRx      = regexp.MustCompile(`(?i)repository not found|repository '.*' not found|does not appear to be a git repository|does not exist|returned error: 404`)
+	remoteAuthFailedRx    = regexp.MustCompile(`(?i)authentication failed|could not read (username|password)|permission denied|terminal prompts disabled|returned error: 40[13]`)
+	remoteUninterestingRx = regexp.MustCompile(`(?i)^fatal: could not read from remote repository\.$|^please make sure you have the correct access rights|^and the repository exists\.$`)
+)
+
+// classifyRemoteCheck interprets the result of `git ls-remote`.
+func classifyRemoteCheck(stderr string, err error) RemoteCheck {
+	if err == nil {
+		match := gitRedirectRx.FindStringSubmatch(stderr)
+		if match == nil {
+			return RemoteCheck{Status: RemoteOK}
+		}
+		//Git reports the new base URL with a trailing slash
+		return RemoteCheck{Status: RemoteMoved, NewURL: RemoteURL(strings.TrimSuffix(match[1], "/"))}
+	}
+
+	result := RemoteCheck{Status: RemoteUnreachable, Message: gitErrorMessage(stderr, err)}
+	switch {
+	case errors.Is(err, cli.ErrTimeout):
+		result.Message = fmt.Sprintf("no response within %s", remoteCheckTimeout)
+	case remoteNotFoundRx.MatchString(stderr):
+		result.Status = RemoteNotFound
+	case remoteAuthFailedRx.MatchString(stderr):
+		result.Status = RemoteAuthFailed
+	}
+	return result
+}
+
+// gitErrorMessage extracts the most useful line from Git's stderr, or falls
+// back to the error if Git did not say anything useful.
+func gitErrorMessage(stderr string, err error) string {
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	for _, line := range slices.Backward(lines) {
+		line = strings.TrimSpace(line)
+		if line != "" && !remoteUninterestingRx.MatchString(line) {
+			return line
+		}
+	}
+	return err.Error()
+}
+
+// commandCheckRemotes implements `rtree check-remotes`. Like `rtree doctor`,
+// it exits non-zero if problems remain, so that it can be run from cron.
+func commandCheckRemotes(index *Index) int {
+	exitCode := 0
+	indexChanged := false
+	for _, c := range index.CheckRemotes() {
+		if c.Status == RemoteOK {
+			continue
+		}
+		msg := fmt.Sprintf("remote %q of %s (%s): %s", c.RemoteName, c.Repo.AbsolutePath(), c.URL.CompactURL(index.tree.RemoteAliases), c.Status)
+		switch c.Status {
+		case RemoteMoved:
+			msg += " to " + c.NewURL.CompactURL(index.tree.RemoteAliases)
+		default:
+			msg += ": " + c.Message
+		}
+		index.tree.ui.ShowWarning(msg)
+
+		var (
+			question string
+			fix      func() error
+		)
+		switch c.Status {
+		case RemoteMoved:
+			question = ">> Update the remote URL?"
+			fix = func() error { return c.Repo.replaceRemoteURL(c.RemoteName, c.URL, c.NewURL) }
+		case RemoteNotFound, RemoteAuthFailed:
+			if len(c.Repo.Remotes) == 1 && len(c.Repo.Remotes[c.RemoteName].URLs) == 1 {
+				//a repo without remotes cannot be indexed
+				index.tree.ui.ShowWarning("this is the only remote of the repo; use `rtree drop` to remove the repo instead")
+				exitCode = 1
+				continue
+			}
+			question = ">> Drop the remote URL?"
+			fix = func() error { return c.Repo.replaceRemoteURL(c.RemoteName, c.URL, "") }
+		default:
+			//might be a temporary network problem, so there is nothing to fix
+			exitCode = 1
+			continue
+		}
+
+		ok, err := index.tree.ui.Confirm(question)
+		if err != nil {
+			index.tree.ui.ShowError(err.Error())
+			return 1
+		}
+		if !ok {
+			exitCode = 1
+			continue
+		}
+		err = fix()
+		if err != nil {
+			index.tree.ui.ShowError(err.Error())
+			exitCode = 1
+			continue
+		}
+		indexChanged = true
+	}
+
+	if indexChanged {
+		err := index.Write()
+		if err != nil {
+			index.tree.ui.ShowError(err.Error())
+			return 1
+		}
+	}
+	return exitCode
+}
+
+// replaceRemoteURL replaces the given URL of the given remote with a new URL,
+// both in the repo and in this index entry. If the new URL is empty, the old
+// URL is removed instead, and the remote is removed entirely if it does not
+// have any URLs left.
+func (r *Repo) replaceRemoteURL(remoteName string, oldURL, newURL RemoteURL) error {
+	remote := r.Remotes[remoteName]
+	b := r.backend()
+	repoPath := r.AbsolutePath()
+
+	var err error
+	switch {
+	case len(remote.URLs) == 1 && newURL == "":
+		err = b.RemoveRemote(repoPath, remoteName)
+	case len(remote.URLs) == 1:
+		err = b.SetRemoteURL(repoPath, remoteName, newURL.CanonicalURL())
+	default:
+		if newURL != "" {
+			err = b.AddRemoteURL(repoPath, remoteName, newURL.CanonicalURL())
+		}
+		if err == nil {
+			err = b.DeleteRemoteURL(repoPath, remoteName, oldURL.CanonicalURL())
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	var urls []RemoteURL
+	for _, url := range remote.URLs {
+		switch {
+		case url != oldURL:
+			urls = append(urls, url)
+		case newURL != "":
+			urls = append(urls, newURL)
+		}
+	}
+	if len(urls) == 0 {
+		delete(r.Remotes, remoteName)
+	} else {
+		remote.URLs = urls
+		r.Remotes[remoteName] = remote
+	}
+	return nil
+}
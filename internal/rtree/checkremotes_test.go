@@ -0,0 +1,232 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"git.xyrillian.de/gofu/internal/cli"
+)
+
+func TestClassifyRemoteCheck(t *testing.T) {
+	failed := errors.New("exit status 128")
+	testCases := []struct {
+		Stderr   string
+		Err      error
+		Expected RemoteCheck
+	}{
+		{"", nil, RemoteCheck{Status: RemoteOK}},
+		{
+			"warning: redirecting to https://github.com/new/name.git/\n", nil,
+			RemoteCheck{Status: RemoteMoved, NewURL: "https://github.com/new/name.git"},
+		},
+		{
+			"ERROR: Repository not found.\nfatal: Could not read from remote repository.\n\nPlease make sure you have the correct access rights\nand the repository exists.\n", failed,
+			RemoteCheck{Status: RemoteNotFound, Message: "ERROR: Repository not found."},
+		},
+		{
+			"fatal: could not read Username for 'https://github.com': terminal prompts disabled\n", failed,
+			RemoteCheck{Status: RemoteAuthFailed, Message: "fatal: could not read Username for 'https://github.com': terminal prompts disabled"},
+		},
+		{
+			"fatal: unable to access 'https://example.org/foo/': Could not resolve host: example.org\n", failed,
+			RemoteCheck{Status: RemoteUnreachable, Message: "fatal: unable to access 'https://example.org/foo/': Could not resolve host: example.org"},
+		},
+		{"", cli.ErrTimeout, RemoteCheck{Status: RemoteUnreachable, Message: "no response within 30s"}},
+	}
+
+	for _, tc := range testCases {
+		actual := classifyRemoteCheck(tc.Stderr, tc.Err)
+		if actual != tc.Expected {
+			t.Errorf("expected %#v for stderr %q, but got %#v", tc.Expected, tc.Stderr, actual)
+		}
+	}
+}
+
+// mustRunGit runs an actual Git command, for tests that cannot be done with
+// the CommandSimulator.
+func mustRunGit(t *testing.T, args ...string) {
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %s: %s", strings.Join(args, " "), err.Error(), out)
+	}
+}
+
+func TestCheckRemotesWithLocalRemotes(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	rootPath := withTemporaryRootPath(t)
+	remotesPath := t.TempDir()
+	aliveURL := RemoteURL("file://" + filepath.Join(remotesPath, "alive.git"))
+	deadURL := RemoteURL("file://" + filepath.Join(remotesPath, "dead.git"))
+	mustRunGit(t, "init", "--quiet", "--bare", filepath.Join(remotesPath, "alive.git"))
+
+	//one repo where the dead remote can be dropped, and one where it is the
+	//only remote
+	fooPath := filepath.Join(rootPath, "example.org/foo")
+	mustRunGit(t, "init", "--quiet", fooPath)
+	mustRunGit(t, "-C", fooPath, "remote", "add", "origin", string(aliveURL))
+	mustRunGit(t, "-C", fooPath, "remote", "add", "dead", string(deadURL))
+	barPath := filepath.Join(rootPath, "example.org/bar")
+	mustRunGit(t, "init", "--quiet", barPath)
+	mustRunGit(t, "-C", barPath, "remote", "add", "origin", string(deadURL))
+
+	opts := testTreeOptions(t)
+	opts.Stdin = strings.NewReader("true\n")
+	var stderr bytes.Buffer
+	opts.Stderr = &stderr
+	err := writeIndexFixture(opts.IndexPath, Index{Repos: []*Repo{
+		{
+			CheckoutPath: "example.org/bar",
+			Remotes:      map[string]Remote{"origin": {URLs: []RemoteURL{deadURL}}},
+		},
+		{
+			CheckoutPath: "example.org/foo",
+			Remotes: map[string]Remote{
+				"origin": {URLs: []RemoteURL{aliveURL}},
+				"dead":   {URLs: []RemoteURL{deadURL}},
+			},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tree, err := New(opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	index, errs := tree.readIndex()
+	if len(errs) > 0 {
+		t.Fatal(errs[0].Error())
+	}
+
+	checks := index.CheckRemotes()
+	var statuses []RemoteStatus
+	for _, c := range checks {
+		statuses = append(statuses, c.Status)
+	}
+	expectedStatuses := []RemoteStatus{RemoteNotFound, RemoteNotFound, RemoteOK}
+	if !slices.Equal(statuses, expectedStatuses) {
+		t.Fatalf("expected statuses %v, but got %v", expectedStatuses, statuses)
+	}
+
+	//the problem with example.org/bar cannot be fixed, so the exit code is nonzero
+	exitCode := commandCheckRemotes(index)
+	if exitCode != 1 {
+		t.Errorf("expected exit code 1, but got %d (stderr: %q)", exitCode, stderr.String())
+	}
+	remotes, err := readGitRemotes(fooPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectedRemotes := [][2]string{{"origin", string(aliveURL)}}
+	if !slices.Equal(remotes, expectedRemotes) {
+		t.Errorf("expected remotes %q in %s, but got %q", expectedRemotes, fooPath, remotes)
+	}
+	index, errs = tree.readIndex()
+	if len(errs) > 0 {
+		t.Fatal(errs[0].Error())
+	}
+	if _, exists := index.Repos[1].Remotes["dead"]; exists || len(index.Repos[1].Remotes) != 1 {
+		t.Errorf("expected remote \"dead\" to be dropped from the index, but got %#v", index.Repos[1].Remotes)
+	}
+}
+
+func TestCheckRemotesTimeout(t *testing.T) {
+	Test{
+		Args: []string{"check-remotes"},
+		Index: Index{Repos: []*Repo{{
+			CheckoutPath: "github.com/foo/bar",
+			Remotes:      map[string]Remote{"origin": {URLs: []RemoteURL{"https://github.com/foo/bar"}}},
+		}}},
+		ExpectFailure: true,
+		ExpectError:   "!! remote \"origin\" of " + filepath.Join(testRootPath, "github.com/foo/bar") + " (gh:foo/bar): unreachable: no response within 30s\n",
+		ExpectExecution: []RecordedCommand{{
+			Cmd: cli.Command{
+				Program: []string{"git", "ls-remote", "https://github.com/foo/bar", "HEAD"},
+				Env:     unattendedGitEnv,
+				Timeout: remoteCheckTimeout,
+			},
+			TimesOut: true,
+		}},
+	}.Run(t)
+}
+
+func TestReplaceRemoteURLWithTwoURLs(t *testing.T) {
+	repoPath := filepath.Join(testRootPath, "github.com/foo/bar")
+	index := Index{Repos: []*Repo{{
+		CheckoutPath: "github.com/foo/bar",
+		Remotes: map[string]Remote{
+			"origin": {URLs: []RemoteURL{"https://github.com/foo/bar", "https://github.com/foo/old"}},
+		},
+	}}}
+
+	//the commands must use the URLs as written in the repo's config, not the
+	//compact form with aliases (i.e. not "gh:foo/old")
+	cs := &CommandSimulator{Cmd: []RecordedCommand{
+		Recorded("@" + repoPath + " git remote set-url --add origin https://github.com/foo/new")[0],
+		Recorded("@" + repoPath + " git remote set-url --delete origin https://github.com/foo/old")[0],
+		Recorded("@" + repoPath + " git remote set-url --delete origin https://github.com/foo/new")[0],
+	}}
+	tree := newTestTree(t, index, cs)
+	loaded, errs := tree.readIndex()
+	if len(errs) > 0 {
+		t.Fatal(errs[0].Error())
+	}
+	repo := loaded.Repos[0]
+
+	err := repo.replaceRemoteURL("origin", "https://github.com/foo/old", "https://github.com/foo/new")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectedURLs := []RemoteURL{"https://github.com/foo/bar", "https://github.com/foo/new"}
+	if urls := repo.Remotes["origin"].URLs; !slices.Equal(urls, expectedURLs) {
+		t.Errorf("expected URLs %q after move, but got %q", expectedURLs, urls)
+	}
+
+	err = repo.replaceRemoteURL("origin", "https://github.com/foo/new", "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expectedURLs = []RemoteURL{"https://github.com/foo/bar"}
+	if urls := repo.Remotes["origin"].URLs; !slices.Equal(urls, expectedURLs) {
+		t.Errorf("expected URLs %q after drop, but got %q", expectedURLs, urls)
+	}
+}
+
+func TestReplaceRemoteURLUnsupported(t *testing.T) {
+	index := Index{Repos: []*Repo{{
+		CheckoutPath: "hg.example.org/foo",
+		Remotes: map[string]Remote{
+			"origin": {URLs: []RemoteURL{"https://hg.example.org/foo"}},
+			"mirror": {URLs: []RemoteURL{"https://mirror.example.org/foo"}},
+		},
+		VCS: VCSMercurial,
+	}}}
+	tree := newTestTree(t, index, &CommandSimulator{})
+	loaded, errs := tree.readIndex()
+	if len(errs) > 0 {
+		t.Fatal(errs[0].Error())
+	}
+	repo := loaded.Repos[0]
+
+	//the hgrc cannot be edited, so the index entry must stay as it is
+	for _, newURL := range []RemoteURL{"https://hg.example.org/bar", ""} {
+		err := repo.replaceRemoteURL("mirror", "https://mirror.example.org/foo", newURL)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("expected an unsupported-operation error for new URL %q, but got %v", newURL, err)
+		}
+		expectedURLs := []RemoteURL{"https://mirror.example.org/foo"}
+		if urls := repo.Remotes["mirror"].URLs; !slices.Equal(urls, expectedURLs) {
+			t.Errorf("expected URLs %q to be unchanged, but got %q", expectedURLs, urls)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"git.xyrillian.de/gofu/internal/forge"
+)
+
+// Configuration represents the contents of the config file. All settings in
+// it are optional; a missing config file is equivalent to an empty one.
+type Configuration struct {
+	//Forges maps hostnames to the forge APIs available on these hosts.
+	Forges map[string]ForgeConfig `json:"forges,omitempty"`
+	//Hooks are applied to repos after they have been cloned.
+	Hooks []HookConfig `json:"hooks,omitempty"`
+	//Cache enables the local object cache for clones, if given.
+	Cache *CacheConfig `json:"cache,omitempty"`
+	//Scan controls how the repository tree is searched for repos.
+	Scan ScanConfig `json:"scan,omitzero"`
+}
+
+// ForgeConfig describes how to access the API of a forge.
+type ForgeConfig struct {
+	//Type is one of "github", "gitea", "forgejo" or "gitlab".
+	Type string `json:"type"`
+	//APIURL only needs to be given if the API is not in the default location
+	//(e.g. "https://api.github.com" for GitHub, or "https://$HOST/api/v4" for
+	//GitLab).
+	APIURL string `json:"api_url,omitempty"`
+	//Token is the API token. If not given, the token is read from the
+	//environment variable named by TokenEnv, or from $GITHUB_TOKEN,
+	//$GITEA_TOKEN or $GITLAB_TOKEN depending on Type.
+	Token    string `json:"token,omitempty"`
+	TokenEnv string `json:"token_env,omitempty"`
+}
+
+// HookConfig describes actions that are taken after cloning repos whose origin
+// URL matches either the URL pattern or the host.
+type HookConfig struct {
+	//URL is a glob pattern (see path.Match) that is matched against the
+	//origin URL, in both its canonical and its compact form.
+	URL string `json:"url,omitempty"`
+	//Host is matched against the hostname of the origin URL.
+	Host string `json:"host,omitempty"`
+	//GitConfig contains keys and values that are set in the repo's .git/config.
+	GitConfig map[string]string `json:"git_config,omitempty"`
+	//Commands are executed with `sh -c` in the repo. The environment
+	//variables $RTREE_CHECKOUT_PATH and $RTREE_REMOTE_URL contain the
+	//absolute path of the repo and its canonical origin URL.
+	Commands []string `json:"commands,omitempty"`
+}
+
+// CacheConfig configures the local object cache that speeds up clones.
+type CacheConfig struct {
+	//Path is where the cache repo is located. The default is
+	//"$XDG_CACHE_HOME/rtree/objects.git".
+	Path string `json:"path,omitempty"`
+	//If ShareObjects is false (the default), clones copy all objects that they
+	//need from the cache, so the cache only saves network traffic, but can be
+	//deleted at any time. If true, clones keep borrowing objects from the
+	//cache to save disk space as well. In this case, the cache must not be
+	//deleted before running `rtree cache dissociate`.
+	ShareObjects bool `json:"share_objects,omitempty"`
+}
+
+// ScanConfig controls how `rtree index` searches the repository tree for repos.
+type ScanConfig struct {
+	//Ignore contains glob patterns (see path.Match) for directories that are
+	//not searched for repos, e.g. "node_modules". Patterns without a slash are
+	//matched against the directory name, others against the path relative to
+	//the root path.
+	Ignore []string `json:"ignore,omitempty"`
+	//MaxDepth is the number of path elements below the root path up to which
+	//repos are searched, e.g. 3 if all repos are at paths like
+	//"github.com/owner/repo". If zero, there is no limit.
+	MaxDepth int `json:"max_depth,omitempty"`
+}
+
+// ReadConfig reads the config file at the given path.
+func ReadConfig(configPath string) (*Configuration, error) {
+	buf, err := os.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Configuration{}, nil
+		}
+		return nil, err
+	}
+
+	var cfg Configuration
+	err = json.Unmarshal(buf, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", configPath, err)
+	}
+	for host, fc := range cfg.Forges {
+		if fc.Type == "" {
+			return nil, fmt.Errorf("read %s: missing \"forges[%q].type\"", configPath, host)
+		}
+	}
+	for idx, hc := range cfg.Hooks {
+		if hc.URL == "" && hc.Host == "" {
+			return nil, fmt.Errorf("read %s: missing \"hooks[%d].url\" or \"hooks[%d].host\"", configPath, idx, idx)
+		}
+		if _, err := path.Match(hc.URL, ""); err != nil {
+			return nil, fmt.Errorf("read %s: invalid pattern in \"hooks[%d].url\": %w", configPath, idx, err)
+		}
+	}
+	for idx, pattern := range cfg.Scan.Ignore {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("read %s: invalid pattern in \"scan.ignore[%d]\": %w", configPath, idx, err)
+		}
+	}
+	if cfg.Scan.MaxDepth < 0 {
+		return nil, fmt.Errorf("read %s: invalid value for \"scan.max_depth\": %d", configPath, cfg.Scan.MaxDepth)
+	}
+	return &cfg, nil
+}
+
+// forgeClient returns a client for the forge API on the given host, or nil if
+// no forge is configured for this host.
+func (c Configuration) forgeClient(host string) (forge.Client, error) {
+	fc, ok := c.Forges[strings.ToLower(host)]
+	if !ok {
+		return nil, nil
+	}
+
+	token := fc.Token
+	if token == "" {
+		envName := fc.TokenEnv
+		if envName == "" {
+			switch fc.Type {
+			case "forgejo":
+				envName = "GITEA_TOKEN"
+			default:
+				envName = strings.ToUpper(fc.Type) + "_TOKEN"
+			}
+		}
+		token = os.Getenv(envName)
+	}
+	return forge.NewClient(fc.Type, host, fc.APIURL, token)
+}
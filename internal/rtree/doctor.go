@@ -0,0 +1,393 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DoctorProblem is an inconsistency in the index or the repository tree that
+// was found by `rtree doctor`.
+type DoctorProblem struct {
+	Message string
+	//If not empty, the problem can be fixed automatically by calling Fix().
+	//FixDescription is used when asking the user for confirmation.
+	FixDescription string
+	Fix            func() error
+}
+
+// doctor holds the state of one `rtree doctor` run.
+type doctor struct {
+	Index    *Index
+	Problems []DoctorProblem
+	//index entries that are not checked further (because they are duplicates)
+	skipped map[*Repo]bool
+	//results of the filesystem scan
+	physicalRepos map[string]bool //keys are paths relative to RootPath
+	directories   []string        //relative to RootPath
+	symlinks      []string        //relative to RootPath
+	//directories that are not searched for repos (see ScanConfig)
+	skippedDirectories []string //relative to RootPath
+}
+
+func (d *doctor) report(p DoctorProblem) {
+	d.Problems = append(d.Problems, p)
+}
+
+// Diagnose checks the index and the repository tree for inconsistencies.
+// Index validation errors (as returned by readIndex) are reported as problems
+// as well, so that a broken index can be repaired by `rtree doctor --fix`.
+func (i *Index) Diagnose(validationErrs []error) ([]DoctorProblem, error) {
+	d := doctor{
+		Index:         i,
+		skipped:       make(map[*Repo]bool),
+		physicalRepos: make(map[string]bool),
+	}
+	for _, err := range validationErrs {
+		d.report(DoctorProblem{Message: err.Error()})
+	}
+
+	err := d.scanTree()
+	if err != nil {
+		return nil, err
+	}
+	d.checkDuplicates()
+	d.checkNestedRepos()
+	for _, repo := range i.Repos {
+		if d.skipped[repo] {
+			continue
+		}
+		err := d.checkRepo(repo)
+		if err != nil {
+			return nil, err
+		}
+	}
+	d.checkSymlinks()
+	d.checkNonRepoDirectories()
+	return d.Problems, nil
+}
+
+// scanTree collects all repos, directories and symlinks below RootPath.
+// Unlike ForeachPhysicalRepo(), this does not inspect the repos themselves.
+// Like ForeachPhysicalRepo(), it does not look into directories that are
+// excluded by the ScanConfig.
+func (d *doctor) scanTree() error {
+	rootPath := d.Index.tree.RootPath
+	config := d.Index.tree.Config.Scan
+	err := filepath.WalkDir(rootPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(rootPath, path)
+		if err != nil || relPath == "." {
+			return err
+		}
+
+		switch {
+		case entry.Type()&fs.ModeSymlink != 0:
+			d.symlinks = append(d.symlinks, relPath)
+		case entry.IsDir():
+			depth := strings.Count(relPath, "/") + 1
+			if config.isIgnored(relPath) || !config.descendsFrom(depth-1) {
+				d.skippedDirectories = append(d.skippedDirectories, relPath)
+				return filepath.SkipDir
+			}
+			if _, isRepo := detectVCS(path); isRepo {
+				d.physicalRepos[relPath] = true
+				return filepath.SkipDir
+			}
+			d.directories = append(d.directories, relPath)
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		//no tree yet -> nothing to check
+		return nil
+	}
+	return err
+}
+
+func (d *doctor) checkDuplicates() {
+	seen := make(map[string]*Repo)
+	for _, repo := range d.Index.Repos {
+		first, exists := seen[repo.CheckoutPath]
+		if !exists {
+			seen[repo.CheckoutPath] = repo
+			continue
+		}
+		d.report(DoctorProblem{
+			Message:        fmt.Sprintf("repo %s appears multiple times in the index", repo.AbsolutePath()),
+			FixDescription: "remove the duplicate index entry",
+			Fix: func() error {
+				//keep the remotes of both entries; the next `rtree index` will
+				//sort out which of them actually exist
+				for remoteName, remote := range repo.Remotes {
+					if _, exists := first.Remotes[remoteName]; !exists {
+						if first.Remotes == nil {
+							first.Remotes = make(map[string]Remote)
+						}
+						first.Remotes[remoteName] = remote
+					}
+				}
+				d.Index.removeEntry(repo)
+				return nil
+			},
+		})
+		//do not run any further checks on the duplicate
+		d.skipped[repo] = true
+	}
+
+	for _, pair := range d.Index.sameOriginPairs() {
+		d.report(DoctorProblem{
+			Message: fmt.Sprintf("repos %s and %s are checkouts of the same origin %s",
+				pair[0].AbsolutePath(), pair[1].AbsolutePath(), pair[0].Remotes["origin"].URLs[0].CompactURL(d.Index.tree.RemoteAliases)),
+		})
+	}
+}
+
+func (d *doctor) checkNestedRepos() {
+	for _, repo := range d.Index.Repos {
+		for _, other := range d.Index.Repos {
+			if strings.HasPrefix(other.CheckoutPath, repo.CheckoutPath+"/") {
+				d.report(DoctorProblem{
+					Message: fmt.Sprintf("repo %s is nested inside repo %s", other.AbsolutePath(), repo.AbsolutePath()),
+				})
+			}
+		}
+	}
+}
+
+func (d *doctor) checkRepo(repo *Repo) error {
+	_, err := os.Stat(repo.MetadataDirPath())
+	existsOnDisk := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var actualRepo Repo
+	if existsOnDisk {
+		actualRepo, err = repo.tree.NewRepoFromAbsolutePath(repo.AbsolutePath(), true)
+		if err != nil {
+			return err
+		}
+	}
+
+	//check for index entries with empty remote maps
+	if len(repo.Remotes) == 0 {
+		if len(actualRepo.Remotes) > 0 {
+			d.report(DoctorProblem{
+				Message:        fmt.Sprintf("index entry for %s has no remotes", repo.AbsolutePath()),
+				FixDescription: "take the remotes from .git/config",
+				Fix: func() error {
+					repo.Remotes = actualRepo.Remotes
+					return nil
+				},
+			})
+		} else {
+			d.report(DoctorProblem{
+				Message:        fmt.Sprintf("index entry for %s has no remotes", repo.AbsolutePath()),
+				FixDescription: "remove the index entry",
+				Fix: func() error {
+					d.Index.removeEntry(repo)
+					return nil
+				},
+			})
+		}
+		return nil
+	}
+
+	//check whether the checkout path matches the origin
+	if origin, ok := repo.Remotes["origin"]; ok && len(origin.URLs) > 0 {
+		expectedPath, err := origin.URLs[0].CheckoutPath()
+		if err == nil && expectedPath != repo.CheckoutPath {
+			p := DoctorProblem{
+				Message: fmt.Sprintf("repo %s should be at %s according to its origin %s",
+					repo.AbsolutePath(), filepath.Join(repo.tree.RootPath, expectedPath), origin.URLs[0].CompactURL(repo.tree.RemoteAliases)),
+			}
+			if existsOnDisk {
+				p.FixDescription = "move the repo to " + filepath.Join(repo.tree.RootPath, expectedPath)
+				p.Fix = func() error { return repo.Move(expectedPath, false) }
+			}
+			d.report(p)
+		}
+	}
+
+	//check whether the remotes on disk match the index
+	if existsOnDisk {
+		diffs := diffRemotes(repo.Remotes, actualRepo.Remotes, repo.tree.RemoteAliases)
+		if len(diffs) > 0 {
+			d.report(DoctorProblem{
+				Message: fmt.Sprintf("remotes of %s differ between index and .git/config:\n\t%s",
+					repo.AbsolutePath(), strings.Join(diffs, "\n\t")),
+				FixDescription: "update the index from .git/config",
+				Fix: func() error {
+					repo.Remotes = actualRepo.Remotes
+					return nil
+				},
+			})
+		}
+	}
+	return nil
+}
+
+// checkSymlinks looks for compatibility symlinks below RootPath, e.g. those
+// left behind by Repo.Move(), and for the ones that `rtree import` left at the
+// original location of imported repos.
+func (d *doctor) checkSymlinks() {
+	rootPath := d.Index.tree.RootPath
+	for _, relPath := range d.symlinks {
+		absPath := filepath.Join(rootPath, relPath)
+		target, err := filepath.EvalSymlinks(absPath)
+		var message string
+		switch {
+		case err != nil:
+			message = fmt.Sprintf("%s is a dangling symlink", absPath)
+		case d.physicalRepos[strings.TrimPrefix(target, rootPath+"/")]:
+			message = fmt.Sprintf("%s is a compatibility symlink to the repo at %s", absPath, target)
+		default:
+			continue
+		}
+		d.report(DoctorProblem{
+			Message:        message,
+			FixDescription: "remove the symlink",
+			Fix:            func() error { return d.Index.tree.fs.Remove(absPath) },
+		})
+	}
+
+	for _, repo := range d.Index.Repos {
+		if repo.ImportedFrom == "" || d.skipped[repo] {
+			continue
+		}
+		//if the symlink has been removed or replaced by something else, it is
+		//none of our business anymore
+		target, err := os.Readlink(repo.ImportedFrom)
+		if err != nil || target != repo.AbsolutePath() {
+			continue
+		}
+		d.report(DoctorProblem{
+			Message:        fmt.Sprintf("%s is a compatibility symlink to the repo at %s (left behind by `rtree import`)", repo.ImportedFrom, target),
+			FixDescription: "remove the symlink",
+			Fix: func() error {
+				err := d.Index.tree.fs.Remove(repo.ImportedFrom)
+				if err != nil {
+					return err
+				}
+				repo.ImportedFrom = ""
+				return nil
+			},
+		})
+	}
+}
+
+// checkNonRepoDirectories looks for directories below RootPath that do not
+// contain any repos. Only the topmost such directory is reported.
+func (d *doctor) checkNonRepoDirectories() {
+	//find all directories that lead to a repo (or to a skipped directory,
+	//whose contents we do not know)
+	leadsToRepo := make(map[string]bool)
+	markParents := func(relPath string) {
+		for dir := filepath.Dir(relPath); dir != "."; dir = filepath.Dir(dir) {
+			leadsToRepo[dir] = true
+		}
+	}
+	for repoPath := range d.physicalRepos {
+		markParents(repoPath)
+	}
+	for _, dirPath := range d.skippedDirectories {
+		markParents(dirPath)
+	}
+
+	for _, relPath := range d.directories {
+		parent := filepath.Dir(relPath)
+		if leadsToRepo[relPath] || (parent != "." && !leadsToRepo[parent]) {
+			continue
+		}
+		absPath := filepath.Join(d.Index.tree.RootPath, relPath)
+		p := DoctorProblem{Message: fmt.Sprintf("%s is not a repo and does not contain any repos", absPath)}
+		if isEmptyDirTree(absPath) {
+			p.Message = fmt.Sprintf("%s is an empty directory", absPath)
+			p.FixDescription = "remove the directory"
+			p.Fix = func() error { return d.Index.tree.fs.RemoveAll(absPath) }
+		}
+		d.report(p)
+	}
+}
+
+// isEmptyDirTree returns whether the given directory contains nothing but
+// (possibly) other empty directories.
+func isEmptyDirTree(path string) bool {
+	isEmpty := true
+	err := filepath.WalkDir(path, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			isEmpty = false
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return err == nil && isEmpty
+}
+
+// commandDoctor implements `rtree doctor`. The exit code is non-zero if any
+// problems remain unfixed, to allow for usage in cronjobs.
+func commandDoctor(index *Index, validationErrs []error, fix bool) int {
+	problems, err := index.Diagnose(validationErrs)
+	if err != nil {
+		index.tree.ui.ShowError(err.Error())
+		return 1
+	}
+
+	exitCode := 0
+	indexChanged := false
+	for _, p := range problems {
+		index.tree.ui.ShowWarning(p.Message)
+		if !fix || p.Fix == nil {
+			exitCode = 1
+			continue
+		}
+
+		ok, err := index.tree.ui.Confirm(fmt.Sprintf(">> Fix this problem (%s)?", p.FixDescription))
+		if err != nil {
+			index.tree.ui.ShowError(err.Error())
+			return 1
+		}
+		if !ok {
+			exitCode = 1
+			continue
+		}
+		err = p.Fix()
+		if err != nil {
+			index.tree.ui.ShowError(err.Error())
+			exitCode = 1
+			continue
+		}
+		indexChanged = true
+	}
+
+	if indexChanged {
+		err := index.Write()
+		if err != nil {
+			index.tree.ui.ShowError(err.Error())
+			return 1
+		}
+	}
+	return exitCode
+}
+
+// removeEntry removes the given entry from the index. Unlike DropRepo(), the
+// repo is not deleted from disk, and other entries with the same CheckoutPath
+// are retained.
+func (i *Index) removeEntry(repo *Repo) {
+	repos := make([]*Repo, 0, len(i.Repos))
+	for _, r := range i.Repos {
+		if r != repo {
+			repos = append(repos, r)
+		}
+	}
+	i.Repos = repos
+}
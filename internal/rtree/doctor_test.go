@@ -0,0 +1,184 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDoctor(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	for _, dirPath := range []string{"github.com/foo/bar/.git", "old/git/.git", "github.com/empty/dir", "stuff"} {
+		err := os.MkdirAll(filepath.Join(rootPath, dirPath), 0755)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	err := os.WriteFile(filepath.Join(rootPath, "stuff/notes.txt"), nil, 0644)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	index := Index{
+		Repos: []*Repo{
+			testIndexWithTwoRepos.Repos[0],
+			{
+				CheckoutPath: "old/git",
+				Remotes: map[string]Remote{
+					"origin": {URLs: []RemoteURL{"https://github.com/git/git"}},
+				},
+			},
+		},
+	}
+	configOf := func(relPath, url string) RecordedCommand {
+		return RecordedCommand{
+			Cmd:    Recorded("@" + filepath.Join(rootPath, relPath) + " git config -l")[0].Cmd,
+			Stdout: "remote.origin.url=" + url + "\n",
+		}
+	}
+
+	//without --fix, problems are only reported
+	Test{
+		Args:          []string{"doctor"},
+		Index:         index,
+		ExpectFailure: true,
+		ExpectError: "!! repo " + rootPath + "/old/git should be at " + rootPath + "/github.com/git/git according to its origin gh:git/git\n" +
+			"!! " + rootPath + "/github.com/empty is an empty directory\n" +
+			"!! " + rootPath + "/stuff is not a repo and does not contain any repos\n",
+		ExpectExecution: []RecordedCommand{
+			configOf("github.com/foo/bar", "https://github.com/foo/bar"),
+			configOf("old/git", "https://github.com/git/git"),
+		},
+	}.Run(t)
+
+	//with --fix, fixable problems are fixed after confirmation
+	Test{
+		Args:          []string{"doctor", "--fix"},
+		Index:         index,
+		Input:         "1\n1\n",
+		ExpectFailure: true,
+		ExpectError: "!! repo " + rootPath + "/old/git should be at " + rootPath + "/github.com/git/git according to its origin gh:git/git\n" +
+			">> Fix this problem (move the repo to " + rootPath + "/github.com/git/git)? 1\n" +
+			">> Fix this problem (move the repo to " + rootPath + "/github.com/git/git)? -> true (1)\n" +
+			"!! " + rootPath + "/github.com/empty is an empty directory\n" +
+			">> Fix this problem (remove the directory)? 1\n" +
+			">> Fix this problem (remove the directory)? -> true (1)\n" +
+			"!! " + rootPath + "/stuff is not a repo and does not contain any repos\n",
+		ExpectExecution: []RecordedCommand{
+			configOf("github.com/foo/bar", "https://github.com/foo/bar"),
+			configOf("old/git", "https://github.com/git/git"),
+		},
+		ExpectIndex: &testIndexWithTwoRepos,
+	}.Run(t)
+
+	for _, dirPath := range []string{"github.com/git/git/.git", "stuff"} {
+		_, err := os.Stat(filepath.Join(rootPath, dirPath))
+		if err != nil {
+			t.Error(err.Error())
+		}
+	}
+	_, err = os.Stat(filepath.Join(rootPath, "github.com/empty"))
+	if !os.IsNotExist(err) {
+		t.Errorf("expected github.com/empty to be deleted, but got err = %v", err)
+	}
+}
+
+func TestDoctorHonorsScanConfig(t *testing.T) {
+	savedConfig := testConfig
+	testConfig = &Configuration{Scan: ScanConfig{Ignore: []string{"node_modules", "example.org/build"}, MaxDepth: 3}}
+	t.Cleanup(func() { testConfig = savedConfig })
+
+	rootPath := withTemporaryRootPath(t)
+	for _, dirPath := range []string{
+		"github.com/foo/bar/.git",
+		"github.com/foo/node_modules/dep/.git", //ignored by name
+		"node_modules/stuff",                   //ignored by name
+		"example.org/build/tmp/.git",           //ignored by path
+		"example.org/a/b/too-deep/.git",        //beyond MaxDepth
+		"example.org/stray",
+	} {
+		mustMkdirAll(t, filepath.Join(rootPath, dirPath))
+	}
+
+	//ignored directories are neither scanned for repos nor reported as stray
+	//directories (and neither are their parents)
+	Test{
+		Args:          []string{"doctor"},
+		Index:         Index{Repos: []*Repo{testIndexWithTwoRepos.Repos[0]}},
+		ExpectFailure: true,
+		ExpectError:   "!! " + rootPath + "/example.org/stray is an empty directory\n",
+		ExpectExecution: []RecordedCommand{{
+			Cmd:    Recorded("@" + filepath.Join(rootPath, "github.com/foo/bar") + " git config -l")[0].Cmd,
+			Stdout: "remote.origin.url=https://github.com/foo/bar\n",
+		}},
+	}.Run(t)
+}
+
+func TestDoctorWithImportSymlink(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	oldPath := filepath.Join(t.TempDir(), "imported")
+	mustWriteFile(t, filepath.Join(oldPath, ".git/config"), "[remote \"origin\"]\n\turl = https://github.com/foo/imported\n")
+	newPath := filepath.Join(rootPath, "github.com/foo/imported")
+
+	imported := &Repo{
+		CheckoutPath: "github.com/foo/imported",
+		Remotes: map[string]Remote{
+			"origin": {URLs: []RemoteURL{"https://github.com/foo/imported"}},
+		},
+		ImportedFrom: oldPath,
+	}
+	Test{
+		Args:        []string{"import", oldPath},
+		Index:       Index{Repos: []*Repo{}},
+		ExpectIndex: &Index{Repos: []*Repo{imported}},
+	}.Run(t)
+
+	configOf := RecordedCommand{
+		Cmd:    Recorded("@" + newPath + " git config -l")[0].Cmd,
+		Stdout: "remote.origin.url=https://github.com/foo/imported\n",
+	}
+	Test{
+		Args:  []string{"doctor", "--fix"},
+		Index: Index{Repos: []*Repo{imported}},
+		Input: "1\n",
+		ExpectError: "!! " + oldPath + " is a compatibility symlink to the repo at " + newPath + " (left behind by `rtree import`)\n" +
+			">> Fix this problem (remove the symlink)? 1\n" +
+			">> Fix this problem (remove the symlink)? -> true (1)\n",
+		ExpectExecution: []RecordedCommand{configOf},
+		ExpectIndex: &Index{Repos: []*Repo{{
+			CheckoutPath: imported.CheckoutPath,
+			Remotes:      imported.Remotes,
+		}}},
+	}.Run(t)
+
+	_, err := os.Lstat(oldPath)
+	if !os.IsNotExist(err) {
+		t.Errorf("expected %s to be deleted, but got err = %v", oldPath, err)
+	}
+
+	//once the symlink is gone, there is nothing left to report
+	Test{
+		Args:            []string{"doctor"},
+		Index:           Index{Repos: []*Repo{imported}},
+		ExpectExecution: []RecordedCommand{configOf},
+	}.Run(t)
+}
+
+func TestDoctorWithBrokenIndex(t *testing.T) {
+	withTemporaryRootPath(t)
+	repo := &Repo{CheckoutPath: "github.com/foo/bar"}
+	Test{
+		Args:          []string{"doctor", "--fix"},
+		Index:         Index{Repos: []*Repo{repo}},
+		Input:         "1\n",
+		ExpectFailure: true,
+		ExpectError: "!! read " + filepath.Join(indexTmpDir, t.Name()+".json") + ": missing \"repos[0].remotes\"\n" +
+			"!! index entry for " + filepath.Join(testRootPath, repo.CheckoutPath) + " has no remotes\n" +
+			">> Fix this problem (remove the index entry)? 1\n" +
+			">> Fix this problem (remove the index entry)? -> true (1)\n",
+		ExpectIndex: &Index{Repos: []*Repo{}},
+	}.Run(t)
+}
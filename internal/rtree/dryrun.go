@@ -0,0 +1,260 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"git.xyrillian.de/gofu/internal/cli"
+)
+
+// fileSystem performs all filesystem operations that change the repository
+// tree or rtree's own files, so that they can be skipped in dry-run mode.
+// Reading from the filesystem does not go through here.
+type fileSystem interface {
+	MkdirAll(path string, perm os.FileMode) error
+	Rename(oldPath, newPath string) error
+	Remove(path string) error
+	RemoveAll(path string) error
+	Symlink(oldPath, newPath string) error
+	WriteFile(path string, data []byte, perm os.FileMode) error
+	//ReplaceFile is like WriteFile, but the file is replaced atomically, so
+	//that readers never see it half-written.
+	ReplaceFile(path string, data []byte, perm os.FileMode) error
+	AppendFile(path string, data []byte, perm os.FileMode) error
+}
+
+// osFileSystem implements fileSystem by actually performing the operations.
+type osFileSystem struct{}
+
+func (osFileSystem) MkdirAll(path string, perm os.FileMode) error { return os.MkdirAll(path, perm) }
+func (osFileSystem) Rename(oldPath, newPath string) error         { return os.Rename(oldPath, newPath) }
+func (osFileSystem) Remove(path string) error                     { return os.Remove(path) }
+func (osFileSystem) RemoveAll(path string) error                  { return os.RemoveAll(path) }
+func (osFileSystem) Symlink(oldPath, newPath string) error        { return os.Symlink(oldPath, newPath) }
+
+func (osFileSystem) WriteFile(path string, data []byte, perm os.FileMode) error {
+	return os.WriteFile(path, data, perm)
+}
+
+func (osFileSystem) ReplaceFile(path string, data []byte, perm os.FileMode) error {
+	tmpPath := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	err := os.WriteFile(tmpPath, data, perm)
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	return err
+}
+
+func (osFileSystem) AppendFile(path string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// dryRunFileSystem implements fileSystem by reporting the operations instead
+// of performing them.
+type dryRunFileSystem struct {
+	ui *cli.Implementation
+}
+
+func (fs dryRunFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	//most calls are just making sure that a parent directory exists, so only
+	//report the ones that would actually do something
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		fs.ui.ShowProgress("would create directory " + path)
+	}
+	return nil
+}
+
+func (fs dryRunFileSystem) Rename(oldPath, newPath string) error {
+	fs.ui.ShowProgress(fmt.Sprintf("would move %s to %s", oldPath, newPath))
+	return nil
+}
+
+func (fs dryRunFileSystem) Remove(path string) error {
+	fs.ui.ShowProgress("would delete " + path)
+	return nil
+}
+
+func (fs dryRunFileSystem) RemoveAll(path string) error {
+	fs.ui.ShowProgress("would delete " + path)
+	return nil
+}
+
+func (fs dryRunFileSystem) Symlink(oldPath, newPath string) error {
+	fs.ui.ShowProgress(fmt.Sprintf("would create symlink %s -> %s", newPath, oldPath))
+	return nil
+}
+
+func (fs dryRunFileSystem) WriteFile(path string, data []byte, perm os.FileMode) error {
+	fs.ui.ShowProgress("would write " + path)
+	return nil
+}
+
+func (fs dryRunFileSystem) ReplaceFile(path string, data []byte, perm os.FileMode) error {
+	fs.ui.ShowProgress("would write " + path)
+	return nil
+}
+
+func (fs dryRunFileSystem) AppendFile(path string, data []byte, perm os.FileMode) error {
+	fs.ui.ShowProgress("would append to " + path)
+	return nil
+}
+
+// enableDryRun implements the `--dry-run` flag: All commands that are not
+// marked as read-only, and all changes to the filesystem, are reported on
+// stderr instead of being executed. Changes to the index are reported as a
+// list of added, removed and changed entries (see Index.reportChanges).
+func (t *RTree) enableDryRun() {
+	if t.dryRun {
+		return
+	}
+	t.dryRun = true
+	t.fs = dryRunFileSystem{t.ui}
+	t.ui.WrapCommandRunner(func(next cli.CommandRunner) cli.CommandRunner {
+		return cli.DryRunCommandRunner(next, func(c cli.Command) {
+			msg := fmt.Sprintf("would run `%s`", strings.Join(c.Program, " "))
+			if c.WorkDir != "" {
+				msg += " in " + c.WorkDir
+			}
+			t.ui.ShowProgress(msg)
+		})
+	})
+}
+
+// reportChanges is used by Write() in dry-run mode. Instead of writing the
+// index file, it reports which entries would be added, removed or changed
+// compared to the index file on disk. For changed entries, a diff of their
+// JSON representation is shown.
+func (i *Index) reportChanges() error {
+	var old Index
+	buf, err := os.ReadFile(i.tree.IndexPath)
+	switch {
+	case err == nil:
+		err = json.Unmarshal(buf, &old)
+		if err != nil {
+			return fmt.Errorf("cannot compare with %s: %w", i.tree.IndexPath, err)
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	oldRepos := make(map[string]*Repo, len(old.Repos))
+	for _, repo := range old.Repos {
+		//like in readIndex, to compare URLs in the same form
+		for _, remote := range repo.Remotes {
+			for idx, url := range remote.URLs {
+				remote.URLs[idx] = ParseRemoteURL(string(url), i.tree.RemoteAliases)
+			}
+		}
+		oldRepos[repo.CheckoutPath] = repo
+	}
+	isIndexed := make(map[string]bool, len(i.Repos))
+	for _, repo := range i.Repos {
+		isIndexed[repo.CheckoutPath] = true
+		oldRepo, exists := oldRepos[repo.CheckoutPath]
+		if !exists {
+			i.tree.ui.ShowProgress(fmt.Sprintf("would add %s to the index", repo.CheckoutPath))
+			continue
+		}
+		oldBuf, err := json.MarshalIndent(oldRepo, "", "  ")
+		if err != nil {
+			return err
+		}
+		newBuf, err := json.MarshalIndent(repo, "", "  ")
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(oldBuf, newBuf) {
+			diff := diffLines(strings.Split(string(oldBuf), "\n"), strings.Split(string(newBuf), "\n"))
+			i.tree.ui.ShowProgress(fmt.Sprintf("would update %s in the index:\n%s", repo.CheckoutPath, strings.Join(diff, "\n")))
+		}
+	}
+	for _, repo := range old.Repos {
+		if !isIndexed[repo.CheckoutPath] {
+			i.tree.ui.ShowProgress(fmt.Sprintf("would remove %s from the index", repo.CheckoutPath))
+		}
+	}
+	return nil
+}
+
+// diffLinesContext is how many unchanged lines diffLines shows around each
+// change.
+const diffLinesContext = 2
+
+// diffLines returns a line diff in the style of `diff -u` (without hunk
+// headers): removed lines are prefixed with "-", added lines with "+", and
+// unchanged lines near a change with " ". Omitted unchanged lines are shown
+// as a single "...". This is meant for small inputs like single index
+// entries, so it uses the plain quadratic LCS algorithm.
+func diffLines(oldLines, newLines []string) []string {
+	//lcs[i][j] is the length of the longest common subsequence of
+	//oldLines[i:] and newLines[j:]
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var all []string
+	i, j := 0, 0
+	for i < len(oldLines) || j < len(newLines) {
+		switch {
+		case i < len(oldLines) && j < len(newLines) && oldLines[i] == newLines[j]:
+			all = append(all, "  "+oldLines[i])
+			i++
+			j++
+		case j == len(newLines) || (i < len(oldLines) && lcs[i+1][j] >= lcs[i][j+1]):
+			all = append(all, "- "+oldLines[i])
+			i++
+		default:
+			all = append(all, "+ "+newLines[j])
+			j++
+		}
+	}
+
+	//only keep unchanged lines that are close to a change
+	isChange := func(idx int) bool { return idx >= 0 && idx < len(all) && all[idx][0] != ' ' }
+	var result []string
+	elided := false
+	for idx, line := range all {
+		keep := false
+		for offset := -diffLinesContext; offset <= diffLinesContext; offset++ {
+			keep = keep || isChange(idx+offset)
+		}
+		switch {
+		case keep:
+			result = append(result, line)
+			elided = false
+		case !elided:
+			result = append(result, "  ...")
+			elided = true
+		}
+	}
+	return result
+}
@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDryRunGet(t *testing.T) {
+	target := filepath.Join(testRootPath, "github.com/another/repo")
+	Test{
+		Args:         []string{"--dry-run", "get", "gh:another/repo"},
+		Index:        testIndexWithTwoRepos,
+		ExpectOutput: target + "\n",
+		ExpectError: ">> would run `git clone https://github.com/another/repo " + target + "`\n" +
+			">> would add github.com/another/repo to the index\n" +
+			">> would write " + filepath.Join(indexTmpDir, t.Name()+".history.json") + "\n",
+	}.Run(t)
+}
+
+func TestDryRunDrop(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	repoPath := filepath.Join(rootPath, "github.com/git/git")
+	mustMkdirAll(t, filepath.Join(repoPath, ".git"))
+
+	Test{
+		Args:  []string{"--dry-run", "drop", "gh:git/git"},
+		Input: "true\n",
+		Index: testIndexWithTwoRepos,
+		ExpectError: ">> " + repoPath + "\n" +
+			">> Drop this repo? true\n" +
+			">> Drop this repo? -> true (true)\n" +
+			">> would delete " + repoPath + "\n" +
+			">> would remove github.com/git/git from the index\n",
+		//the status command still runs since it does not change anything
+		ExpectExecution: Recorded("@" + repoPath + " git status"),
+	}.Run(t)
+
+	_, err := os.Stat(repoPath)
+	if err != nil {
+		t.Errorf("expected %s to still exist, but got: %s", repoPath, err.Error())
+	}
+}
+
+func TestDryRunImport(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	oldPath := filepath.Join(t.TempDir(), "imported")
+	mustWriteFile(t, filepath.Join(oldPath, ".git/config"), "[remote \"origin\"]\n\turl = https://github.com/foo/imported\n")
+	newPath := filepath.Join(rootPath, "github.com/foo/imported")
+
+	Test{
+		Args:  []string{"--dry-run", "import", oldPath},
+		Index: testIndexWithTwoRepos,
+		ExpectError: ">> would create directory " + filepath.Dir(newPath) + "\n" +
+			">> would move " + oldPath + " to " + newPath + "\n" +
+			">> would create symlink " + oldPath + " -> " + newPath + "\n" +
+			">> would add github.com/foo/imported to the index\n",
+	}.Run(t)
+
+	fi, err := os.Lstat(oldPath)
+	if err != nil || !fi.IsDir() {
+		t.Errorf("expected %s to still be a directory, but got %v (err = %v)", oldPath, fi, err)
+	}
+}
+
+func TestDryRunIndex(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	repoPath := filepath.Join(rootPath, "github.com/foo/new")
+	mustWriteFile(t, filepath.Join(repoPath, ".git/config"), "[remote \"origin\"]\n\turl = gh:foo/new\n")
+
+	Test{
+		Args:  []string{"--dry-run", "index"},
+		Index: Index{Repos: []*Repo{}},
+		ExpectError: ">> would run `git remote set-url origin https://github.com/foo/new` in " + repoPath + "\n" +
+			">> would add github.com/foo/new to the index\n",
+		ExpectExecution: []RecordedCommand{{
+			Cmd:    Recorded("@" + repoPath + " git rev-list --max-parents=0 --all")[0].Cmd,
+			Stdout: "1234567890123456789012345678901234567890\n",
+		}},
+	}.Run(t)
+}
+
+func TestDryRunIndexShowsChanges(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	repoPath := filepath.Join(rootPath, "github.com/foo/bar")
+	mustWriteFile(t, filepath.Join(repoPath, ".git/config"),
+		"[remote \"origin\"]\n\turl = https://github.com/foo/bar\n"+
+			"[remote \"upstream\"]\n\turl = https://github.com/upstream/bar\n")
+
+	Test{
+		Args: []string{"--dry-run", "index"},
+		Index: Index{Repos: []*Repo{{
+			CheckoutPath: "github.com/foo/bar",
+			Remotes: map[string]Remote{
+				"origin": {URLs: []RemoteURL{"https://github.com/foo/bar"}},
+			},
+		}}},
+		ExpectError: ">> would update github.com/foo/bar in the index:\n" +
+			"  ...\n" +
+			"          \"https://github.com/foo/bar\"\n" +
+			"        ]\n" +
+			"+     },\n" +
+			"+     \"upstream\": {\n" +
+			"+       \"urls\": [\n" +
+			"+         \"https://github.com/upstream/bar\"\n" +
+			"+       ]\n" +
+			"      }\n" +
+			"    }\n" +
+			"  ...\n",
+		ExpectExecution: []RecordedCommand{{
+			Cmd: Recorded("@" + repoPath + " git rev-list --max-parents=0 --all")[0].Cmd,
+		}},
+	}.Run(t)
+}
@@ -0,0 +1,220 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"cmp"
+	"context"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"git.xyrillian.de/gofu/internal/cli"
+)
+
+// Scores for the evidence that makes an indexed repo a fork candidate.
+const (
+	forkScoreSameBasename  = 1
+	forkScoreSharedHistory = 10
+)
+
+// The fork probe is only a hint, so we do not wait for slow remotes. The
+// fetch downloads all commits of the remote, so it gets more time.
+const (
+	forkProbeListTimeout  = 30 * time.Second
+	forkProbeFetchTimeout = 2 * time.Minute
+)
+
+// ForkCandidate is an indexed repo that might be a fork of (or the upstream
+// for) a remote that is about to be cloned.
+type ForkCandidate struct {
+	Repo          *Repo
+	Score         int
+	SharedHistory bool
+}
+
+// FindForkCandidates looks for indexed repos that could be forks of the given
+// remote because they have a remote with the same basename, ranked by
+// evidence (highest score first) and then by frecency. If any candidates have
+// cached root commits, the root commits of the remote are probed to confirm or
+// reject them, so that unrelated repos that happen to be called "docs" or
+// "website" are not offered. (The probe downloads all commits of the remote,
+// so it is skipped when there is nothing to compare. Renamed forks are found
+// after cloning instead, see findSharedHistory.)
+//
+// The root commits of the remote are returned as well, to avoid having to
+// look them up again after cloning.
+func (i *Index) FindForkCandidates(remoteURL RemoteURL) (candidates []ForkCandidate, rootCommits []string) {
+	basename := urlBasename(remoteURL)
+	for _, repo := range i.Repos {
+		if hasRemoteWithBasename(repo, basename) {
+			candidates = append(candidates, ForkCandidate{Repo: repo, Score: forkScoreSameBasename})
+		}
+	}
+	if !slices.ContainsFunc(candidates, func(c ForkCandidate) bool { return len(c.Repo.RootCommits) > 0 }) {
+		return i.rankForkCandidates(candidates), nil
+	}
+
+	rootCommits = i.tree.probeRootCommits(remoteURL)
+	if len(rootCommits) > 0 {
+		confirmed := candidates[:0]
+		for _, c := range candidates {
+			if len(c.Repo.RootCommits) > 0 {
+				if !sharesAny(rootCommits, c.Repo.RootCommits) {
+					//histories are known to be unrelated
+					continue
+				}
+				c.Score += forkScoreSharedHistory
+				c.SharedHistory = true
+			}
+			confirmed = append(confirmed, c)
+		}
+		candidates = confirmed
+	}
+	return i.rankForkCandidates(candidates), rootCommits
+}
+
+// findSharedHistory returns the indexed repos that share a root commit with
+// the given repo (which must have its RootCommits filled), ranked by frecency.
+func (i *Index) findSharedHistory(repo *Repo) []ForkCandidate {
+	var candidates []ForkCandidate
+	for _, other := range i.Repos {
+		if other != repo && sharesAny(repo.RootCommits, other.RootCommits) {
+			candidates = append(candidates, ForkCandidate{Repo: other, Score: forkScoreSharedHistory, SharedHistory: true})
+		}
+	}
+	return i.rankForkCandidates(candidates)
+}
+
+// rankForkCandidates sorts by evidence first, and by frecency among
+// candidates with the same evidence.
+func (i *Index) rankForkCandidates(candidates []ForkCandidate) []ForkCandidate {
+	//a broken history file is not worth failing over
+	h, err := i.tree.readHistory()
+	if err != nil {
+		i.tree.ui.ShowWarning(err.Error())
+	}
+	now := time.Now()
+	slices.SortStableFunc(candidates, func(left, right ForkCandidate) int {
+		return cmp.Or(
+			cmp.Compare(right.Score, left.Score),
+			cmp.Compare(h.frecency(right.Repo.CheckoutPath, now), h.frecency(left.Repo.CheckoutPath, now)),
+		)
+	})
+	return candidates
+}
+
+func hasRemoteWithBasename(repo *Repo, basename string) bool {
+	for _, remote := range repo.Remotes {
+		for _, url := range remote.URLs {
+			if urlBasename(url) == basename {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// urlBasename returns the last path element of the URL, without ".git" suffix.
+func urlBasename(u RemoteURL) string {
+	return path.Base(strings.TrimSuffix(strings.TrimRight(u.CanonicalURL(), "/"), ".git"))
+}
+
+func sharesAny(left, right []string) bool {
+	for _, item := range left {
+		if slices.Contains(right, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// probeRootCommits finds the root commit(s) of the remote's HEAD without
+// cloning it. Since the root commits are at the far end of a shallow history,
+// we cannot use `--depth` here; instead we do a treeless partial fetch, which
+// downloads only commit objects. Returns nil if the remote cannot be probed
+// (e.g. because it needs credentials or does not answer quickly enough).
+func (t *RTree) probeRootCommits(remoteURL RemoteURL) []string {
+	//check that the remote is reachable and not empty before setting up the
+	//fetch (the clone that follows will report any errors)
+	out, err := t.ui.CaptureStdout(cli.Command{
+		Program:  []string{"git", "ls-remote", remoteURL.CanonicalURL(), "HEAD"},
+		Env:      unattendedGitEnv,
+		Timeout:  forkProbeListTimeout,
+		ReadOnly: true,
+	})
+	if err != nil || strings.TrimSpace(out) == "" {
+		return nil
+	}
+
+	//the probe repo is temporary, so the commands below count as read-only
+	//(fork detection works the same in dry-run mode); it needs a unique name
+	//since API users may probe concurrently
+	probePath, err := os.MkdirTemp("", "rtree-fork-probe-*.git")
+	if err != nil {
+		return nil
+	}
+	defer os.RemoveAll(probePath)
+	err = t.ui.Run(cli.Command{
+		Program:  []string{"git", "init", "--quiet", "--bare", probePath},
+		ReadOnly: true,
+	})
+	if err != nil {
+		return nil
+	}
+	err = t.ui.Run(cli.Command{
+		Program:  []string{"git", "fetch", "--quiet", "--filter=tree:0", remoteURL.CanonicalURL(), "HEAD"},
+		WorkDir:  probePath,
+		Env:      unattendedGitEnv,
+		Timeout:  forkProbeFetchTimeout,
+		ReadOnly: true,
+	})
+	if err != nil {
+		return nil
+	}
+	out, err = t.ui.CaptureStdout(cli.Command{
+		Program:  []string{"git", "rev-list", "--max-parents=0", "FETCH_HEAD"},
+		WorkDir:  probePath,
+		ReadOnly: true,
+	})
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(out)
+}
+
+// LoadRootCommits fills the RootCommits field by looking at the checkout. The
+// command is interrupted when the given context is canceled.
+// This is only supported for repos with a .git directory.
+func (r *Repo) LoadRootCommits(ctx context.Context) error {
+	if !r.HasGitDir() {
+		return nil
+	}
+	out, err := r.tree.ui.CaptureStdout(cli.Command{
+		Program:  []string{"git", "rev-list", "--max-parents=0", "--all"},
+		WorkDir:  r.AbsolutePath(),
+		Context:  ctx,
+		ReadOnly: true,
+	})
+	if err != nil {
+		return err
+	}
+	r.RootCommits = mergeRootCommits(nil, strings.Fields(out))
+	return nil
+}
+
+// mergeRootCommits returns the sorted union of both lists.
+func mergeRootCommits(left, right []string) []string {
+	var result []string
+	for _, commit := range append(slices.Clone(left), right...) {
+		if !slices.Contains(result, commit) {
+			result = append(result, commit)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
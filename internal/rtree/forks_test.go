@@ -0,0 +1,182 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testIndexWithRootCommits = Index{
+	Repos: []*Repo{
+		{
+			CheckoutPath: "github.com/foo/docs",
+			Remotes: map[string]Remote{
+				"origin": {URLs: []RemoteURL{"https://github.com/foo/docs"}},
+			},
+			RootCommits: []string{"1111111111111111111111111111111111111111"},
+		},
+		{
+			CheckoutPath: "github.com/git/git",
+			Remotes: map[string]Remote{
+				"origin": {URLs: []RemoteURL{"https://github.com/git/git"}},
+			},
+			RootCommits: []string{"e83c5163316f89bfbde7d9ab23ca2e25604af290"},
+		},
+	},
+}
+
+// recordedProbe returns the commands executed by probeRootCommits().
+func recordedProbe(url string, rootCommits ...string) []RecordedCommand {
+	probePath := filepath.Join(os.TempDir(), "rtree-fork-probe-*.git")
+	cmds := Recorded(
+		"git ls-remote "+url+" HEAD",
+		"git init --quiet --bare "+probePath,
+		"@"+probePath+" git fetch --quiet --filter=tree:0 "+url+" HEAD",
+		"@"+probePath+" git rev-list --max-parents=0 FETCH_HEAD",
+	)
+	cmds[0].Cmd.Env = unattendedGitEnv
+	cmds[0].Cmd.Timeout = forkProbeListTimeout
+	cmds[0].Stdout = "0123456789012345678901234567890123456789\tHEAD\n"
+	cmds[2].Cmd.Env = unattendedGitEnv
+	cmds[2].Cmd.Timeout = forkProbeFetchTimeout
+	for _, commit := range rootCommits {
+		cmds[3].Stdout += commit + "\n"
+	}
+	for idx := range cmds[1:] {
+		cmds[idx+1].Glob = true
+	}
+	return cmds
+}
+
+func TestGetRenamedForkBySharedHistory(t *testing.T) {
+	//there is no candidate with the same basename, so the remote is not
+	//probed; the shared history is only noticed after cloning
+	target := filepath.Join(testRootPath, "/github.com/git/git")
+	clonePath := filepath.Join(testRootPath, "/example.com/my-git-fork")
+	cmds := Recorded(
+		"git clone https://example.com/my-git-fork "+clonePath,
+		"@"+clonePath+" git rev-list --max-parents=0 --all",
+		"@"+target+" git remote add myfork https://example.com/my-git-fork",
+		"@"+target+" git remote update myfork",
+	)
+	cmds[1].Stdout = "e83c5163316f89bfbde7d9ab23ca2e25604af290\n"
+
+	Test{
+		Args:         []string{"get", "https://example.com/my-git-fork"},
+		Index:        testIndexWithRootCommits,
+		Input:        fmt.Sprintf("add as remote to %s (shared history)\nmyfork\n", target),
+		ExpectOutput: target + "\n",
+		ExpectError: fmt.Sprintf(
+			"The new clone shares history with other repos. What to do? -> add as remote to %s (shared history)\n"+
+				"Existing remotes:\n\t(origin) gh:git/git\n"+
+				"Enter remote name for https://example.com/my-git-fork: myfork\n",
+			target,
+		),
+		ExpectExecution: cmds,
+		ExpectIndex: &Index{
+			Repos: []*Repo{
+				testIndexWithRootCommits.Repos[0],
+				{
+					CheckoutPath: "github.com/git/git",
+					Remotes: map[string]Remote{
+						"origin": {URLs: []RemoteURL{"https://github.com/git/git"}},
+						"myfork": {URLs: []RemoteURL{"https://example.com/my-git-fork"}},
+					},
+					RootCommits: []string{"e83c5163316f89bfbde7d9ab23ca2e25604af290"},
+				},
+			},
+		},
+	}.Run(t)
+}
+
+func TestGetUnrelatedRepoWithSameBasename(t *testing.T) {
+	target := filepath.Join(testRootPath, "/example.com/docs")
+	Test{
+		Args:         []string{"get", "https://example.com/docs"},
+		Index:        testIndexWithRootCommits,
+		ExpectOutput: target + "\n",
+		ExpectExecution: append(
+			recordedProbe("https://example.com/docs", "2222222222222222222222222222222222222222"),
+			Recorded("git clone https://example.com/docs "+target)...,
+		),
+		ExpectIndex: &Index{
+			Repos: []*Repo{
+				{
+					CheckoutPath: "example.com/docs",
+					Remotes: map[string]Remote{
+						"origin": {URLs: []RemoteURL{"https://example.com/docs"}},
+					},
+					RootCommits: []string{"2222222222222222222222222222222222222222"},
+				},
+				testIndexWithRootCommits.Repos[0],
+				testIndexWithRootCommits.Repos[1],
+			},
+		},
+	}.Run(t)
+}
+
+func TestGetWithUnreachableProbe(t *testing.T) {
+	target := filepath.Join(testRootPath, "/example.com/docs")
+	probe := recordedProbe("https://example.com/docs")[0]
+	probe.Stdout = ""
+	probe.TimesOut = true
+
+	//when the remote does not answer the probe, the same-basename candidate
+	//is offered since we cannot tell whether the histories are related
+	Test{
+		Args:         []string{"get", "https://example.com/docs"},
+		Index:        testIndexWithRootCommits,
+		Input:        "clone to " + target + "\n",
+		ExpectOutput: target + "\n",
+		ExpectError:  "Found possible fork candidates. What to do? -> clone to " + target + "\n",
+		ExpectExecution: append(
+			[]RecordedCommand{probe},
+			Recorded(
+				"git clone https://example.com/docs "+target,
+				"@"+target+" git rev-list --max-parents=0 --all",
+			)...,
+		),
+		ExpectIndex: &Index{
+			Repos: []*Repo{
+				{
+					CheckoutPath: "example.com/docs",
+					Remotes: map[string]Remote{
+						"origin": {URLs: []RemoteURL{"https://example.com/docs"}},
+					},
+				},
+				testIndexWithRootCommits.Repos[0],
+				testIndexWithRootCommits.Repos[1],
+			},
+		},
+	}.Run(t)
+}
+
+func TestIndexWithoutRootCommits(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	repoPath := filepath.Join(rootPath, "github.com/foo/empty")
+	mustWriteFile(t, filepath.Join(repoPath, ".git/config"), "[remote \"origin\"]\n\turl = https://github.com/foo/empty\n")
+
+	//an empty repo has no root commits yet, which must not fail the whole rebuild
+	Test{
+		Args:  []string{"index"},
+		Index: Index{Repos: []*Repo{}},
+		ExpectError: "!! cannot find root commits of " + repoPath +
+			": command \"git rev-list --max-parents=0 --all\" has failed\n",
+		ExpectExecution: []RecordedCommand{
+			{
+				Cmd:   Recorded("@" + repoPath + " git rev-list --max-parents=0 --all")[0].Cmd,
+				Fails: true,
+			},
+		},
+		ExpectIndex: &Index{Repos: []*Repo{{
+			CheckoutPath: "github.com/foo/empty",
+			Remotes: map[string]Remote{
+				"origin": {URLs: []RemoteURL{"https://github.com/foo/empty"}},
+			},
+		}}},
+	}.Run(t)
+}
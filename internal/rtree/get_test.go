@@ -5,6 +5,7 @@ package rtree
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"testing"
 )
@@ -30,7 +31,7 @@ func TestGetExistingRepo(t *testing.T) {
 	Test{
 		Args:         []string{"get", "gh:git/git"},
 		Index:        testIndexWithTwoRepos,
-		ExpectOutput: filepath.Join(RootPath, "/github.com/git/git") + "\n",
+		ExpectOutput: filepath.Join(testRootPath, "/github.com/git/git") + "\n",
 	}.Run(t)
 }
 
@@ -38,19 +39,22 @@ func TestGetExistingRepoWithoutShortcut(t *testing.T) {
 	Test{
 		Args:         []string{"get", "https://github.com/git/git"},
 		Index:        testIndexWithTwoRepos,
-		ExpectOutput: filepath.Join(RootPath, "/github.com/git/git") + "\n",
+		ExpectOutput: filepath.Join(testRootPath, "/github.com/git/git") + "\n",
 	}.Run(t)
 }
 
 func TestGetNewRepo(t *testing.T) {
-	target := filepath.Join(RootPath, "/github.com/another/repo")
+	target := filepath.Join(testRootPath, "/github.com/another/repo")
 
 	for _, remoteURL := range []string{"gh:another/repo", "https://github.com/another/repo"} {
 		Test{
-			Args:            []string{"get", remoteURL},
-			Index:           testIndexWithTwoRepos,
-			ExpectOutput:    target + "\n",
-			ExpectExecution: Recorded("git clone https://github.com/another/repo " + target),
+			Args:         []string{"get", remoteURL},
+			Index:        testIndexWithTwoRepos,
+			ExpectOutput: target + "\n",
+			ExpectExecution: Recorded(
+				"git clone https://github.com/another/repo "+target,
+				"@"+target+" git rev-list --max-parents=0 --all",
+			),
 			ExpectIndex: &Index{
 				Repos: []*Repo{
 					{
@@ -69,7 +73,7 @@ func TestGetNewRepo(t *testing.T) {
 }
 
 func TestGetNewForkAsRemote(t *testing.T) {
-	target := filepath.Join(RootPath, "/github.com/git/git")
+	target := filepath.Join(testRootPath, "/github.com/git/git")
 	Test{
 		Args:         []string{"get", "https://example.com/git"},
 		Index:        testIndexWithTwoRepos,
@@ -101,7 +105,7 @@ func TestGetNewForkAsRemote(t *testing.T) {
 }
 
 func TestGetNewForkAsSeparate(t *testing.T) {
-	target := filepath.Join(RootPath, "/example.com/git")
+	target := filepath.Join(testRootPath, "/example.com/git")
 	Test{
 		Args:         []string{"get", "https://example.com/git"},
 		Index:        testIndexWithTwoRepos,
@@ -111,7 +115,10 @@ func TestGetNewForkAsSeparate(t *testing.T) {
 			"Found possible fork candidates. What to do? -> clone to %s\n",
 			target,
 		),
-		ExpectExecution: Recorded("git clone https://example.com/git " + target),
+		ExpectExecution: Recorded(
+			"git clone https://example.com/git "+target,
+			"@"+target+" git rev-list --max-parents=0 --all",
+		),
 		ExpectIndex: &Index{
 			Repos: []*Repo{
 				{
@@ -126,3 +133,52 @@ func TestGetNewForkAsSeparate(t *testing.T) {
 		},
 	}.Run(t)
 }
+
+func TestGetExistingRepoByCheckoutPath(t *testing.T) {
+	Test{
+		Args:         []string{"get", "github.com/git/git"},
+		Index:        testIndexWithTwoRepos,
+		ExpectOutput: filepath.Join(testRootPath, "/github.com/git/git") + "\n",
+	}.Run(t)
+}
+
+func TestGetExistingRepoByPushURL(t *testing.T) {
+	defer func(saved []*RemoteAlias) { testAliases = saved }(testAliases)
+	testAliases = remoteAliasesForPushTest
+
+	Test{
+		Args:         []string{"get", "git@github.com:git/git.git"},
+		Index:        testIndexWithTwoRepos,
+		ExpectOutput: filepath.Join(testRootPath, "/github.com/git/git") + "\n",
+	}.Run(t)
+}
+
+func TestGetExistingRepoWithDifferentScheme(t *testing.T) {
+	Test{
+		Args:         []string{"get", "git@github.com:git/git.git"},
+		Index:        testIndexWithTwoRepos,
+		ExpectOutput: filepath.Join(testRootPath, "/github.com/git/git") + "\n",
+	}.Run(t)
+}
+
+func TestGetInterruptedClone(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	target := filepath.Join(rootPath, "github.com/another/repo")
+	Test{
+		Args:          []string{"get", "gh:another/repo"},
+		Index:         testIndexWithTwoRepos,
+		ExpectFailure: true,
+		ExpectError: "!! removed partial clone at " + target + "\n" +
+			"!! command \"git clone https://github.com/another/repo " + target + "\": interrupted\n",
+		ExpectExecution: []RecordedCommand{{
+			Cmd:         Recorded("git clone https://github.com/another/repo " + target)[0].Cmd,
+			Interrupted: true,
+			SideEffect:  func() { mustMkdirAll(t, filepath.Join(target, ".git")) },
+		}},
+	}.Run(t)
+
+	_, err := os.Lstat(target)
+	if !os.IsNotExist(err) {
+		t.Errorf("expected partial clone at %s to be removed, but got err = %v", target, err)
+	}
+}
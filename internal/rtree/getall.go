@@ -0,0 +1,151 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"git.xyrillian.de/gofu/internal/cli"
+	"git.xyrillian.de/gofu/internal/forge"
+)
+
+// GetAllOptions contains the flags for `rtree get-all`.
+type GetAllOptions struct {
+	SkipArchived bool
+	SkipForks    bool
+	UseSSH       bool
+}
+
+// commandGetAll implements `rtree get-all`: It lists all repos of an
+// organization or user through the forge API, and clones those selected by
+// the user. The argument is something like "github.com:org" or "gh:org" (with
+// an alias like `url.https://github.com/.insteadOf=gh:`).
+func commandGetAll(index *Index, owner string, opts GetAllOptions) error {
+	//make sure that stdout is not used for prompts
+	index.tree.ui.StdoutProtected = true
+
+	host, ownerPath, err := splitHostAndPath(ParseRemoteURL(owner, index.tree.RemoteAliases).CanonicalURL())
+	ownerPath = strings.Trim(ownerPath, "/")
+	if err != nil || host == "" || ownerPath == "" {
+		return fmt.Errorf("cannot parse %q: expected something like \"github.com:org\"", owner)
+	}
+	client, err := index.tree.Config.forgeClient(host)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		return fmt.Errorf("no forge API configured for %s (see `forges` in %s)", host, index.tree.ConfigPath)
+	}
+
+	forgeRepos, err := client.ListRepos(context.Background(), ownerPath)
+	if err != nil {
+		return err
+	}
+	sort.Slice(forgeRepos, func(i, j int) bool {
+		return forgeRepos[i].FullName < forgeRepos[j].FullName
+	})
+
+	//ask which repos to clone
+	var choices []cli.Choice
+	urls := make(map[string]RemoteURL)
+	for _, forgeRepo := range forgeRepos {
+		if (opts.SkipArchived && forgeRepo.Archived) || (opts.SkipForks && forgeRepo.Fork) {
+			continue
+		}
+		url := remoteURLFromForge(forgeRepo, opts.UseSSH)
+		if url == "" {
+			continue
+		}
+		urls[forgeRepo.FullName] = url
+
+		text := url.CompactURL(index.tree.RemoteAliases)
+		if index.findRepoByRemoteURL(url) != nil {
+			text += " (already in index)"
+		}
+		choices = append(choices, cli.Choice{Text: text, Return: forgeRepo.FullName})
+	}
+	if len(choices) == 0 {
+		return fmt.Errorf("no repos found for %s on %s", ownerPath, host)
+	}
+	selection, err := index.tree.ui.MultiQuery(fmt.Sprintf("Which repos of %s shall be cloned?", ownerPath), choices...)
+	if err != nil {
+		return err
+	}
+
+	//clone selected repos that we don't have yet
+	var (
+		clonedPaths []string
+		errs        []error
+	)
+	for _, fullName := range selection {
+		url := urls[fullName]
+		if index.findRepoByRemoteURL(url) != nil {
+			continue
+		}
+		newRepo, err := index.tree.NewRepoFromRemoteURL(url)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		_, err = os.Stat(newRepo.AbsolutePath())
+		if err == nil {
+			index.tree.ui.ShowWarning(fmt.Sprintf(
+				"skipping %s: %s already exists (if there is a repo there, try `rtree index`)",
+				url.CompactURL(index.tree.RemoteAliases), newRepo.AbsolutePath(),
+			))
+			continue
+		}
+
+		err = newRepo.Checkout(context.Background())
+		if err != nil {
+			errs = append(errs, err)
+			if errors.Is(err, cli.ErrInterrupted) {
+				break
+			}
+			continue
+		}
+		index.Repos = append(index.Repos, &newRepo)
+		clonedPaths = append(clonedPaths, newRepo.AbsolutePath())
+		newRepo.runHooksAfterCheckout(context.Background())
+
+		//persist progress after each clone, so that nothing is lost if we
+		//get interrupted
+		err = index.Write()
+		if err != nil {
+			return err
+		}
+	}
+
+	index.tree.ui.ShowResultsSorted(clonedPaths)
+	return errors.Join(errs...)
+}
+
+// remoteURLFromForge chooses the URL to clone a repo from.
+func remoteURLFromForge(forgeRepo forge.Repo, useSSH bool) RemoteURL {
+	url := forgeRepo.CloneURL
+	if useSSH && forgeRepo.SSHURL != "" {
+		url = forgeRepo.SSHURL
+	}
+	return RemoteURL(strings.TrimSuffix(url, ".git"))
+}
+
+// findRepoByRemoteURL returns the repo that has a remote with the given URL,
+// or nil if there is none.
+func (i *Index) findRepoByRemoteURL(remoteURL RemoteURL) *Repo {
+	for _, repo := range i.Repos {
+		for _, remote := range repo.Remotes {
+			for _, url := range remote.URLs {
+				if remoteURL.MatchesURL(url, i.tree.RemoteAliases) {
+					return repo
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGetAll(t *testing.T) {
+	withForge(t, "github.com", "github", map[string]string{
+		"/orgs/foo/repos?per_page=100&limit=50&page=1": `[
+			{"full_name":"foo/bar","clone_url":"https://github.com/foo/bar.git"},
+			{"full_name":"foo/baz","clone_url":"https://github.com/foo/baz.git"},
+			{"full_name":"foo/old","clone_url":"https://github.com/foo/old.git","archived":true},
+			{"full_name":"foo/qux","clone_url":"https://github.com/foo/qux.git","fork":true}
+		]`,
+		"/orgs/foo/repos?per_page=100&limit=50&page=2": `[]`,
+	})
+
+	target := filepath.Join(testRootPath, "/github.com/foo/baz")
+	Test{
+		Args:         []string{"get-all", "--skip-archived", "gh:foo"},
+		Index:        testIndexWithTwoRepos,
+		Input:        "gh:foo/bar (already in index)\ngh:foo/baz\n",
+		ExpectOutput: target + "\n",
+		ExpectError: "Which repos of foo shall be cloned? -> gh:foo/bar (already in index)\n" +
+			"Which repos of foo shall be cloned? -> gh:foo/baz\n",
+		ExpectExecution: Recorded("git clone https://github.com/foo/baz " + target),
+		ExpectIndex: &Index{
+			Repos: []*Repo{
+				testIndexWithTwoRepos.Repos[0],
+				{
+					CheckoutPath: "github.com/foo/baz",
+					Remotes: map[string]Remote{
+						"origin": {URLs: []RemoteURL{"https://github.com/foo/baz"}},
+					},
+				},
+				testIndexWithTwoRepos.Repos[1],
+			},
+		},
+	}.Run(t)
+}
+
+func TestGetAllWithoutForge(t *testing.T) {
+	Test{
+		Args:          []string{"get-all", "gh:foo"},
+		Index:         testIndexWithTwoRepos,
+		ExpectFailure: true,
+		ExpectError:   "!! no forge API configured for github.com (see `forges` in " + testTreeOptions(t).ConfigPath + ")\n",
+	}.Run(t)
+}
@@ -0,0 +1,260 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Reading remotes with `git config -l` costs one process spawn per repo, which
+// adds up quickly during `rtree index`. Therefore, we parse the repo's config
+// file ourselves, and only fall back to `git config -l` if we encounter
+// something that we do not support (see errGitConfigUnsupported). The
+// system-wide and user-global config are not considered here, since remotes
+// are only ever configured per repo.
+
+// errGitConfigUnsupported is returned by readGitRemotes when the config file
+// uses features that only Git itself can evaluate, e.g. conditional includes.
+var errGitConfigUnsupported = errors.New("unsupported construct in Git config")
+
+// maxGitConfigIncludeDepth matches the limit that Git itself enforces.
+const maxGitConfigIncludeDepth = 10
+
+// readGitRemotes lists pairs of remote name and URL from the config of the Git
+// repo at the given path, like gitBackend.ReadRemotes.
+func readGitRemotes(repoPath string) ([][2]string, error) {
+	gitDir, err := findGitDir(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	commonDir, err := findGitCommonDir(gitDir)
+	if err != nil {
+		return nil, err
+	}
+
+	p := gitConfigParser{}
+	err = p.parseFile(filepath.Join(commonDir, "config"), 0)
+	if err != nil {
+		return nil, err
+	}
+	return p.remotes, nil
+}
+
+// findGitDir returns the location of the Git directory of the given checkout.
+// This is usually the .git directory, but .git can also be a file pointing to
+// the actual Git directory (e.g. for submodules and worktrees).
+func findGitDir(repoPath string) (string, error) {
+	gitPath := filepath.Join(repoPath, ".git")
+	fi, err := os.Stat(gitPath)
+	if err != nil || fi.IsDir() {
+		return gitPath, err
+	}
+
+	buf, err := os.ReadFile(gitPath)
+	if err != nil {
+		return "", err
+	}
+	target, ok := strings.CutPrefix(strings.TrimSpace(string(buf)), "gitdir:")
+	if !ok {
+		return "", fmt.Errorf("cannot parse %s: expected \"gitdir: <path>\"", gitPath)
+	}
+	return resolvePathRelativeTo(repoPath, strings.TrimSpace(target)), nil
+}
+
+// findGitCommonDir returns the directory that holds the config file for the
+// given Git directory. For worktrees, the Git directory contains a file
+// "commondir" that points to the Git directory of the main worktree.
+func findGitCommonDir(gitDir string) (string, error) {
+	buf, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	switch {
+	case err == nil:
+		return resolvePathRelativeTo(gitDir, strings.TrimSpace(string(buf))), nil
+	case os.IsNotExist(err):
+		return gitDir, nil
+	default:
+		return "", err
+	}
+}
+
+func resolvePathRelativeTo(baseDir, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(baseDir, path)
+}
+
+// gitConfigParser reads Git config files as described in man:git-config(1),
+// but only retains the remote URLs.
+type gitConfigParser struct {
+	remotes [][2]string
+	//the current section, with the section name in lower case
+	section    string
+	subsection string
+}
+
+func (p *gitConfigParser) parseFile(path string, depth int) error {
+	if depth > maxGitConfigIncludeDepth {
+		return fmt.Errorf("cannot parse %s: exceeded maximum include depth", path)
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	//includes are relative to the directory containing the including file,
+	//and the section does not carry over into or out of included files
+	outerSection, outerSubsection := p.section, p.subsection
+	p.section, p.subsection = "", ""
+	defer func() { p.section, p.subsection = outerSection, outerSubsection }()
+
+	scanner := bufio.NewScanner(strings.NewReader(string(buf)))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		//join continuation lines
+		for strings.HasSuffix(line, `\`) && !strings.HasSuffix(line, `\\`) && scanner.Scan() {
+			lineNo++
+			line = strings.TrimSuffix(line, `\`) + scanner.Text()
+		}
+
+		if strings.HasPrefix(line, "[") {
+			line, err = p.parseSectionHeader(line)
+			if err != nil {
+				return fmt.Errorf("cannot parse %s, line %d: %w", path, lineNo, err)
+			}
+		}
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		key, value, err := parseGitConfigVariable(line)
+		if err != nil {
+			return fmt.Errorf("cannot parse %s, line %d: %w", path, lineNo, err)
+		}
+		switch {
+		case p.section == "remote" && p.subsection != "" && key == "url":
+			p.remotes = append(p.remotes, [2]string{p.subsection, value})
+		case p.section == "include" && key == "path":
+			err := p.parseFile(expandGitConfigPath(filepath.Dir(path), value), depth+1)
+			if err != nil && !os.IsNotExist(err) { //Git ignores missing include files
+				return err
+			}
+		case p.section == "includeif":
+			return errGitConfigUnsupported
+		}
+	}
+	return scanner.Err()
+}
+
+// parseSectionHeader parses a line like `[remote "origin"]`. Returns the rest
+// of the line, since a variable can follow the section header directly.
+func (p *gitConfigParser) parseSectionHeader(line string) (string, error) {
+	line = strings.TrimPrefix(line, "[")
+	name, rest, ok := strings.Cut(line, "]")
+	if !ok {
+		return "", errors.New("unterminated section header")
+	}
+
+	section, subsection, hasSubsection := strings.Cut(name, " ")
+	switch {
+	case hasSubsection:
+		//[section "subsection"]: the subsection is case-sensitive and may
+		//contain escaped quotes and backslashes (which may also contain "]",
+		//so we need to find the end of the header again)
+		start := strings.Index(line, `"`)
+		if start == -1 {
+			return "", errors.New("invalid subsection in section header")
+		}
+		var sb strings.Builder
+		idx := start + 1
+		for ; idx < len(line) && line[idx] != '"'; idx++ {
+			if line[idx] == '\\' && idx+1 < len(line) {
+				idx++
+			}
+			sb.WriteByte(line[idx])
+		}
+		if idx+1 >= len(line) || line[idx+1] != ']' {
+			return "", errors.New("unterminated section header")
+		}
+		section, subsection, rest = line[:start], sb.String(), line[idx+2:]
+	case strings.Contains(section, "."):
+		//deprecated [section.subsection] syntax: the subsection is lowercased
+		section, subsection, _ = strings.Cut(section, ".")
+		subsection = strings.ToLower(subsection)
+	}
+
+	p.section = strings.ToLower(strings.TrimSpace(section))
+	p.subsection = subsection
+	return strings.TrimSpace(rest), nil
+}
+
+// parseGitConfigVariable parses a line like `url = "foo" # comment`. Variable
+// names are case-insensitive, so the key is returned in lower case.
+func parseGitConfigVariable(line string) (key, value string, err error) {
+	key, rawValue, hasValue := strings.Cut(line, "=")
+	key = strings.ToLower(strings.TrimSpace(key))
+	if !hasValue {
+		//a variable without value is a boolean true, which is not interesting for us
+		return key, "", nil
+	}
+
+	var (
+		sb            strings.Builder
+		inQuotes      bool
+		pendingSpaces strings.Builder //whitespace is only kept if followed by something else
+	)
+	rawValue = strings.TrimLeft(rawValue, " \t")
+	for idx := 0; idx < len(rawValue); idx++ {
+		c := rawValue[idx]
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+			continue
+		case !inQuotes && (c == '#' || c == ';'):
+			idx = len(rawValue) //rest of line is a comment
+			continue
+		case !inQuotes && (c == ' ' || c == '\t'):
+			pendingSpaces.WriteByte(c)
+			continue
+		case c == '\\':
+			idx++
+			if idx == len(rawValue) {
+				return "", "", errors.New("unterminated escape sequence")
+			}
+			switch rawValue[idx] {
+			case '"', '\\':
+				c = rawValue[idx]
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			default:
+				return "", "", fmt.Errorf("invalid escape sequence: \\%c", rawValue[idx])
+			}
+		}
+		sb.WriteString(pendingSpaces.String())
+		pendingSpaces.Reset()
+		sb.WriteByte(c)
+	}
+	if inQuotes {
+		return "", "", errors.New("unterminated quoted string")
+	}
+	return key, sb.String(), nil
+}
+
+// expandGitConfigPath resolves the value of "include.path".
+func expandGitConfigPath(baseDir, path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return filepath.Join(os.Getenv("HOME"), rest)
+	}
+	return resolvePathRelativeTo(baseDir, path)
+}
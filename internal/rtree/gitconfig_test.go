@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func mustWriteFile(t *testing.T, path, contents string) {
+	mustMkdirAll(t, filepath.Dir(path))
+	err := os.WriteFile(path, []byte(contents), 0644)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+const testGitConfig = `[core]
+	bare = false
+[remote "origin"]
+	url = https://github.com/foo/bar
+	fetch = +refs/heads/*:refs/remotes/origin/*
+[Remote "with \"quotes\""] URL = "git@example.com:foo/bar.git" ; comment
+[remote.Legacy]
+	url = https://example.org/legacy\
+continued
+[include]
+	path = extra.inc
+	path = missing.inc
+`
+
+func TestReadGitRemotes(t *testing.T) {
+	basePath := t.TempDir()
+	mainPath := filepath.Join(basePath, "main")
+	mustWriteFile(t, filepath.Join(mainPath, ".git/config"), testGitConfig)
+	mustWriteFile(t, filepath.Join(mainPath, ".git/extra.inc"), "[remote \"upstream\"]\n url = https://github.com/upstream/bar # comment\n")
+	//a worktree of the main repo
+	worktreePath := filepath.Join(basePath, "worktree")
+	mustWriteFile(t, filepath.Join(worktreePath, ".git"), "gitdir: ../main/.git/worktrees/wt\n")
+	mustWriteFile(t, filepath.Join(mainPath, ".git/worktrees/wt/commondir"), "../..\n")
+
+	expected := [][2]string{
+		{"origin", "https://github.com/foo/bar"},
+		{`with "quotes"`, "git@example.com:foo/bar.git"},
+		{"legacy", "https://example.org/legacycontinued"},
+		{"upstream", "https://github.com/upstream/bar"},
+	}
+	for _, repoPath := range []string{mainPath, worktreePath} {
+		actual, err := readGitRemotes(repoPath)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !slices.Equal(actual, expected) {
+			t.Errorf("expected remotes %q in %s, but got %q", expected, repoPath, actual)
+		}
+	}
+
+	//conditional includes are left to Git
+	repoPath := filepath.Join(basePath, "conditional")
+	mustWriteFile(t, filepath.Join(repoPath, ".git/config"), "[includeIf \"gitdir:~/work/\"]\n\tpath = work.inc\n")
+	_, err := readGitRemotes(repoPath)
+	if err != errGitConfigUnsupported {
+		t.Errorf("expected error %q, but got %v", errGitConfigUnsupported, err)
+	}
+}
+
+func TestIndexWithoutGitConfigSubprocess(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	repoPath := filepath.Join(rootPath, "github.com/foo/bar")
+	mustWriteFile(t, filepath.Join(repoPath, ".git/config"), "[remote \"origin\"]\n\turl = https://github.com/foo/bar\n")
+
+	//only the root commits need to be obtained from Git (the remote URL is
+	//already in canonical form, so it is not rewritten)
+	indexed := &Repo{
+		CheckoutPath: "github.com/foo/bar",
+		Remotes:      testIndexWithTwoRepos.Repos[0].Remotes,
+		RootCommits:  []string{"1234567890123456789012345678901234567890"},
+	}
+	Test{
+		Args:  []string{"index"},
+		Index: Index{Repos: []*Repo{}},
+		ExpectExecution: []RecordedCommand{{
+			Cmd:    Recorded("@" + repoPath + " git rev-list --max-parents=0 --all")[0].Cmd,
+			Stdout: indexed.RootCommits[0] + "\n",
+		}},
+		ExpectIndex: &Index{Repos: []*Repo{indexed}},
+	}.Run(t)
+
+	//when nothing has changed, no commands are run at all
+	Test{
+		Args:  []string{"index"},
+		Index: Index{Repos: []*Repo{indexed}},
+	}.Run(t)
+}
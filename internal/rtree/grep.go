@@ -0,0 +1,106 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"git.xyrillian.de/gofu/internal/cli"
+)
+
+// GrepOptions contains the options for `rtree grep`.
+type GrepOptions struct {
+	//If true, only the names of matching files are shown (like `grep -l`).
+	FilesWithMatches bool
+	//If not empty, only repos whose checkout path matches any of these globs
+	//are searched.
+	Match []string
+}
+
+// grepResult is the outcome of `git grep` in a single repo.
+type grepResult struct {
+	Lines []string //prefixed with the checkout path
+	Err   error
+}
+
+// selectsRepo checks whether the repo is selected by the --match options.
+func (o GrepOptions) selectsRepo(repo *Repo) bool {
+	if len(o.Match) == 0 {
+		return true
+	}
+	for _, pattern := range o.Match {
+		if ok, _ := path.Match(pattern, repo.CheckoutPath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// commandGrep implements `rtree grep`: It runs `git grep` concurrently in all
+// selected repos, and shows the matches with paths relative to the RootPath.
+// Like grep(1), the exit code is 0 if there were matches, 1 if there were
+// none, and 2 if an error occurred.
+func commandGrep(index *Index, pattern string, opts GrepOptions) int {
+	var repos []*Repo
+	for _, repo := range index.Repos {
+		//Mercurial and non-colocated Jujutsu repos cannot be searched with `git grep`
+		if repo.HasGitDir() && opts.selectsRepo(repo) {
+			repos = append(repos, repo)
+		}
+	}
+
+	cmdline := []string{"git", "grep", "-I"}
+	if opts.FilesWithMatches {
+		cmdline = append(cmdline, "-l")
+	}
+	cmdline = append(cmdline, "-e", pattern)
+
+	results := make([]grepResult, len(repos))
+	foreachRepoConcurrently(repos, func(idx int, repo *Repo) {
+		results[idx] = repo.grep(cmdline)
+	})
+
+	//show results in the order of the index, so that the output is stable
+	exitCode := 1
+	hasErrors := false
+	for _, result := range results {
+		if result.Err != nil {
+			index.tree.ui.ShowError(result.Err.Error())
+			hasErrors = true
+			continue
+		}
+		for _, line := range result.Lines {
+			index.tree.ui.ShowResult(line)
+			exitCode = 0
+		}
+	}
+	if hasErrors {
+		return 2
+	}
+	return exitCode
+}
+
+func (r *Repo) grep(cmdline []string) grepResult {
+	stdout, stderr, err := r.tree.ui.CaptureOutput(cli.Command{
+		Program:  cmdline,
+		WorkDir:  r.AbsolutePath(),
+		ReadOnly: true,
+	})
+	if err != nil {
+		//`git grep` exits with status 1 and without any output if there are
+		//no matches
+		if stdout == "" && strings.TrimSpace(stderr) == "" {
+			return grepResult{}
+		}
+		return grepResult{Err: fmt.Errorf("grep in %s: %s", r.AbsolutePath(), gitErrorMessage(stderr, err))}
+	}
+
+	var result grepResult
+	for line := range strings.SplitSeq(strings.TrimSuffix(stdout, "\n"), "\n") {
+		result.Lines = append(result.Lines, r.CheckoutPath+"/"+line)
+	}
+	return result
+}
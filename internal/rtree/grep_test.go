@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestGrep(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	rootPath := withTemporaryRootPath(t)
+	files := map[string]string{
+		"example.org/foo/main.go":    "package main\n\nfunc main() {}\n",
+		"example.org/foo/README.md":  "# foo\n",
+		"example.org/bar/lib/lib.go": "package lib\n\nfunc Main() {}\n",
+		"github.com/qux/qux/qux.txt": "nothing to see here\n",
+	}
+	for filePath, contents := range files {
+		mustWriteFile(t, filepath.Join(rootPath, filePath), contents)
+	}
+	index := Index{}
+	for _, checkoutPath := range []string{"example.org/bar", "example.org/foo", "github.com/qux/qux"} {
+		repoPath := filepath.Join(rootPath, checkoutPath)
+		mustRunGit(t, "init", "--quiet", repoPath)
+		mustRunGit(t, "-C", repoPath, "add", ".")
+		index.Repos = append(index.Repos, &Repo{
+			CheckoutPath: checkoutPath,
+			Remotes:      map[string]Remote{"origin": {URLs: []RemoteURL{RemoteURL("https://" + checkoutPath)}}},
+		})
+	}
+
+	testCases := []struct {
+		Args             []string
+		ExpectedExitCode int
+		ExpectedOutput   string
+	}{
+		{
+			Args:             []string{"grep", "func"},
+			ExpectedExitCode: 0,
+			ExpectedOutput:   "example.org/bar/lib/lib.go:func Main() {}\nexample.org/foo/main.go:func main() {}\n",
+		},
+		{
+			Args:             []string{"grep", "-l", "--match", "*/foo", "f"},
+			ExpectedExitCode: 0,
+			ExpectedOutput:   "example.org/foo/README.md\nexample.org/foo/main.go\n",
+		},
+		{
+			Args:             []string{"grep", "--match=github.com/*/*", "func"},
+			ExpectedExitCode: 1,
+		},
+		{
+			Args:             []string{"grep", "-l", `\(`},
+			ExpectedExitCode: 2,
+		},
+	}
+	for _, tc := range testCases {
+		opts := testTreeOptions(t)
+		var stdout bytes.Buffer
+		opts.Stdout = &stdout
+		err := writeIndexFixture(opts.IndexPath, index)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		tree, err := New(opts)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		exitCode := tree.exec(tc.Args)
+		if exitCode != tc.ExpectedExitCode {
+			t.Errorf("%v: expected exit code %d, but got %d", tc.Args, tc.ExpectedExitCode, exitCode)
+		}
+		if stdout.String() != tc.ExpectedOutput {
+			t.Errorf("%v: expected output %q, but got %q", tc.Args, tc.ExpectedOutput, stdout.String())
+		}
+	}
+}
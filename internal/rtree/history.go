@@ -0,0 +1,208 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// The access history records when repos were visited through `rtree get`, so
+// that repos can be ranked by frecency (a mix of frequency and recency). It is
+// kept in a separate file next to the index, so that the index file (which is
+// usually backed up) only changes when the set of repos changes.
+
+// maxHistorySamples is how many access timestamps are kept per repo. Older
+// accesses still count towards the total number of accesses, but their age is
+// only estimated from the retained samples.
+const maxHistorySamples = 10
+
+// accessHistory represents the contents of the history file.
+type accessHistory struct {
+	//key = checkout path
+	Repos map[string]*accessRecord `json:"repos"`
+}
+
+type accessRecord struct {
+	//Count is the total number of accesses.
+	Count int `json:"count"`
+	//Timestamps holds the most recent accesses as UNIX timestamps, oldest first.
+	Timestamps []int64 `json:"timestamps"`
+}
+
+// errCorruptHistory is returned by readHistory when the history file cannot
+// be parsed.
+var errCorruptHistory = errors.New("history file is corrupt")
+
+// readHistory reads the history file. A missing history file is not an error.
+// If the history file cannot be parsed, an empty history is returned together
+// with an error that wraps errCorruptHistory.
+func (t *RTree) readHistory() (accessHistory, error) {
+	h := accessHistory{Repos: make(map[string]*accessRecord)}
+	if t.HistoryPath == "" {
+		return h, nil
+	}
+	buf, err := os.ReadFile(t.HistoryPath)
+	switch {
+	case err == nil:
+		err = json.Unmarshal(buf, &h)
+		if err != nil {
+			empty := accessHistory{Repos: make(map[string]*accessRecord)}
+			return empty, fmt.Errorf("read %s: %w (%w)", t.HistoryPath, errCorruptHistory, err)
+		}
+		if h.Repos == nil {
+			h.Repos = make(map[string]*accessRecord)
+		}
+		return h, nil
+	case os.IsNotExist(err):
+		return h, nil
+	default:
+		return h, err
+	}
+}
+
+// RecordAccess adds an access to the given repo to the history file. Entries
+// for repos that are not in the index anymore are dropped at the same time.
+// If the history file is corrupt, it is started afresh (it is only used for
+// ranking, so nothing of value is lost). If the RTree does not have a
+// HistoryPath, this does nothing.
+func (i *Index) RecordAccess(repo *Repo, now time.Time) error {
+	if i.tree.HistoryPath == "" {
+		return nil
+	}
+	err := i.tree.fs.MkdirAll(filepath.Dir(i.tree.HistoryPath), 0755)
+	if err != nil {
+		return err
+	}
+	unlock, err := i.tree.lockHistory()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	h, err := i.tree.readHistory()
+	switch {
+	case errors.Is(err, errCorruptHistory):
+		i.tree.ui.ShowWarning(err.Error() + "; starting a new history")
+	case err != nil:
+		return err
+	}
+
+	isIndexed := make(map[string]bool, len(i.Repos))
+	for _, r := range i.Repos {
+		isIndexed[r.CheckoutPath] = true
+	}
+	for checkoutPath := range h.Repos {
+		if !isIndexed[checkoutPath] {
+			delete(h.Repos, checkoutPath)
+		}
+	}
+	rec := h.Repos[repo.CheckoutPath]
+	if rec == nil {
+		rec = &accessRecord{}
+		h.Repos[repo.CheckoutPath] = rec
+	}
+	rec.Count++
+	rec.Timestamps = append(rec.Timestamps, now.Unix())
+	if len(rec.Timestamps) > maxHistorySamples {
+		rec.Timestamps = rec.Timestamps[len(rec.Timestamps)-maxHistorySamples:]
+	}
+
+	buf, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	//readers like `rtree recent` do not take the lock, so the file must never
+	//be seen half-written
+	return i.tree.fs.ReplaceFile(i.tree.HistoryPath, buf, 0644)
+}
+
+// lockHistory takes an exclusive advisory lock on a lock file next to the
+// history file, so that several `rtree get` running at once do not lose each
+// other's accesses. (The history file itself cannot be locked since it is
+// replaced on every write.) The returned function releases the lock.
+func (t *RTree) lockHistory() (unlock func(), err error) {
+	if t.dryRun {
+		return func() {}, nil
+	}
+	f, err := os.OpenFile(t.HistoryPath+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock %s: %w", f.Name(), err)
+	}
+	//closing the file releases the lock
+	return func() { f.Close() }, nil
+}
+
+// frecency returns the frecency score of the repo with the given checkout
+// path. Each sampled access is weighted by its age, and the average weight is
+// multiplied by the total number of accesses (this is the same approach that
+// Firefox uses for ranking URLs). Repos that were never accessed score 0.
+func (h accessHistory) frecency(checkoutPath string, now time.Time) float64 {
+	rec := h.Repos[checkoutPath]
+	if rec == nil || len(rec.Timestamps) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, ts := range rec.Timestamps {
+		age := now.Sub(time.Unix(ts, 0))
+		switch {
+		case age < 24*time.Hour:
+			sum += 100
+		case age < 7*24*time.Hour:
+			sum += 70
+		case age < 30*24*time.Hour:
+			sum += 50
+		case age < 90*24*time.Hour:
+			sum += 30
+		default:
+			sum += 10
+		}
+	}
+	return float64(rec.Count) * sum / float64(len(rec.Timestamps))
+}
+
+// sortByFrecency sorts the given repos by descending frecency. The sort is
+// stable, so repos with the same score retain their order.
+func (h accessHistory) sortByFrecency(repos []*Repo, now time.Time) {
+	slices.SortStableFunc(repos, func(left, right *Repo) int {
+		return cmp.Compare(h.frecency(right.CheckoutPath, now), h.frecency(left.CheckoutPath, now))
+	})
+}
+
+// commandRecent implements `rtree recent`: It lists the checkout paths of all
+// repos that have been accessed through `rtree get`, by descending frecency.
+func commandRecent(index *Index) error {
+	h, err := index.tree.readHistory()
+	switch {
+	case errors.Is(err, errCorruptHistory):
+		//the next `rtree get` will start a new history
+		index.tree.ui.ShowWarning(err.Error())
+	case err != nil:
+		return err
+	}
+	now := time.Now()
+	var repos []*Repo
+	for _, repo := range index.Repos {
+		if h.frecency(repo.CheckoutPath, now) > 0 {
+			repos = append(repos, repo)
+		}
+	}
+	h.sortByFrecency(repos, now)
+	for _, repo := range repos {
+		index.tree.ui.ShowResult(repo.CheckoutPath)
+	}
+	return nil
+}
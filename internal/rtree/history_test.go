@@ -0,0 +1,186 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeHistoryFixture(t *testing.T, path string, h accessHistory) {
+	buf, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	mustWriteFile(t, path, string(buf))
+}
+
+func TestFrecency(t *testing.T) {
+	now := time.Now()
+	ago := func(d time.Duration) int64 { return now.Add(-d).Unix() }
+	h := accessHistory{Repos: map[string]*accessRecord{
+		"often":       {Count: 50, Timestamps: []int64{ago(60 * 24 * time.Hour), ago(50 * 24 * time.Hour)}},
+		"recent":      {Count: 2, Timestamps: []int64{ago(2 * time.Hour), ago(time.Hour)}},
+		"long-ago":    {Count: 2, Timestamps: []int64{ago(200 * 24 * time.Hour), ago(100 * 24 * time.Hour)}},
+		"no-accesses": {Count: 0},
+	}}
+
+	expected := map[string]float64{
+		"often":       50 * 30,
+		"recent":      2 * 100,
+		"long-ago":    2 * 10,
+		"no-accesses": 0,
+		"unknown":     0,
+	}
+	for checkoutPath, score := range expected {
+		actual := h.frecency(checkoutPath, now)
+		if actual != score {
+			t.Errorf("expected frecency %g for %s, but got %g", score, checkoutPath, actual)
+		}
+	}
+}
+
+func TestGetRecordsAccess(t *testing.T) {
+	opts := testTreeOptions(t)
+	for range maxHistorySamples + 2 {
+		Test{
+			Args:         []string{"get", "gh:git/git"},
+			Index:        testIndexWithTwoRepos,
+			ExpectOutput: filepath.Join(testRootPath, "/github.com/git/git") + "\n",
+		}.Run(t)
+	}
+
+	buf, err := os.ReadFile(opts.HistoryPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var h accessHistory
+	err = json.Unmarshal(buf, &h)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rec := h.Repos["github.com/git/git"]
+	if len(h.Repos) != 1 || rec == nil {
+		t.Fatalf("expected only github.com/git/git in history, but got %#v", h.Repos)
+	}
+	if rec.Count != maxHistorySamples+2 || len(rec.Timestamps) != maxHistorySamples {
+		t.Errorf("expected %d accesses with %d timestamps, but got %#v", maxHistorySamples+2, maxHistorySamples, rec)
+	}
+}
+
+func TestRecordAccessWaitsForLock(t *testing.T) {
+	tree := newTestTree(t, testIndexWithTwoRepos, &CommandSimulator{})
+	tree.HistoryPath = filepath.Join(t.TempDir(), "history.json")
+	index, errs := tree.readIndex()
+	if len(errs) > 0 {
+		t.Fatal(errs[0].Error())
+	}
+
+	//another `rtree get` is in the middle of updating the history
+	unlock, err := tree.lockHistory()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	now := time.Now()
+	done := make(chan error)
+	go func() { done <- index.RecordAccess(index.Repos[1], now) }()
+	select {
+	case err := <-done:
+		t.Fatalf("expected RecordAccess to wait for the lock, but it returned (err = %v)", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	writeHistoryFixture(t, tree.HistoryPath, accessHistory{Repos: map[string]*accessRecord{
+		"github.com/git/git": {Count: 5, Timestamps: []int64{now.Unix()}},
+	}})
+	unlock()
+
+	//the access must be added to what the other process wrote
+	err = <-done
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	h, err := tree.readHistory()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rec := h.Repos["github.com/git/git"]
+	if rec == nil || rec.Count != 6 || len(rec.Timestamps) != 2 {
+		t.Errorf("expected 6 accesses with 2 timestamps, but got %#v", rec)
+	}
+}
+
+func TestGetResetsCorruptHistory(t *testing.T) {
+	opts := testTreeOptions(t)
+	mustWriteFile(t, opts.HistoryPath, `{"repos":{"github.com/git/git":{"count":`)
+
+	Test{
+		Args:         []string{"get", "gh:git/git"},
+		Index:        testIndexWithTwoRepos,
+		ExpectOutput: filepath.Join(testRootPath, "/github.com/git/git") + "\n",
+		ExpectError: "!! read " + opts.HistoryPath + ": history file is corrupt (unexpected end of JSON input);" +
+			" starting a new history\n",
+	}.Run(t)
+
+	buf, err := os.ReadFile(opts.HistoryPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var h accessHistory
+	err = json.Unmarshal(buf, &h)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if rec := h.Repos["github.com/git/git"]; len(h.Repos) != 1 || rec == nil || rec.Count != 1 {
+		t.Errorf("expected a new history with one access, but got %#v", h.Repos)
+	}
+	//(the lock file is expected to stay around)
+	matches, err := filepath.Glob(opts.HistoryPath + ".*.tmp")
+	if err != nil || len(matches) > 0 {
+		t.Errorf("expected no temporary files next to the history file, but got %v (err = %v)", matches, err)
+	}
+}
+
+func TestRecent(t *testing.T) {
+	now := time.Now().Unix()
+	writeHistoryFixture(t, testTreeOptions(t).HistoryPath, accessHistory{Repos: map[string]*accessRecord{
+		"github.com/foo/bar":   {Count: 1, Timestamps: []int64{now}},
+		"github.com/git/git":   {Count: 3, Timestamps: []int64{now, now, now}},
+		"github.com/gone/gone": {Count: 9, Timestamps: []int64{now}}, //not in index
+	}})
+
+	Test{
+		Args:         []string{"recent"},
+		Index:        testIndexWithTwoRepos,
+		ExpectOutput: "github.com/git/git\ngithub.com/foo/bar\n",
+	}.Run(t)
+}
+
+func TestForkCandidatesRankedByFrecency(t *testing.T) {
+	index := Index{Repos: []*Repo{
+		{
+			CheckoutPath: "github.com/bar/bar",
+			Remotes:      map[string]Remote{"origin": {URLs: []RemoteURL{"https://github.com/bar/bar"}}},
+		},
+		{
+			CheckoutPath: "github.com/foo/bar",
+			Remotes:      map[string]Remote{"origin": {URLs: []RemoteURL{"https://github.com/foo/bar"}}},
+		},
+	}}
+	tree := newTestTree(t, index, &CommandSimulator{})
+	writeHistoryFixture(t, tree.HistoryPath, accessHistory{Repos: map[string]*accessRecord{
+		"github.com/foo/bar": {Count: 1, Timestamps: []int64{time.Now().Unix()}},
+	}})
+	idx, errs := tree.readIndex()
+	if len(errs) > 0 {
+		t.Fatal(errs[0].Error())
+	}
+
+	candidates, _ := idx.FindForkCandidates(ParseRemoteURL("https://example.com/bar", tree.RemoteAliases))
+	if len(candidates) != 2 || candidates[0].Repo.CheckoutPath != "github.com/foo/bar" {
+		t.Errorf("expected github.com/foo/bar to be ranked first, but got %#v", candidates)
+	}
+}
@@ -0,0 +1,122 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"git.xyrillian.de/gofu/internal/cli"
+)
+
+// Matches returns whether this hook applies to a repo with the given origin.
+// The aliases are needed to match the compact form of the origin URL.
+func (h HookConfig) Matches(originURL RemoteURL, aliases []*RemoteAlias) bool {
+	if h.Host != "" {
+		host, _, err := splitHostAndPath(originURL.CanonicalURL())
+		if err == nil && strings.EqualFold(host, h.Host) {
+			return true
+		}
+	}
+	if h.URL != "" {
+		for _, url := range []string{originURL.CanonicalURL(), originURL.CompactURL(aliases)} {
+			if ok, _ := path.Match(h.URL, url); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RunHooks applies all hooks from the config that match this repo's origin.
+// This is called after each clone (see runHooksAfterCheckout), but can also be
+// triggered manually through `rtree hooks run`. The hooks' commands are
+// interrupted when the given context is canceled.
+func (r Repo) RunHooks(ctx context.Context) error {
+	origin, ok := r.Remotes["origin"]
+	if !ok || len(origin.URLs) == 0 {
+		return nil
+	}
+	originURL := origin.URLs[0]
+
+	for _, hook := range r.tree.Config.Hooks {
+		if !hook.Matches(originURL, r.tree.RemoteAliases) {
+			continue
+		}
+
+		keys := make([]string, 0, len(hook.GitConfig))
+		for key := range hook.GitConfig {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if len(keys) > 0 && !r.HasGitDir() {
+			r.tree.ui.ShowWarning(fmt.Sprintf("cannot set Git config in %s: not a Git repo", r.AbsolutePath()))
+			keys = nil
+		}
+		for _, key := range keys {
+			err := r.tree.ui.Run(cli.Command{
+				Program: []string{"git", "config", key, hook.GitConfig[key]},
+				WorkDir: r.AbsolutePath(),
+				Context: ctx,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, command := range hook.Commands {
+			err := r.tree.ui.Run(cli.Command{
+				Program: []string{
+					"env",
+					"RTREE_CHECKOUT_PATH=" + r.AbsolutePath(),
+					"RTREE_REMOTE_URL=" + originURL.CanonicalURL(),
+					"sh", "-c", command,
+				},
+				WorkDir: r.AbsolutePath(),
+				Context: ctx,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// commandHooksRun implements `rtree hooks run`.
+func commandHooksRun(index *Index, args []string) error {
+	if len(args) == 1 && args[0] == "--all" {
+		for _, repo := range index.Repos {
+			index.tree.ui.ShowProgress(repo.AbsolutePath())
+			err := repo.RunHooks(context.Background())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var dirPath string
+	if len(args) == 1 {
+		dirPath = args[0]
+	} else {
+		var err error
+		dirPath, err = os.Getwd()
+		if err != nil {
+			return err
+		}
+	}
+	repo, err := index.FindRepoByPath(dirPath)
+	if err != nil {
+		return err
+	}
+	if repo == nil {
+		return fmt.Errorf("%s is not inside a repo that is tracked by rtree", dirPath)
+	}
+	return repo.RunHooks(context.Background())
+}
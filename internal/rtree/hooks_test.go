@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.xyrillian.de/gofu/internal/cli"
+)
+
+func withHooks(t *testing.T, hooks ...HookConfig) {
+	savedConfig := testConfig
+	testConfig = &Configuration{Hooks: hooks}
+	t.Cleanup(func() { testConfig = savedConfig })
+}
+
+var testHooks = []HookConfig{
+	{
+		Host:      "GitHub.com",
+		GitConfig: map[string]string{"user.email": "me@example.com", "commit.gpgsign": "true"},
+	},
+	{
+		URL:      "gh:another/*",
+		Commands: []string{"direnv allow"},
+	},
+	{
+		URL:      "https://example.com/*",
+		Commands: []string{"false"},
+	},
+}
+
+func TestGetRunsHooks(t *testing.T) {
+	withHooks(t, testHooks...)
+
+	target := filepath.Join(testRootPath, "/github.com/another/repo")
+	Test{
+		Args:         []string{"get", "gh:another/repo"},
+		Index:        testIndexWithTwoRepos,
+		ExpectOutput: target + "\n",
+		ExpectExecution: append(
+			Recorded(
+				"git clone https://github.com/another/repo "+target,
+				"@"+target+" git config commit.gpgsign true",
+				"@"+target+" git config user.email me@example.com",
+			),
+			RecordedCommand{Cmd: cli.Command{
+				Program: []string{
+					"env", "RTREE_CHECKOUT_PATH=" + target, "RTREE_REMOTE_URL=https://github.com/another/repo",
+					"sh", "-c", "direnv allow",
+				},
+				WorkDir: target,
+			}},
+			Recorded("@" + target + " git rev-list --max-parents=0 --all")[0],
+		),
+		ExpectIndex: &Index{
+			Repos: []*Repo{
+				{
+					CheckoutPath: "github.com/another/repo",
+					Remotes: map[string]Remote{
+						"origin": {URLs: []RemoteURL{"https://github.com/another/repo"}},
+					},
+				},
+				testIndexWithTwoRepos.Repos[0],
+				testIndexWithTwoRepos.Repos[1],
+			},
+		},
+	}.Run(t)
+}
+
+func TestHooksRun(t *testing.T) {
+	withHooks(t, testHooks...)
+	rootPath := withTemporaryRootPath(t)
+	target := filepath.Join(rootPath, "github.com/git/git")
+	err := os.MkdirAll(target, 0755)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	Test{
+		Args:  []string{"hooks", "run", target},
+		Index: testIndexWithTwoRepos,
+		ExpectExecution: Recorded(
+			"@"+target+" git config commit.gpgsign true",
+			"@"+target+" git config user.email me@example.com",
+		),
+	}.Run(t)
+}
+
+func TestGetWithFailingHook(t *testing.T) {
+	withHooks(t, testHooks...)
+
+	//the repo is added to the index even though the hook fails
+	target := filepath.Join(testRootPath, "example.com/foo")
+	Test{
+		Args:         []string{"get", "https://example.com/foo"},
+		Index:        testIndexWithTwoRepos,
+		ExpectOutput: target + "\n",
+		ExpectError: "!! hooks failed for " + target + ": command \"env RTREE_CHECKOUT_PATH=" + target +
+			" RTREE_REMOTE_URL=https://example.com/foo sh -c false\" has failed" +
+			" (retry with `rtree hooks run " + target + "`)\n",
+		ExpectExecution: []RecordedCommand{
+			Recorded("git clone https://example.com/foo " + target)[0],
+			{
+				Cmd: cli.Command{
+					Program: []string{
+						"env", "RTREE_CHECKOUT_PATH=" + target, "RTREE_REMOTE_URL=https://example.com/foo",
+						"sh", "-c", "false",
+					},
+					WorkDir: target,
+				},
+				Fails: true,
+			},
+			Recorded("@" + target + " git rev-list --max-parents=0 --all")[0],
+		},
+		ExpectIndex: &Index{
+			Repos: []*Repo{
+				{
+					CheckoutPath: "example.com/foo",
+					Remotes: map[string]Remote{
+						"origin": {URLs: []RemoteURL{"https://example.com/foo"}},
+					},
+				},
+				testIndexWithTwoRepos.Repos[0],
+				testIndexWithTwoRepos.Repos[1],
+			},
+		},
+	}.Run(t)
+}
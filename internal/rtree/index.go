@@ -4,11 +4,11 @@
 package rtree
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
-	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -18,40 +18,50 @@ import (
 
 // Index represents the contents of the index.json file.
 type Index struct {
-	Repos []*Repo `json:"repos"`
+	//Version is always IndexVersion after readIndex() or Write().
+	Version int     `json:"version"`
+	Repos   []*Repo `json:"repos"`
+
+	//tree is the RTree that this index belongs to.
+	tree *RTree
+	//migration is set if the index file was migrated by readIndex(), but
+	//the migration has not been written to disk yet.
+	migration *indexMigration
 }
 
-// ReadIndex reads the index file.
-func ReadIndex() (*Index, []error) {
+// readIndex reads the index file. Older versions of the index are migrated
+// automatically (see IndexVersion).
+func (t *RTree) readIndex() (*Index, []error) {
 	//read contents of index file
-	buf, err := os.ReadFile(IndexPath)
+	buf, migration, err := t.readIndexFile()
 	if err != nil {
-		if os.IsNotExist(err) {
-			_, err := os.Stat(OldIndexPath)
-			if !os.IsNotExist(err) {
-				err = fmt.Errorf(
-					"old index format detected: upgrade to the new index format with this command:\n\t"+
-						`yq -o json < %s | jq --sort-keys '{ repos: .repos | map({ path, remotes: .remotes | map({ key: .name, value: { urls: [.url] }}) | from_entries }) }' > %s`,
-					OldIndexPath, IndexPath,
-				)
-				return nil, []error{err}
-			}
-			return &Index{Repos: nil}, nil
-		}
 		return nil, []error{err}
 	}
+	if buf == nil {
+		return &Index{Version: IndexVersion, Repos: nil, tree: t}, nil
+	}
 
 	//deserialize JSON
-	var index Index
+	index := Index{tree: t, migration: migration}
 	err = json.Unmarshal(buf, &index)
 	if err != nil {
 		return nil, []error{err}
 	}
+	for _, repo := range index.Repos {
+		repo.tree = t
+		//URLs are stored in canonical form, but users editing the index by
+		//hand might have used aliases
+		for _, remote := range repo.Remotes {
+			for idx, url := range remote.URLs {
+				remote.URLs[idx] = ParseRemoteURL(string(url), t.RemoteAliases)
+			}
+		}
+	}
 	//validate JSON
 	var errs []error
 	missing := func(key string, args ...any) {
 		errs = append(errs, fmt.Errorf("read %s: missing \"%s\"",
-			IndexPath, fmt.Sprintf(key, args...),
+			t.IndexPath, fmt.Sprintf(key, args...),
 		))
 	}
 	for idx, repo := range index.Repos {
@@ -65,7 +75,7 @@ func ReadIndex() (*Index, []error) {
 			switch {
 			case remoteName == "":
 				errs = append(errs, fmt.Errorf("read %s: empty key in \"repos[%d].remotes\"",
-					IndexPath, idx,
+					t.IndexPath, idx,
 				))
 			case len(remote.URLs) == 0:
 				missing("repos[%d].remotes[%q].urls", idx, remoteName)
@@ -85,43 +95,105 @@ func (r reposByAbsPath) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
 
 // Write writes the index file to disk.
 func (i *Index) Write() error {
+	i.Version = IndexVersion
 	sort.Sort(reposByAbsPath(i.Repos))
 	buf, err := json.MarshalIndent(i, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	err = os.MkdirAll(filepath.Dir(IndexPath), 0755)
-	if err != nil {
-		return err
+	if i.tree.dryRun {
+		err = i.reportChanges()
+	} else {
+		err = i.tree.fs.MkdirAll(filepath.Dir(i.tree.IndexPath), 0755)
+	}
+	if err == nil && i.migration != nil {
+		err = i.tree.persistMigration(*i.migration)
+	}
+	if err == nil && !i.tree.dryRun {
+		err = i.tree.fs.ReplaceFile(i.tree.IndexPath, buf, 0644)
 	}
-	err = os.WriteFile(IndexPath, buf, 0644)
 	if err != nil {
 		return err
 	}
+	if i.migration != nil {
+		if !i.tree.dryRun {
+			i.tree.ui.ShowWarning(fmt.Sprintf(
+				"migrated %s to index format version %d (the previous index is still available at %s)",
+				i.tree.IndexPath, IndexVersion, i.migration.backupPath,
+			))
+		}
+		i.migration = nil
+	}
 
-	//perform sanity check (TODO: do this instead when rebuilding the index)
+	//perform sanity check (`rtree doctor` has more thorough checks)
 	seen := make(map[string]bool)
 	warned := make(map[string]bool)
 	for _, repo := range i.Repos {
 		if seen[repo.CheckoutPath] && !warned[repo.CheckoutPath] {
-			cli.Interface.ShowWarning(
+			i.tree.ui.ShowWarning(
 				fmt.Sprintf("repo %s appears multiple times in the index file!", repo.AbsolutePath()),
 			)
 			warned[repo.CheckoutPath] = true
 		}
 		seen[repo.CheckoutPath] = true
 	}
+	for _, pair := range i.sameOriginPairs() {
+		i.tree.ui.ShowWarning(fmt.Sprintf(
+			"repos %s and %s are checkouts of the same origin %s!",
+			pair[0].AbsolutePath(), pair[1].AbsolutePath(), pair[0].Remotes["origin"].URLs[0].CompactURL(i.tree.RemoteAliases),
+		))
+	}
 
 	return nil
 }
 
+// sameOriginPairs returns all pairs of repos with different checkout paths
+// that are checkouts of the same origin (like HasSameOriginAs()). Instead of
+// comparing each pair of repos, this looks up each repo by the equivalence
+// keys of its origin URL. Within each pair, and among the pairs, the repos
+// appear in the same order as in i.Repos.
+func (i *Index) sameOriginPairs() [][2]*Repo {
+	var (
+		reposByKey = make(map[string][]int)
+		pairs      [][2]int
+	)
+	for idx, repo := range i.Repos {
+		origin, ok := repo.Remotes["origin"]
+		if !ok || len(origin.URLs) == 0 {
+			continue
+		}
+		paired := make(map[int]bool)
+		for _, key := range origin.URLs[0].equivalenceKeys(i.tree.RemoteAliases) {
+			for _, otherIdx := range reposByKey[key] {
+				if !paired[otherIdx] && i.Repos[otherIdx].CheckoutPath != repo.CheckoutPath {
+					paired[otherIdx] = true
+					pairs = append(pairs, [2]int{otherIdx, idx})
+				}
+			}
+			reposByKey[key] = append(reposByKey[key], idx)
+		}
+	}
+
+	sort.Slice(pairs, func(a, b int) bool {
+		if pairs[a][0] != pairs[b][0] {
+			return pairs[a][0] < pairs[b][0]
+		}
+		return pairs[a][1] < pairs[b][1]
+	})
+	result := make([][2]*Repo, len(pairs))
+	for idx, pair := range pairs {
+		result[idx] = [2]*Repo{i.Repos[pair[0]], i.Repos[pair[1]]}
+	}
+	return result
+}
+
 // Rebuild implements the `rtree index` subcommand.
 func (i *Index) Rebuild() error {
 	//check if existing index entries are still checked out
 	var newRepos []*Repo
 	for _, repo := range i.Repos {
-		fi, err := os.Stat(repo.GitDirPath())
+		fi, err := os.Stat(repo.MetadataDirPath())
 		switch {
 		case err == nil:
 			// in a normal repo .git is a directory but when the repo is a submodule of another repo
@@ -131,7 +203,7 @@ func (i *Index) Rebuild() error {
 				newRepos = append(newRepos, repo)
 				continue
 			}
-			return fmt.Errorf("expected repository at %s, but is not a directory or file", repo.GitDirPath())
+			return fmt.Errorf("expected repository at %s, but is not a directory or file", repo.MetadataDirPath())
 		case !os.IsNotExist(err):
 			return err
 		}
@@ -139,23 +211,23 @@ func (i *Index) Rebuild() error {
 		//repo has been deleted - ask what to do
 		var remoteURLs []string
 		if origin, ok := repo.Remotes["origin"]; ok {
-			remoteURLs = origin.CompactURLs()
+			remoteURLs = origin.CompactURLs(i.tree.RemoteAliases)
 		} else {
 			for _, remote := range repo.Remotes {
-				remoteURLs = append(remoteURLs, remote.CompactURLs()...)
+				remoteURLs = append(remoteURLs, remote.CompactURLs(i.tree.RemoteAliases)...)
 			}
 		}
 
-		repoPath := filepath.Join(RootPath, repo.CheckoutPath)
+		repoPath := filepath.Join(i.tree.RootPath, repo.CheckoutPath)
 		var selection string
 		if len(remoteURLs) == 0 {
-			selection, err = cli.Interface.Query(
+			selection, err = i.tree.ui.Query(
 				fmt.Sprintf("repository %s has been deleted; no remote to restore from", repoPath),
 				cli.Choice{Return: "d", Shortcut: 'd', Text: "delete from index"},
 				cli.Choice{Return: "s", Shortcut: 's', Text: "skip"},
 			)
 		} else {
-			selection, err = cli.Interface.Query(
+			selection, err = i.tree.ui.Query(
 				fmt.Sprintf("repository %s has been deleted", repoPath),
 				cli.Choice{Return: "r", Shortcut: 'r', Text: "restore from " + strings.Join(remoteURLs, " and ")},
 				cli.Choice{Return: "d", Shortcut: 'd', Text: "delete from index"},
@@ -168,11 +240,12 @@ func (i *Index) Rebuild() error {
 
 		switch selection {
 		case "r":
-			err := repo.Checkout()
+			err := repo.Checkout(context.Background())
 			if err != nil {
 				return err
 			}
 			newRepos = append(newRepos, repo)
+			repo.runHooksAfterCheckout(context.Background())
 		case "d":
 			continue
 		case "s":
@@ -180,161 +253,151 @@ func (i *Index) Rebuild() error {
 		}
 	}
 
+	newRepos, _, err := i.addPhysicalRepos(context.Background(), newRepos)
+	if err != nil {
+		return err
+	}
+	i.Repos = newRepos
+	return nil
+}
+
+// addPhysicalRepos is the part of Rebuild() that scans the repository tree.
+// Index entries for repos found on disk are updated, and entries for new
+// repos are added to the given list. The updated list is returned, as well
+// as the entries that were added.
+func (i *Index) addPhysicalRepos(ctx context.Context, repos []*Repo) (newRepos, addedRepos []*Repo, err error) {
+	newRepos = repos
 	existingRepos := make(map[string]*Repo)
 	for _, repo := range newRepos {
 		existingRepos[repo.CheckoutPath] = repo
 	}
 
 	//index new repos
-	err := ForeachPhysicalRepo(func(newRepo Repo) error {
+	err = i.tree.ForeachPhysicalRepo(func(newRepo Repo) error {
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
 		repo, exists := existingRepos[newRepo.CheckoutPath]
 
 		// if a repo has no remotes, repo is nil which rtree cannot parse back and doesn't make sense to add anyway
 		if exists && repo.Remotes == nil {
-			fmt.Printf("repository %s has no remotes; skipping\n", filepath.Join(RootPath, newRepo.CheckoutPath))
+			i.tree.ui.ShowWarning(fmt.Sprintf("repository %s has no remotes; skipping", newRepo.AbsolutePath()))
 			return nil
 		}
 
 		if exists {
-			//update the existing index entry with the new remotes
+			//update the existing index entry with the new remotes (and VCS,
+			//e.g. if a Git repo has been converted into a colocated jj repo)
 			repo.Remotes = newRepo.Remotes
+			repo.VCS = newRepo.VCS
 		} else {
-			newRepos = append(newRepos, &newRepo)
+			repo = &newRepo
+			newRepos = append(newRepos, repo)
+			addedRepos = append(addedRepos, repo)
+		}
+
+		//cache root commits for fork detection (these do not change, so
+		//this only needs to be done once; if it fails, e.g. for an empty
+		//repo, it is retried next time)
+		if len(repo.RootCommits) == 0 {
+			err := repo.LoadRootCommits(ctx)
+			if err != nil && ctx.Err() == nil {
+				i.tree.ui.ShowWarning(fmt.Sprintf("cannot find root commits of %s: %s", repo.AbsolutePath(), err.Error()))
+				return nil
+			}
+			return err
 		}
 		return nil
 	})
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-
-	i.Repos = newRepos
-	return nil
+	return newRepos, addedRepos, nil
 }
 
-// FindRepo locates the repo with the given remote if it exists on disk or (if
-// allowClone is set) clones it and adds it to the index. This is the meat of
-// `rtree get`, and is also used by `rtree drop`.
+// FindRepo locates the repo with the given remote (or checkout path) if it
+// exists on disk or (if allowClone is set) clones it and adds it to the index.
+// This is the meat of `rtree get`, and is also used by `rtree drop`.
 func (i *Index) FindRepo(rawRemoteURL string, allowClone bool) (*Repo, error) {
 	//make sure that stdout is not used for prompts
-	cli.Interface.StdoutProtected = true
+	i.tree.ui.StdoutProtected = true
 
-	remoteURL := ParseRemoteURL(rawRemoteURL)
-	basename := path.Base(remoteURL.CanonicalURL())
-
-	//is this remote already checked out directly? also look for repos with the
-	//same basename that could be forks
-	var candidates []*Repo
-	for _, repo := range i.Repos {
-		isCandidate := false
-		for _, remote := range repo.Remotes {
-			for _, url := range remote.URLs {
-				// be flexible about .git ending in remote
-				if remoteURL == url || remoteURL+".git" == url || remoteURL == url+".git" {
-					return repo, nil
-				}
-				if basename == path.Base(url.CanonicalURL()) {
-					isCandidate = true
-				}
-			}
-		}
-		if isCandidate {
-			candidates = append(candidates, repo)
-		}
-	}
-
-	//double-check if the repo is already checked out, but we didn't notice it yet
-	newRepo, err := NewRepoFromRemoteURL(remoteURL)
-	if err != nil {
-		return nil, err
+	repo, newRepo, err := i.lookupRepo(rawRemoteURL)
+	if repo != nil || err != nil {
+		return repo, err
 	}
-	_, err = os.Stat(newRepo.AbsolutePath())
-	switch {
-	case err == nil:
-		return nil, fmt.Errorf(
-			"%s already exists (if there is a repo there, try `rtree index`)",
-			newRepo.AbsolutePath(),
-		)
-	case !os.IsNotExist(err):
-		return nil, err
-	}
-
 	if !allowClone {
 		return nil, errors.New("no such remote in index (you can validate the index with `rtree index`)")
 	}
+	remoteURL := newRepo.Remotes["origin"].URLs[0]
+
+	//look for repos that could be forks
+	candidates, rootCommits := i.FindForkCandidates(remoteURL)
+	newRepo.RootCommits = rootCommits
 
 	//if no fork candidates found, clone as new repo
 	if len(candidates) == 0 {
-		err := newRepo.Checkout()
-		if err != nil {
-			return nil, err
-		}
-		i.Repos = append(i.Repos, &newRepo)
-		err = i.Write()
-		return &newRepo, err
+		return i.cloneNewRepo(&newRepo, true)
 	}
 
 	//if we found fork candidates, ask the user to match the repo with a fork
 	//candidate (or confirm that the repo shall be cloned fresh)
-	if len(candidates) > 10 {
-		candidates = candidates[:10]
-	}
 	choices := make([]cli.Choice, len(candidates)+1)
-	for idx, repo := range candidates {
-		choices[idx] = cli.Choice{Text: "add as remote to " + repo.AbsolutePath(), Return: repo.CheckoutPath}
+	for idx, c := range candidates {
+		text := "add as remote to " + c.Repo.AbsolutePath()
+		if c.SharedHistory {
+			text += " (shared history)"
+		}
+		choices[idx] = cli.Choice{Text: text, Return: c.Repo.CheckoutPath}
 	}
 	choices[len(candidates)] = cli.Choice{
 		Return:   "clone",
 		Shortcut: 'n',
 		Text:     "clone to " + newRepo.AbsolutePath(),
 	}
-	selection, err := cli.Interface.Query("Found possible fork candidates. What to do?", choices...)
+	selection, err := i.tree.ui.Query("Found possible fork candidates. What to do?", choices...)
 	if err != nil {
 		return nil, err
 	}
 
 	if selection == "clone" {
-		err := newRepo.Checkout()
-		if err != nil {
-			return nil, err
-		}
-		i.Repos = append(i.Repos, &newRepo)
-		err = i.Write()
-		return &newRepo, err
+		return i.cloneNewRepo(&newRepo, false)
 	}
+	return i.addForkRemote(selectCandidate(candidates, selection), remoteURL, rootCommits)
+}
 
-	//find the repo selected by the user
-	var target *Repo
-	for _, repo := range candidates {
-		if repo.CheckoutPath == selection {
-			target = repo
-			break
+// selectCandidate returns the repo that the user chose with Query().
+func selectCandidate(candidates []ForkCandidate, checkoutPath string) *Repo {
+	for _, c := range candidates {
+		if c.Repo.CheckoutPath == checkoutPath {
+			return c.Repo
 		}
 	}
+	return nil
+}
 
+// addForkRemote is the part of FindRepo() that adds the remote to an existing
+// repo that the user has selected among the fork candidates.
+func (i *Index) addForkRemote(target *Repo, remoteURL RemoteURL, rootCommits []string) (*Repo, error) {
 	//report the existing remotes, and ask for the name of the new remote
 	var prompt strings.Builder
 	prompt.WriteString("Existing remotes:\n")
 	for remoteName, remote := range target.Remotes {
-		fmt.Fprintf(&prompt, "\t(%s) %s\n", remoteName, strings.Join(remote.CompactURLs(), " "))
+		fmt.Fprintf(&prompt, "\t(%s) %s\n", remoteName, strings.Join(remote.CompactURLs(i.tree.RemoteAliases), " "))
 	}
 	fmt.Fprintf(&prompt, "Enter remote name for %s:", remoteURL)
-	remoteName, err := cli.Interface.ReadLine(prompt.String())
+	remoteName, err := i.tree.ui.ReadLine(prompt.String())
 	if err != nil {
 		return nil, err
 	}
 
-	err = cli.Interface.Run(cli.Command{
-		Program: []string{"git", "remote", "add", remoteName, remoteURL.CompactURL()},
-		WorkDir: target.AbsolutePath(),
-	})
+	err = target.backend().AddRemote(target.AbsolutePath(), remoteName, remoteURL.ConfigURL(i.tree.RemoteAliases))
 	if err != nil {
 		return nil, err
 	}
 
-	err = cli.Interface.Run(cli.Command{
-		Program: []string{"git", "remote", "update", remoteName},
-		WorkDir: target.AbsolutePath(),
-	})
+	err = target.backend().FetchRemotes(context.Background(), target.AbsolutePath(), remoteName)
 	if err != nil {
 		return nil, err
 	}
@@ -342,25 +405,122 @@ func (i *Index) FindRepo(rawRemoteURL string, allowClone bool) (*Repo, error) {
 	target.Remotes[remoteName] = Remote{
 		URLs: []RemoteURL{remoteURL},
 	}
+	target.RootCommits = mergeRootCommits(target.RootCommits, rootCommits)
 	err = i.Write()
 	return target, err
 }
 
-// ImportRepo moves the given repo into the rtree and adds it to the index.
-func (i *Index) ImportRepo(dirPath string) error {
-	//need to make dirPath absolute first
-	dirPath, err := filepath.Abs(dirPath)
+// lookupRepo is the part of FindRepo() that does not have side effects. If
+// the repo is in the index, it is returned. Otherwise, a Repo instance for
+// cloning the remote is returned instead, after checking that its checkout
+// path is still free.
+func (i *Index) lookupRepo(rawRemoteURL string) (repo *Repo, newRepo Repo, err error) {
+	//accept checkout paths as well (the shell completion from `rtree
+	//shell-init` offers them next to the remote URLs)
+	for _, repo := range i.Repos {
+		if repo.CheckoutPath == rawRemoteURL {
+			return repo, Repo{}, nil
+		}
+	}
+
+	remoteURL := ParseRemoteURL(rawRemoteURL, i.tree.RemoteAliases)
+
+	//is this remote already checked out directly?
+	if repo := i.findRepoByRemoteURL(remoteURL); repo != nil {
+		return repo, Repo{}, nil
+	}
+
+	//double-check if the repo is already checked out, but we didn't notice it yet
+	newRepo, err = i.tree.NewRepoFromRemoteURL(remoteURL)
 	if err != nil {
-		return err
+		return nil, Repo{}, err
 	}
-	repo, err := NewRepoFromAbsolutePath(dirPath, true)
+	_, err = os.Stat(newRepo.AbsolutePath())
+	switch {
+	case err == nil:
+		return nil, Repo{}, fmt.Errorf(
+			"%s already exists (if there is a repo there, try `rtree index`)",
+			newRepo.AbsolutePath(),
+		)
+	case !os.IsNotExist(err):
+		return nil, Repo{}, err
+	}
+	return nil, newRepo, nil
+}
+
+// cloneNewRepo is the part of FindRepo() that clones a repo into its own
+// checkout path. If lookForForks is set, the user is offered to add the
+// remote to an existing repo with shared history instead (this catches forks
+// that were renamed, which FindForkCandidates cannot find before cloning).
+func (i *Index) cloneNewRepo(newRepo *Repo, lookForForks bool) (*Repo, error) {
+	err := newRepo.Checkout(context.Background())
 	if err != nil {
-		return err
+		return nil, err
 	}
+	i.Repos = append(i.Repos, newRepo)
+	newRepo.runHooksAfterCheckout(context.Background())
 
-	//repo must be outside $GOPATH/src
-	if !strings.HasPrefix(repo.CheckoutPath, "../") {
-		return fmt.Errorf("%s is already inside GOPATH", dirPath)
+	//if the remote was not probed, take the root commits from the clone
+	//(which does not exist in dry-run mode)
+	if len(newRepo.RootCommits) == 0 && !i.tree.dryRun {
+		err := newRepo.LoadRootCommits(context.Background())
+		if err != nil {
+			i.tree.ui.ShowWarning(fmt.Sprintf("cannot find root commits of %s: %s", newRepo.AbsolutePath(), err.Error()))
+		}
+	}
+	//the clone has succeeded, so it needs to go into the index even if the
+	//next steps fail
+	var offerErr error
+	if lookForForks {
+		if candidates := i.findSharedHistory(newRepo); len(candidates) > 0 {
+			target, err := i.querySharedHistory(newRepo, candidates)
+			switch {
+			case err != nil:
+				offerErr = err
+			case target != nil:
+				err := i.removeRepo(newRepo)
+				if err != nil {
+					return nil, err
+				}
+				return i.addForkRemote(target, newRepo.Remotes["origin"].URLs[0], newRepo.RootCommits)
+			}
+		}
+	}
+	if offerErr == nil {
+		offerErr = i.OfferForkParent(context.Background(), newRepo)
+	}
+	err = i.Write()
+	if err == nil {
+		err = offerErr
+	}
+	return newRepo, err
+}
+
+// querySharedHistory is the part of cloneNewRepo() that asks the user whether
+// the new clone shall be replaced by a remote in one of the given repos with
+// shared history. Returns nil if the clone shall be kept.
+func (i *Index) querySharedHistory(newRepo *Repo, candidates []ForkCandidate) (*Repo, error) {
+	choices := make([]cli.Choice, len(candidates)+1)
+	for idx, c := range candidates {
+		choices[idx] = cli.Choice{Text: "add as remote to " + c.Repo.AbsolutePath() + " (shared history)", Return: c.Repo.CheckoutPath}
+	}
+	choices[len(candidates)] = cli.Choice{
+		Return:   "keep",
+		Shortcut: 'k',
+		Text:     "keep the clone at " + newRepo.AbsolutePath(),
+	}
+	selection, err := i.tree.ui.Query("The new clone shares history with other repos. What to do?", choices...)
+	if err != nil {
+		return nil, err
+	}
+	return selectCandidate(candidates, selection), nil
+}
+
+// ImportRepo moves the given repo into the rtree and adds it to the index.
+func (i *Index) ImportRepo(dirPath string) error {
+	repo, err := i.tree.newRepoForImport(dirPath)
+	if err != nil {
+		return err
 	}
 
 	//select the remote which determines the checkout path
@@ -386,13 +546,19 @@ func (i *Index) ImportRepo(dirPath string) error {
 			return errors.New("repo has no remotes")
 		}
 
-		question := fmt.Sprintf("Repo has multiple remotes. Where to put below %s?", RootPath)
-		checkoutPath, err = cli.Interface.Query(question, choices...)
+		question := fmt.Sprintf("Repo has multiple remotes. Where to put below %s?", i.tree.RootPath)
+		checkoutPath, err = i.tree.ui.Query(question, choices...)
 		if err != nil {
 			return err
 		}
 	}
 
+	return i.moveIntoTree(&repo, checkoutPath)
+}
+
+// moveIntoTree is the part of ImportRepo() that happens after the checkout
+// path has been chosen. A symlink is left at the original location.
+func (i *Index) moveIntoTree(repo *Repo, checkoutPath string) error {
 	//double-check that there is no such repo in the rtree yet
 	for _, other := range i.Repos {
 		if other.CheckoutPath == checkoutPath {
@@ -401,26 +567,57 @@ func (i *Index) ImportRepo(dirPath string) error {
 	}
 
 	//do the move
-	err = repo.Move(checkoutPath, true)
+	originalPath := repo.AbsolutePath()
+	err := repo.Move(checkoutPath, true)
 	if err != nil {
 		return err
 	}
-	i.Repos = append(i.Repos, &repo)
+	repo.ImportedFrom = originalPath
+	i.Repos = append(i.Repos, repo)
 	return nil
 }
 
+// newRepoForImport scans the repo at the given path, which must be outside
+// the RootPath.
+func (t *RTree) newRepoForImport(dirPath string) (Repo, error) {
+	//need to make dirPath absolute first
+	dirPath, err := filepath.Abs(dirPath)
+	if err != nil {
+		return Repo{}, err
+	}
+	repo, err := t.NewRepoFromAbsolutePath(dirPath, true)
+	if err != nil {
+		return Repo{}, err
+	}
+
+	//repo must be outside $GOPATH/src
+	if !strings.HasPrefix(repo.CheckoutPath, "../") {
+		return Repo{}, fmt.Errorf("%s is already inside GOPATH", dirPath)
+	}
+	return repo, nil
+}
+
 // DropRepo deletes the given repo from the rtree and removes it from the index.
 func (i *Index) DropRepo(repo *Repo) error {
-	err := repo.Exec("git", "status")
+	repo.tree.ui.ShowProgress(repo.AbsolutePath())
+	err := repo.tree.ui.Run(cli.Command{
+		Program:  repo.backend().StatusCommand(),
+		WorkDir:  repo.AbsolutePath(),
+		ReadOnly: true,
+	})
 	if err != nil {
 		return err
 	}
-	ok, err := cli.Interface.Confirm(">> Drop this repo?")
+	ok, err := i.tree.ui.Confirm(">> Drop this repo?")
 	if !ok || err != nil {
 		return err
 	}
+	return i.removeRepo(repo)
+}
 
-	err = os.RemoveAll(repo.AbsolutePath())
+// removeRepo is the part of DropRepo() that happens after confirmation.
+func (i *Index) removeRepo(repo *Repo) error {
+	err := i.tree.fs.RemoveAll(repo.AbsolutePath())
 	if err != nil {
 		return err
 	}
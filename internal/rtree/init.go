@@ -4,6 +4,8 @@
 package rtree
 
 import (
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -17,74 +19,196 @@ import (
 type RemoteAlias struct {
 	Alias       string
 	Replacement string
+	//If true, this alias comes from a "url.<base>.pushInsteadOf" directive,
+	//i.e. URLs starting with Alias are rewritten into Replacement only when
+	//pushing.
+	Push bool
+	//Where this alias was defined, as reported by `git config --show-origin`
+	//(e.g. "file:/etc/gitconfig"). This is only used for display purposes.
+	Origin string
 }
 
-// IndexPath is where the index file is stored.
-var IndexPath string
-
-// OldIndexPath is where the old index file was stored.
-// This is only used to detect if a system has not been upgraded to the new index format yet.
-// TODO: remove this after some time
-var OldIndexPath string
-
-// RootPath is the directory below which all repositories are located. Its value
-// is $GOPATH/src to match the repository layout created by `go get`.
-var RootPath string
-
-// RemoteAliases is the list of remote aliases that is used by ExpandRemoteURL().
-var RemoteAliases []*RemoteAlias
-
-// Init initializes the global variables of this package to their standard
-// values, unless they are already populated. Unit tests shall set IndexPath,
-// RootPath etc. before calling Exec(), such that this function becomes a no-op
-// when called by Exec().
-//
-// Returns false if initialization failed.
-func Init() bool {
-	ok := true //until shown otherwise
-
-	if IndexPath == "" {
-		homeDir := os.Getenv("HOME")
-		if homeDir == "" {
-			cli.Interface.ShowError("$HOME is not set (rtree needs the HOME variable to locate its index file)")
-			ok = false //but keep going to report all errors at once
-		} else {
-			IndexPath = filepath.Join(homeDir, ".config/rtree/index.json")
-			OldIndexPath = filepath.Join(homeDir, ".rtree/index.yaml")
-		}
-	}
+// RTree is a repository tree, i.e. a directory containing repos at paths
+// derived from their remote URLs, plus the index file that lists these repos.
+// All state lives in this object, so several instances with different settings
+// can be used side by side.
+type RTree struct {
+	//RootPath is the directory below which all repositories are located.
+	RootPath string
+	//IndexPath is where the index file is stored.
+	IndexPath string
+	//OldIndexPath is where the index file was stored in the legacy YAML
+	//format. If there is no index file at IndexPath yet, the legacy index file
+	//is migrated automatically.
+	OldIndexPath string
+	//ConfigPath is where the config file is stored.
+	ConfigPath string
+	//HistoryPath is where `rtree get` records when repos were accessed. If
+	//empty, accesses are not recorded.
+	HistoryPath string
+	//Config contains the settings from the config file.
+	Config *Configuration
+	//RemoteAliases is the list of remote aliases that is used by ParseRemoteURL() etc.
+	RemoteAliases []*RemoteAlias
 
-	if RootPath == "" {
-		gopath := os.Getenv("GOPATH")
-		if gopath == "" {
-			cli.Interface.ShowError("$GOPATH is not set (rtree needs the GOPATH variable to know where to look for and place repos)")
-			ok = false //but keep going to report all errors at once
-		} else {
-			RootPath = filepath.Join(gopath, "src")
-		}
+	//ui wraps access to the CLI, including input, output and subprocesses.
+	ui *cli.Implementation
+	//fs performs all changes to the filesystem.
+	fs fileSystem
+	//dryRun is set by enableDryRun().
+	dryRun bool
+}
+
+// Options contains the settings for New(). Only RootPath and IndexPath are
+// required.
+type Options struct {
+	RootPath     string
+	IndexPath    string
+	OldIndexPath string
+	HistoryPath  string
+	//If Config is nil, it is read from ConfigPath (if given).
+	ConfigPath    string
+	Config        *Configuration
+	RemoteAliases []*RemoteAlias
+	//Stdin, Stdout and Stderr are given to subprocesses, and are used for
+	//prompts and messages. If not given, there is no input, and all output
+	//is discarded.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	//CommandRunner executes subprocesses. The default is cli.DefaultCommandRunner.
+	CommandRunner cli.CommandRunner
+	//If DryRun is true, all commands and filesystem operations that would
+	//change anything are only reported on Stderr. Commands that only read
+	//(e.g. `git config -l`) are still executed.
+	DryRun bool
+}
+
+// New creates an RTree instance with the given settings.
+func New(opts Options) (*RTree, error) {
+	if opts.Stdin == nil {
+		opts.Stdin = strings.NewReader("")
+	}
+	if opts.Stdout == nil {
+		opts.Stdout = io.Discard
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = io.Discard
 	}
+	if opts.CommandRunner == nil {
+		opts.CommandRunner = cli.DefaultCommandRunner
+	}
+	return newWithUI(opts, cli.NewImplementation(opts.Stdin, opts.Stdout, opts.Stderr, opts.CommandRunner))
+}
 
-	if RemoteAliases == nil {
-		out, err := cli.Interface.CaptureStdout(cli.Command{
-			Program: []string{"git", "config", "--global", "-l"},
-		})
-		if err != nil {
-			cli.Interface.ShowError(err.Error())
-			ok = false //but keep going to report all errors at once
-		}
+// newWithUI is like New, but uses the given cli.Implementation instead of
+// creating one from the streams in the Options.
+func newWithUI(opts Options, ui *cli.Implementation) (*RTree, error) {
+	var errs []error
+	if opts.RootPath == "" {
+		errs = append(errs, errors.New("no root path given"))
+	}
+	if opts.IndexPath == "" {
+		errs = append(errs, errors.New("no index path given"))
+	}
 
-		rx := regexp.MustCompile(`^url\.([^=]+)\.insteadof=(.+)$`)
-		for line := range strings.SplitSeq(out, "\n") {
-			match := rx.FindStringSubmatch(line)
-			if match == nil {
-				continue
+	cfg := opts.Config
+	if cfg == nil {
+		cfg = &Configuration{}
+		if opts.ConfigPath != "" {
+			var err error
+			cfg, err = ReadConfig(opts.ConfigPath)
+			if err != nil {
+				errs = append(errs, err)
 			}
-			RemoteAliases = append(RemoteAliases, &RemoteAlias{
-				Alias:       match[2],
-				Replacement: match[1],
-			})
 		}
 	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	t := &RTree{
+		RootPath:      opts.RootPath,
+		IndexPath:     opts.IndexPath,
+		OldIndexPath:  opts.OldIndexPath,
+		ConfigPath:    opts.ConfigPath,
+		HistoryPath:   opts.HistoryPath,
+		Config:        cfg,
+		RemoteAliases: opts.RemoteAliases,
+		ui:            ui,
+		fs:            osFileSystem{},
+	}
+	if opts.DryRun {
+		t.enableDryRun()
+	}
+	return t, nil
+}
+
+// DefaultOptions returns the settings that the `rtree` command uses: The index,
+// config and history file are located in ~/.config/rtree, the repos are located in
+// $GOPATH/src to match the repository layout created by `go get`, and remote
+// aliases are taken from the system-wide and user-global Git config.
+func DefaultOptions() (Options, error) {
+	return defaultOptions(cli.NewImplementation(os.Stdin, os.Stdout, os.Stderr, cli.DefaultCommandRunner))
+}
+
+func defaultOptions(ui *cli.Implementation) (opts Options, err error) {
+	var errs []error //collect all errors to report them at once
+
+	homeDir := os.Getenv("HOME")
+	if homeDir == "" {
+		errs = append(errs, errors.New("$HOME is not set (rtree needs the HOME variable to locate its index file)"))
+	} else {
+		opts.IndexPath = filepath.Join(homeDir, ".config/rtree/index.json")
+		opts.OldIndexPath = filepath.Join(homeDir, ".rtree/index.yaml")
+		opts.ConfigPath = filepath.Join(homeDir, ".config/rtree/config.json")
+		opts.HistoryPath = filepath.Join(homeDir, ".config/rtree/history.json")
+	}
+
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		errs = append(errs, errors.New("$GOPATH is not set (rtree needs the GOPATH variable to know where to look for and place repos)"))
+	} else {
+		opts.RootPath = filepath.Join(gopath, "src")
+	}
 
-	return ok
+	//NOTE: Without an explicit scope, `git config` would also report the
+	//repo-local config of whatever repo we're running in, so we filter
+	//by scope instead of using `--system` and `--global`. (The latter
+	//would also disable processing of includes by default.)
+	out, err := ui.CaptureStdout(cli.Command{
+		Program:  []string{"git", "config", "-l", "--includes", "--show-scope", "--show-origin"},
+		ReadOnly: true,
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	opts.RemoteAliases = parseRemoteAliases(out)
+
+	return opts, errors.Join(errs...)
+}
+
+var remoteAliasConfigRx = regexp.MustCompile(`^url\.([^=]+)\.(insteadof|pushinsteadof)=(.+)$`)
+
+// parseRemoteAliases extracts the remote aliases from the output of `git
+// config -l --show-scope --show-origin`. Only the system-wide and user-global
+// config (including all files included from there) are considered.
+func parseRemoteAliases(out string) (result []*RemoteAlias) {
+	for line := range strings.SplitSeq(out, "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 || (fields[0] != "system" && fields[0] != "global") {
+			continue
+		}
+		match := remoteAliasConfigRx.FindStringSubmatch(fields[2])
+		if match == nil {
+			continue
+		}
+		result = append(result, &RemoteAlias{
+			Alias:       match[3],
+			Replacement: match[1],
+			Push:        match[2] == "pushinsteadof",
+			Origin:      fields[1],
+		})
+	}
+	return result
 }
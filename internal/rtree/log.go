@@ -0,0 +1,225 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"git.xyrillian.de/gofu/internal/cli"
+)
+
+// LogOptions contains the options for `rtree log`.
+type LogOptions struct {
+	//Since is given to `git log --since`, so it can be anything that Git
+	//understands (e.g. "yesterday" or "2 weeks ago").
+	Since string
+	//Author is given to `git log --author`. The special value "me" refers to
+	//the user.email from the Git config of each repo.
+	Author string
+	//If true, the commits from all repos are shown in a single list, ordered
+	//by date. Otherwise, commits are grouped by repo.
+	Chronological bool
+	//If true, the report is printed as JSON instead of text.
+	JSON bool
+}
+
+// LogCommit is a commit as reported by `rtree log`.
+type LogCommit struct {
+	//Repo is the checkout path of the repo containing the commit.
+	Repo        string    `json:"repo"`
+	Hash        string    `json:"hash"`
+	AuthorName  string    `json:"author_name"`
+	AuthorEmail string    `json:"author_email"`
+	Date        time.Time `json:"date"`
+	Subject     string    `json:"subject"`
+}
+
+// logResult is the outcome of `git log` in a single repo.
+type logResult struct {
+	Commits []LogCommit
+	Err     error
+}
+
+// The fields of the format are separated by NUL bytes, since the subject can
+// contain anything else.
+const logFormat = "--format=%H%x00%an%x00%ae%x00%aI%x00%s"
+
+// CollectLog runs `git log` concurrently in all Git repos, and returns the
+// matching commits on local branches (except merge commits), newest first.
+// Repos that cannot be queried are reported as errors, but do not prevent
+// the other repos from being reported.
+func (i *Index) CollectLog(opts LogOptions) ([]LogCommit, []error) {
+	var repos []*Repo
+	for _, repo := range i.Repos {
+		//Mercurial and non-colocated Jujutsu repos cannot be queried with `git log`
+		if repo.HasGitDir() {
+			repos = append(repos, repo)
+		}
+	}
+
+	results := make([]logResult, len(repos))
+	foreachRepoConcurrently(repos, func(idx int, repo *Repo) {
+		results[idx] = repo.log(opts)
+	})
+
+	var (
+		commits []LogCommit
+		errs    []error
+	)
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+		commits = append(commits, result.Commits...)
+	}
+	//the sort is stable, so commits at the same time remain in index order
+	slices.SortStableFunc(commits, func(left, right LogCommit) int {
+		return right.Date.Compare(left.Date)
+	})
+	return commits, errs
+}
+
+func (r *Repo) log(opts LogOptions) logResult {
+	repoPath := r.AbsolutePath()
+	cmdline := []string{"git", "log", "--branches", "--no-merges", logFormat}
+	if opts.Since != "" {
+		cmdline = append(cmdline, "--since="+opts.Since)
+	}
+	switch opts.Author {
+	case "":
+	case "me":
+		out, err := r.tree.ui.CaptureStdout(cli.Command{
+			Program:  []string{"git", "config", "user.email"},
+			WorkDir:  repoPath,
+			ReadOnly: true,
+		})
+		email := strings.TrimSpace(out)
+		if err != nil || email == "" {
+			return logResult{Err: fmt.Errorf("cannot find out who \"me\" is in %s: user.email is not set", repoPath)}
+		}
+		//the email is not a pattern (e.g. "+" in "me+git@example.org" is
+		//literal); the escapes from QuoteMeta only work for extended regexes
+		//since e.g. "\+" is a quantifier in Git's default basic regexes
+		cmdline = append(cmdline, "--extended-regexp", "--author="+regexp.QuoteMeta(email))
+	default:
+		cmdline = append(cmdline, "--author="+opts.Author)
+	}
+	stdout, stderr, err := r.tree.ui.CaptureOutput(cli.Command{Program: cmdline, WorkDir: repoPath, ReadOnly: true})
+	if err != nil {
+		return logResult{Err: fmt.Errorf("log in %s: %s", repoPath, gitErrorMessage(stderr, err))}
+	}
+
+	var result logResult
+	for line := range strings.SplitSeq(strings.TrimSpace(stdout), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "\x00", 5)
+		if len(fields) != 5 {
+			return logResult{Err: fmt.Errorf("log in %s: unexpected output line %q", repoPath, line)}
+		}
+		date, err := time.Parse(time.RFC3339, fields[3])
+		if err != nil {
+			return logResult{Err: fmt.Errorf("log in %s: %w", repoPath, err)}
+		}
+		result.Commits = append(result.Commits, LogCommit{
+			Repo:        r.CheckoutPath,
+			Hash:        fields[0],
+			AuthorName:  fields[1],
+			AuthorEmail: fields[2],
+			Date:        date,
+			Subject:     fields[4],
+		})
+	}
+	return result
+}
+
+// logRepoGroup is how commits are grouped by repo in the JSON output.
+type logRepoGroup struct {
+	Repo    string      `json:"repo"`
+	Commits []LogCommit `json:"commits"`
+}
+
+// commandLog implements `rtree log`: It shows the commits from all repos,
+// either grouped by repo or in a single chronological list.
+func commandLog(index *Index, opts LogOptions) error {
+	commits, errs := index.CollectLog(opts)
+	for _, err := range errs {
+		index.tree.ui.ShowError(err.Error())
+	}
+
+	//group commits by repo (in index order)
+	var groups []logRepoGroup
+	if !opts.Chronological {
+		for _, repo := range index.Repos {
+			group := logRepoGroup{Repo: repo.CheckoutPath}
+			for _, c := range commits {
+				if c.Repo == repo.CheckoutPath {
+					group.Commits = append(group.Commits, c)
+				}
+			}
+			if len(group.Commits) > 0 {
+				groups = append(groups, group)
+			}
+		}
+	}
+
+	switch {
+	case opts.JSON:
+		var (
+			buf []byte
+			err error
+		)
+		if opts.Chronological {
+			buf, err = json.MarshalIndent(nonNil(commits), "", "  ")
+		} else {
+			buf, err = json.MarshalIndent(nonNil(groups), "", "  ")
+		}
+		if err != nil {
+			return err
+		}
+		index.tree.ui.ShowResult(string(buf))
+	case opts.Chronological:
+		for _, c := range commits {
+			index.tree.ui.ShowResult(fmt.Sprintf("%s  %s  %s  %s", c.Date.Format("2006-01-02 15:04"), c.Repo, shortHash(c.Hash), c.Subject))
+		}
+	default:
+		for idx, group := range groups {
+			if idx > 0 {
+				index.tree.ui.ShowResult("")
+			}
+			index.tree.ui.ShowResult(group.Repo)
+			for _, c := range group.Commits {
+				//ShowResult() trims leading whitespace, so the indentation
+				//cannot be done with spaces
+				index.tree.ui.ShowResult(fmt.Sprintf("- %s  %s  %s", c.Date.Format("2006-01-02 15:04"), shortHash(c.Hash), c.Subject))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("could not collect commits from %d repos", len(errs))
+	}
+	return nil
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 10 {
+		return hash[:10]
+	}
+	return hash
+}
+
+// nonNil makes sure that an empty list is serialized as `[]` instead of `null`.
+func nonNil[T any](list []T) []T {
+	if list == nil {
+		return []T{}
+	}
+	return list
+}
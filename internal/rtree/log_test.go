@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// mustCommit creates a commit with the given author and date in an actual Git repo.
+func mustCommit(t *testing.T, repoPath, authorEmail, date, subject string) {
+	cmd := exec.Command("git", "-C", repoPath, "commit", "--quiet", "--allow-empty", "-m", subject)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Someone", "GIT_AUTHOR_EMAIL="+authorEmail, "GIT_AUTHOR_DATE="+date,
+		"GIT_COMMITTER_NAME=Someone", "GIT_COMMITTER_EMAIL="+authorEmail, "GIT_COMMITTER_DATE="+date,
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git commit: %s: %s", err.Error(), out)
+	}
+}
+
+func TestLog(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	rootPath := withTemporaryRootPath(t)
+	index := Index{}
+	for _, checkoutPath := range []string{"example.org/bar", "example.org/foo"} {
+		repoPath := filepath.Join(rootPath, checkoutPath)
+		mustRunGit(t, "init", "--quiet", repoPath)
+		mustRunGit(t, "-C", repoPath, "config", "user.email", "me+git@example.org")
+		index.Repos = append(index.Repos, &Repo{
+			CheckoutPath: checkoutPath,
+			Remotes:      map[string]Remote{"origin": {URLs: []RemoteURL{RemoteURL("https://" + checkoutPath)}}},
+		})
+	}
+	fooPath := filepath.Join(rootPath, "example.org/foo")
+	barPath := filepath.Join(rootPath, "example.org/bar")
+	mustCommit(t, fooPath, "me+git@example.org", "2025-12-24T12:00:00Z", "too old")
+	mustCommit(t, fooPath, "me+git@example.org", "2026-01-02T09:00:00Z", "first")
+	mustCommit(t, barPath, "me+git@example.org", "2026-01-02T10:00:00Z", "second")
+	mustCommit(t, barPath, "me+git@example-org", "2026-01-02T11:00:00Z", "not mine") //the "." in the email of "me" is not a wildcard
+	mustCommit(t, fooPath, "me+git@example.org", "2026-01-03T08:30:00Z", "third")
+
+	run := func(args ...string) string {
+		t.Helper()
+		opts := testTreeOptions(t)
+		var stdout bytes.Buffer
+		opts.Stdout = &stdout
+		err := writeIndexFixture(opts.IndexPath, index)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		tree, err := New(opts)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		exitCode := tree.exec(args)
+		if exitCode != 0 {
+			t.Errorf("%v: expected exit code 0, but got %d", args, exitCode)
+		}
+		return stdout.String()
+	}
+
+	actual := run("log", "--since=2026-01-01", "--author=me")
+	expected := "example.org/bar\n" +
+		"- 2026-01-02 10:00  " + shortHash(gitRevParse(t, barPath, "HEAD~")) + "  second\n" +
+		"\n" +
+		"example.org/foo\n" +
+		"- 2026-01-03 08:30  " + shortHash(gitRevParse(t, fooPath, "HEAD")) + "  third\n" +
+		"- 2026-01-02 09:00  " + shortHash(gitRevParse(t, fooPath, "HEAD~")) + "  first\n"
+	if actual != expected {
+		t.Errorf("expected grouped output %q, but got %q", expected, actual)
+	}
+
+	actual = run("log", "--since=2026-01-01", "--chronological")
+	expected = "2026-01-03 08:30  example.org/foo  " + shortHash(gitRevParse(t, fooPath, "HEAD")) + "  third\n" +
+		"2026-01-02 11:00  example.org/bar  " + shortHash(gitRevParse(t, barPath, "HEAD")) + "  not mine\n" +
+		"2026-01-02 10:00  example.org/bar  " + shortHash(gitRevParse(t, barPath, "HEAD~")) + "  second\n" +
+		"2026-01-02 09:00  example.org/foo  " + shortHash(gitRevParse(t, fooPath, "HEAD~")) + "  first\n"
+	if actual != expected {
+		t.Errorf("expected chronological output %q, but got %q", expected, actual)
+	}
+
+	var groups []logRepoGroup
+	err := json.Unmarshal([]byte(run("log", "--since=2026-01-01", "--author=me", "--json")), &groups)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(groups) != 2 || len(groups[0].Commits) != 1 || len(groups[1].Commits) != 2 || groups[1].Commits[0].Subject != "third" {
+		t.Errorf("unexpected JSON output: %#v", groups)
+	}
+}
+
+func gitRevParse(t *testing.T, repoPath, rev string) string {
+	out, err := exec.Command("git", "-C", repoPath, "rev-parse", rev).Output()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return string(bytes.TrimSpace(out))
+}
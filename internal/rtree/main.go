@@ -4,7 +4,10 @@
 package rtree
 
 import (
+	"errors"
+	"fmt"
 	"strings"
+	"time"
 
 	"git.xyrillian.de/gofu/internal/cli"
 )
@@ -15,18 +18,86 @@ import (
 // executing other programs) pass through cli.Interface and can be intercepted
 // there for the purpose of testing.
 func Exec(args []string) int {
-	if !Init() {
+	return execWithUI(args, cli.Interface)
+}
+
+// execWithUI is like Exec, but uses the given cli.Implementation instead of
+// cli.Interface.
+func execWithUI(args []string, ui *cli.Implementation) int {
+	opts, err := defaultOptions(ui)
+	var tree *RTree
+	if err == nil {
+		tree, err = newWithUI(opts, ui)
+	}
+	if err != nil {
+		//report all errors at once
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, err := range joined.Unwrap() {
+				ui.ShowError(err.Error())
+			}
+		} else {
+			ui.ShowError(err.Error())
+		}
 		return 1
 	}
+	stop := ui.HandleInterrupts()
+	defer stop()
+	exitCode := tree.exec(args)
+	if ui.Interrupted() {
+		//like a shell does for processes that were killed by SIGINT
+		return 130
+	}
+	return exitCode
+}
 
+// exec is the part of Exec() that runs after the RTree has been set up.
+func (t *RTree) exec(args []string) int {
+	if len(args) > 0 && args[0] == "--dry-run" {
+		t.enableDryRun()
+		args = args[1:]
+	}
 	if len(args) == 0 {
-		return usage()
+		return t.usage()
+	}
+
+	switch args[0] {
+	case "shell-init":
+		//does not need the index, so it works even before the first `rtree get`
+		var err error
+		switch {
+		case len(args) == 2:
+			err = commandShellInit(t.ui, args[1], false)
+		case len(args) == 3 && args[2] == "--auto-cd":
+			err = commandShellInit(t.ui, args[1], true)
+		default:
+			return t.usage()
+		}
+		if err != nil {
+			t.ui.ShowError(err.Error())
+			return 1
+		}
+		return 0
+	case "complete":
+		//runs on every <Tab>, where error messages would garble the command line
+		if len(args) != 1 {
+			return 1
+		}
+		index, errs := t.readIndex()
+		if len(errs) > 0 {
+			return 1
+		}
+		commandComplete(index)
+		return 0
 	}
 
-	index, errs := ReadIndex()
+	index, errs := t.readIndex()
 	if len(errs) > 0 {
+		//`rtree doctor` can deal with an index that fails validation
+		if index != nil && args[0] == "doctor" {
+			return commandDoctorWithArgs(index, errs, args[1:])
+		}
 		for _, err := range errs {
-			cli.Interface.ShowError(err.Error())
+			t.ui.ShowError(err.Error())
 		}
 		return 1
 	}
@@ -35,60 +106,214 @@ func Exec(args []string) int {
 	switch args[0] {
 	case "get":
 		if len(args) != 2 {
-			return usage()
+			return t.usage()
 		}
 		err = commandGet(index, args[1])
+	case "new":
+		var (
+			opts NewRepoOptions
+			url  string
+		)
+		for _, arg := range args[1:] {
+			switch arg {
+			case "--forge":
+				opts.CreateOnForge = true
+			case "--private":
+				opts.Private = true
+			default:
+				if url != "" || strings.HasPrefix(arg, "-") {
+					return t.usage()
+				}
+				url = arg
+			}
+		}
+		if url == "" || (opts.Private && !opts.CreateOnForge) {
+			return t.usage()
+		}
+		err = commandNew(index, url, opts)
+	case "get-all":
+		var (
+			opts  GetAllOptions
+			owner string
+		)
+		for _, arg := range args[1:] {
+			switch arg {
+			case "--skip-archived":
+				opts.SkipArchived = true
+			case "--skip-forks":
+				opts.SkipForks = true
+			case "--ssh":
+				opts.UseSSH = true
+			default:
+				if owner != "" || strings.HasPrefix(arg, "-") {
+					return t.usage()
+				}
+				owner = arg
+			}
+		}
+		if owner == "" {
+			return t.usage()
+		}
+		err = commandGetAll(index, owner, opts)
 	case "drop":
 		if len(args) != 2 {
-			return usage()
+			return t.usage()
 		}
 		err = commandDrop(index, args[1])
 	case "index":
 		if len(args) != 1 {
-			return usage()
+			return t.usage()
 		}
 		err = commandIndex(index)
 	case "repos":
 		if len(args) != 1 {
-			return usage()
+			return t.usage()
 		}
 		commandRepos(index)
 	case "remotes":
 		if len(args) != 1 {
-			return usage()
+			return t.usage()
 		}
 		commandRemotes(index)
+	case "aliases":
+		if len(args) != 1 {
+			return t.usage()
+		}
+		commandAliases(index)
+	case "check-remotes":
+		if len(args) != 1 {
+			return t.usage()
+		}
+		return commandCheckRemotes(index)
+	case "sync-heads":
+		if len(args) != 1 {
+			return t.usage()
+		}
+		return commandSyncHeads(index)
+	case "recent":
+		if len(args) != 1 {
+			return t.usage()
+		}
+		err = commandRecent(index)
 	case "import":
 		if len(args) != 2 {
-			return usage()
+			return t.usage()
 		}
 		err = commandImport(index, args[1])
 	case "each":
 		if len(args) < 2 {
-			return usage()
+			return t.usage()
 		}
 		return commandEach(index, args[1:])
+	case "grep":
+		var (
+			opts    GrepOptions
+			pattern string
+		)
+		for idx := 1; idx < len(args); idx++ {
+			switch arg := args[idx]; {
+			case arg == "-l":
+				opts.FilesWithMatches = true
+			case arg == "--match" && idx+1 < len(args):
+				idx++
+				opts.Match = append(opts.Match, args[idx])
+			case strings.HasPrefix(arg, "--match="):
+				opts.Match = append(opts.Match, strings.TrimPrefix(arg, "--match="))
+			default:
+				if pattern != "" || strings.HasPrefix(arg, "-") {
+					return t.usage()
+				}
+				pattern = arg
+			}
+		}
+		if pattern == "" {
+			return t.usage()
+		}
+		return commandGrep(index, pattern, opts)
+	case "log":
+		var opts LogOptions
+		for _, arg := range args[1:] {
+			switch {
+			case strings.HasPrefix(arg, "--since="):
+				opts.Since = strings.TrimPrefix(arg, "--since=")
+			case strings.HasPrefix(arg, "--author="):
+				opts.Author = strings.TrimPrefix(arg, "--author=")
+			case arg == "--chronological":
+				opts.Chronological = true
+			case arg == "--json":
+				opts.JSON = true
+			default:
+				return t.usage()
+			}
+		}
+		if opts.Since == "" {
+			opts.Since = "yesterday"
+		}
+		err = commandLog(index, opts)
+	case "which":
+		switch len(args) {
+		case 1:
+			err = commandWhich(index, "")
+		case 2:
+			err = commandWhich(index, args[1])
+		default:
+			return t.usage()
+		}
+	case "hooks":
+		if len(args) < 2 || len(args) > 3 || args[1] != "run" {
+			return t.usage()
+		}
+		err = commandHooksRun(index, args[2:])
+	case "cache":
+		if len(args) != 2 {
+			return t.usage()
+		}
+		err = commandCache(index, args[1])
+	case "backup":
+		if len(args) != 2 {
+			return t.usage()
+		}
+		err = commandBackup(index, args[1])
+	case "restore-backup":
+		if len(args) != 2 {
+			return t.usage()
+		}
+		err = commandRestoreBackup(index, args[1])
+	case "doctor":
+		return commandDoctorWithArgs(index, nil, args[1:])
 	default:
-		return usage()
+		return t.usage()
 	}
 
 	if err == nil {
 		return 0
 	}
-	cli.Interface.ShowError(err.Error())
+	t.ui.ShowError(err.Error())
 	return 1
 }
 
 var usageStr = strings.TrimSpace(`
 Usage:
+  rtree [--dry-run] <command> [<args>...]
   rtree [get|drop] <url>
-  rtree [index|repos|remotes]
+  rtree new [--forge [--private]] <url>
+  rtree get-all [--skip-archived] [--skip-forks] [--ssh] <forge>:<owner>
+  rtree [index|repos|remotes|aliases|recent]
   rtree import <path>
+  rtree which [<path>]
+  rtree doctor [--fix]
+  rtree [check-remotes|sync-heads]
+  rtree hooks run [<path>|--all]
+  rtree cache [gc|dissociate]
+  rtree [backup|restore-backup] <dir>
   rtree each <command>
+  rtree grep [-l] [--match <glob>]... <pattern>
+  rtree log [--since=<date>] [--author=<pattern>|me] [--chronological] [--json]
+  rtree shell-init [bash|zsh|fish] [--auto-cd]
 `)
 
-func usage() int {
-	cli.Interface.ShowUsage(usageStr)
+func (t *RTree) usage() int {
+	t.ui.ShowUsage(usageStr)
 	return 1
 }
 
@@ -97,7 +322,13 @@ func commandGet(index *Index, url string) error {
 	if err != nil {
 		return err
 	}
-	cli.Interface.ShowResult(repo.AbsolutePath())
+	index.tree.ui.ShowResult(repo.AbsolutePath())
+
+	//failing to record the access should not prevent `cg` from working
+	err = index.RecordAccess(repo, time.Now())
+	if err != nil {
+		index.tree.ui.ShowWarning("could not record access in history: " + err.Error())
+	}
 	return nil
 }
 
@@ -132,7 +363,7 @@ func commandRepos(index *Index) {
 	for _, repo := range index.Repos {
 		items = append(items, repo.CheckoutPath)
 	}
-	cli.Interface.ShowResultsSorted(items)
+	index.tree.ui.ShowResultsSorted(items)
 }
 
 func commandRemotes(index *Index) {
@@ -140,11 +371,38 @@ func commandRemotes(index *Index) {
 	for _, repo := range index.Repos {
 		for _, remote := range repo.Remotes {
 			for _, url := range remote.URLs {
-				items = append(items, url.CompactURL())
+				items = append(items, url.CompactURL(index.tree.RemoteAliases))
 			}
 		}
 	}
-	cli.Interface.ShowResultsSorted(items)
+	index.tree.ui.ShowResultsSorted(items)
+}
+
+func commandAliases(index *Index) {
+	var items []string
+	for _, alias := range index.tree.RemoteAliases {
+		directive := "insteadOf"
+		if alias.Push {
+			directive = "pushInsteadOf"
+		}
+		item := fmt.Sprintf("%s -> %s (%s", alias.Alias, alias.Replacement, directive)
+		if alias.Origin != "" {
+			item += " in " + alias.Origin
+		}
+		items = append(items, item+")")
+	}
+	index.tree.ui.ShowResultsSorted(items)
+}
+
+func commandDoctorWithArgs(index *Index, validationErrs []error, args []string) int {
+	switch {
+	case len(args) == 0:
+		return commandDoctor(index, validationErrs, false)
+	case len(args) == 1 && args[0] == "--fix":
+		return commandDoctor(index, validationErrs, true)
+	default:
+		return index.tree.usage()
+	}
 }
 
 func commandEach(index *Index, cmdline []string) (exitCode int) {
@@ -152,8 +410,11 @@ func commandEach(index *Index, cmdline []string) (exitCode int) {
 	for _, repo := range index.Repos {
 		err := repo.Exec(cmdline...)
 		if err != nil {
-			cli.Interface.ShowError(err.Error())
+			index.tree.ui.ShowError(err.Error())
 			exitCode = 1
+			if errors.Is(err, cli.ErrInterrupted) {
+				break
+			}
 		}
 	}
 	return
@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"git.xyrillian.de/gofu/internal/cli"
+)
+
+func TestExecInterrupted(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	gopath := t.TempDir()
+	t.Setenv("GOPATH", gopath)
+	target := filepath.Join(gopath, "src/github.com/foo/new")
+
+	var ui *cli.Implementation
+	cs := &CommandSimulator{Cmd: []RecordedCommand{
+		Recorded("git config -l --includes --show-scope --show-origin")[0],
+		{
+			Cmd:         Recorded("git clone https://github.com/foo/new " + target)[0].Cmd,
+			Interrupted: true,
+			//like Ctrl-C on the terminal while the clone is running
+			SideEffect: func() {
+				err := syscall.Kill(os.Getpid(), syscall.SIGINT)
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+				deadline := time.Now().Add(5 * time.Second)
+				for !ui.Interrupted() && time.Now().Before(deadline) {
+					time.Sleep(10 * time.Millisecond)
+				}
+			},
+		},
+	}}
+	var stdout, stderr bytes.Buffer
+	ui = cli.NewImplementation(strings.NewReader(""), &stdout, &stderr, cs.Next)
+
+	exitCode := execWithUI([]string{"get", "https://github.com/foo/new"}, ui)
+	if exitCode != 130 {
+		t.Errorf("expected exit code 130, but got %d (stderr = %q)", exitCode, stderr.String())
+	}
+	if cs.idx != len(cs.Cmd) {
+		t.Errorf("expected %d commands to be executed, but got %d", len(cs.Cmd), cs.idx)
+	}
+	if stdout.String() != "" {
+		t.Errorf("expected no output, but got %q", stdout.String())
+	}
+	expectedError := "!! command \"git clone https://github.com/foo/new " + target + "\": interrupted\n"
+	if stderr.String() != expectedError {
+		t.Errorf("expected error %q, but got %q", expectedError, stderr.String())
+	}
+}
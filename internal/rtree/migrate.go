@@ -0,0 +1,161 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// IndexVersion is the version of the index format that this version of rtree
+// writes. When the format changes, increase this and add a migration to
+// indexMigrations.
+const IndexVersion = 1
+
+// indexMigrations[N] upgrades an index in version N to version N+1. Migrations
+// work on the generic JSON representation of the index, since the Index type
+// always matches the current version.
+var indexMigrations = []func(data map[string]any) error{
+	//version 0 -> 1: the "version" field was introduced, nothing else changed
+	func(data map[string]any) error { return nil },
+}
+
+// migrateIndex upgrades the given index file contents to the current
+// IndexVersion, if necessary. Returns the version that the index had before.
+func migrateIndex(data map[string]any) (originalVersion int, err error) {
+	if value, exists := data["version"]; exists {
+		number, ok := value.(float64)
+		if !ok || number != float64(int(number)) || number < 0 {
+			return 0, fmt.Errorf("invalid value for \"version\": %v", value)
+		}
+		originalVersion = int(number)
+	}
+	if originalVersion > IndexVersion {
+		return 0, fmt.Errorf("index has version %d, but this version of rtree only supports up to version %d", originalVersion, IndexVersion)
+	}
+
+	for version := originalVersion; version < IndexVersion; version++ {
+		err := indexMigrations[version](data)
+		if err != nil {
+			return 0, fmt.Errorf("cannot migrate index from version %d to %d: %w", version, version+1, err)
+		}
+		data["version"] = version + 1
+	}
+	return originalVersion, nil
+}
+
+// indexMigration describes how the index file was migrated in memory by
+// readIndexFile. The migration is persisted by the next Index.Write(), so
+// that commands which do not change the index do not write it either.
+type indexMigration struct {
+	//originalVersion is the version of the index file before the migration.
+	originalVersion int
+	//backupPath is where the original index file is kept.
+	backupPath string
+	//originalContents is written to backupPath, unless it is nil (for the
+	//legacy index file, which is just left where it is).
+	originalContents []byte
+}
+
+// readIndexFile reads the index file and returns its contents in the current
+// IndexVersion. If the index file needs to be migrated, the returned
+// indexMigration describes how to persist that migration. If there is no
+// index file yet, but an index file in the legacy YAML format, that file is
+// migrated instead.
+//
+// Returns (nil, nil, nil) if there is no index file at all.
+func (t *RTree) readIndexFile() ([]byte, *indexMigration, error) {
+	var (
+		data      map[string]any
+		migration indexMigration
+	)
+	buf, err := os.ReadFile(t.IndexPath)
+	switch {
+	case err == nil:
+		err = json.Unmarshal(buf, &data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read %s: %w", t.IndexPath, err)
+		}
+		migration.originalContents = buf
+	case os.IsNotExist(err):
+		buf, err = os.ReadFile(t.OldIndexPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil, nil
+			}
+			return nil, nil, err
+		}
+		data, err = convertLegacyIndex(buf)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read %s: %w", t.OldIndexPath, err)
+		}
+		migration.backupPath = t.OldIndexPath
+	default:
+		return nil, nil, err
+	}
+
+	migration.originalVersion, err = migrateIndex(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read %s: %w", t.IndexPath, err)
+	}
+	if migration.backupPath == "" {
+		if migration.originalVersion == IndexVersion {
+			return buf, nil, nil
+		}
+		migration.backupPath = fmt.Sprintf("%s.v%d.bak", t.IndexPath, migration.originalVersion)
+	}
+
+	buf, err = json.Marshal(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return buf, &migration, nil
+}
+
+// persistMigration is called by Index.Write() before the migrated index is
+// written. It writes the backup of the original index file, if necessary.
+func (t *RTree) persistMigration(migration indexMigration) error {
+	if t.dryRun {
+		t.ui.ShowProgress(fmt.Sprintf(
+			"would migrate %s to index format version %d (keeping the previous index at %s)",
+			t.IndexPath, IndexVersion, migration.backupPath,
+		))
+		return nil
+	}
+	if migration.originalContents == nil {
+		return nil
+	}
+	return t.fs.WriteFile(migration.backupPath, migration.originalContents, 0644)
+}
+
+// convertLegacyIndex converts the contents of the legacy index.yaml into the
+// JSON structure of index version 0.
+func convertLegacyIndex(buf []byte) (map[string]any, error) {
+	var legacy struct {
+		Repos []struct {
+			Path    string `yaml:"path"`
+			Remotes []struct {
+				Name string `yaml:"name"`
+				URL  string `yaml:"url"`
+			} `yaml:"remotes"`
+		} `yaml:"repos"`
+	}
+	err := yaml.Unmarshal(buf, &legacy)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make([]any, len(legacy.Repos))
+	for idx, repo := range legacy.Repos {
+		remotes := make(map[string]any, len(repo.Remotes))
+		for _, remote := range repo.Remotes {
+			remotes[remote.Name] = map[string]any{"urls": []any{remote.URL}}
+		}
+		repos[idx] = map[string]any{"path": repo.Path, "remotes": remotes}
+	}
+	return map[string]any{"repos": repos}, nil
+}
@@ -0,0 +1,158 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git.xyrillian.de/gofu/internal/cli"
+)
+
+func TestIndexMigrationsAreComplete(t *testing.T) {
+	if len(indexMigrations) != IndexVersion {
+		t.Errorf("expected %d index migrations, but got %d", IndexVersion, len(indexMigrations))
+	}
+}
+
+// writeTestIndexFile puts an index file where Test.Run() will look for it.
+func writeTestIndexFile(t *testing.T, contents string) string {
+	path := filepath.Join(indexTmpDir, t.Name()+".json")
+	err := os.MkdirAll(indexTmpDir, 0755)
+	if err == nil {
+		err = os.WriteFile(path, []byte(contents), 0644)
+	}
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return path
+}
+
+// execReadOnly runs a command like Test.Run() does, but without checking the
+// index file afterwards, since the command is expected to leave it alone.
+func execReadOnly(t *testing.T, args ...string) (stdout, stderr string) {
+	var outBuf, errBuf bytes.Buffer
+	cs := &CommandSimulator{}
+	tree, err := newWithUI(testTreeOptions(t), cli.NewImplementation(strings.NewReader(""), &outBuf, &errBuf, cs.Next))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	exitCode := tree.exec(args)
+	if exitCode != 0 {
+		t.Errorf("expected %q to succeed, but got exit code %d (stderr = %q)", args, exitCode, errBuf.String())
+	}
+	return outBuf.String(), errBuf.String()
+}
+
+// getAnotherRepo is a Test that writes the index, and thus persists a pending
+// migration.
+func getAnotherRepo(t *testing.T, expectedError string, repos ...*Repo) Test {
+	target := filepath.Join(testRootPath, "github.com/another/repo")
+	newRepo := &Repo{
+		CheckoutPath: "github.com/another/repo",
+		Remotes:      map[string]Remote{"origin": {URLs: []RemoteURL{"https://github.com/another/repo"}}},
+	}
+	return Test{
+		Args:         []string{"get", "gh:another/repo"},
+		ExpectOutput: target + "\n",
+		ExpectError:  expectedError,
+		ExpectExecution: Recorded(
+			"git clone https://github.com/another/repo "+target,
+			"@"+target+" git rev-list --max-parents=0 --all",
+		),
+		ExpectIndex: &Index{Repos: append([]*Repo{newRepo}, repos...)},
+	}
+}
+
+func expectFileContents(t *testing.T, path, expected string) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(buf) != expected {
+		t.Errorf("expected %s to contain %q, but got %q", path, expected, string(buf))
+	}
+}
+
+func TestMigrateUnversionedIndex(t *testing.T) {
+	original := `{"repos":[{"path":"github.com/git/git","remotes":{"origin":{"urls":["https://github.com/git/git"]}}}]}`
+	indexPath := writeTestIndexFile(t, original)
+	target := filepath.Join(testRootPath, "github.com/another/repo")
+
+	//commands that do not change the index only migrate it in memory
+	stdout, stderr := execReadOnly(t, "repos")
+	if stdout != "github.com/git/git\n" || stderr != "" {
+		t.Errorf("unexpected output from `rtree repos`: stdout = %q, stderr = %q", stdout, stderr)
+	}
+	_, stderr = execReadOnly(t, "--dry-run", "get", "gh:another/repo")
+	expectedError := ">> would run `git clone https://github.com/another/repo " + target + "`\n" +
+		">> would add github.com/another/repo to the index\n" +
+		">> would migrate " + indexPath + " to index format version 1 (keeping the previous index at " + indexPath + ".v0.bak)\n" +
+		">> would write " + filepath.Join(indexTmpDir, t.Name()+".history.json") + "\n"
+	if stderr != expectedError {
+		t.Errorf("expected stderr %q from dry run, but got %q", expectedError, stderr)
+	}
+	expectFileContents(t, indexPath, original)
+	_, err := os.Stat(indexPath + ".v0.bak")
+	if !os.IsNotExist(err) {
+		t.Errorf("expected no backup at %s.v0.bak yet, but got err = %v", indexPath, err)
+	}
+
+	//the migration is persisted when the index is written anyway
+	getAnotherRepo(t,
+		"!! migrated "+indexPath+" to index format version 1 (the previous index is still available at "+indexPath+".v0.bak)\n",
+		testIndexWithTwoRepos.Repos[1],
+	).Run(t)
+	expectFileContents(t, indexPath+".v0.bak", original)
+
+	//the second time, there is nothing to migrate
+	getAnotherRepo(t, "", testIndexWithTwoRepos.Repos[1]).Run(t)
+}
+
+func TestMigrateLegacyIndex(t *testing.T) {
+	testOldIndexPath = filepath.Join(t.TempDir(), "index.yaml")
+	t.Cleanup(func() { testOldIndexPath = "" })
+	err := os.WriteFile(testOldIndexPath, []byte(`repos:
+- path: github.com/foo/bar
+  remotes:
+  - name: origin
+    url: https://github.com/foo/bar
+- path: github.com/git/git
+  remotes:
+  - name: origin
+    url: https://github.com/git/git
+`), 0644)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	indexPath := filepath.Join(indexTmpDir, t.Name()+".json")
+
+	stdout, stderr := execReadOnly(t, "repos")
+	if stdout != "github.com/foo/bar\ngithub.com/git/git\n" || stderr != "" {
+		t.Errorf("unexpected output from `rtree repos`: stdout = %q, stderr = %q", stdout, stderr)
+	}
+	_, err = os.Stat(indexPath)
+	if !os.IsNotExist(err) {
+		t.Errorf("expected no index at %s yet, but got err = %v", indexPath, err)
+	}
+
+	getAnotherRepo(t,
+		"!! migrated "+indexPath+" to index format version 1 (the previous index is still available at "+testOldIndexPath+")\n",
+		testIndexWithTwoRepos.Repos...,
+	).Run(t)
+}
+
+func TestRejectNewerIndex(t *testing.T) {
+	indexPath := writeTestIndexFile(t, "{\n  \"version\": 9999,\n  \"repos\": []\n}")
+
+	Test{
+		Args:          []string{"repos"},
+		ExpectFailure: true,
+		ExpectError:   "!! read " + indexPath + ": index has version 9999, but this version of rtree only supports up to version 1\n",
+		ExpectIndex:   &Index{Version: 9999, Repos: []*Repo{}},
+	}.Run(t)
+}
@@ -0,0 +1,85 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// NewRepoOptions contains the options for `rtree new`.
+type NewRepoOptions struct {
+	//If true, the repo is also created on the forge that hosts the remote URL
+	//(see `forges` in the config).
+	CreateOnForge bool
+	//If true, the repo on the forge is private. Only used with CreateOnForge.
+	Private bool
+}
+
+// CreateRepo starts a new repo: It initializes an empty repo at the checkout
+// path for the given remote URL, with that URL as its "origin" remote, and
+// adds it to the index. Unlike FindRepo(), this does not try to clone
+// anything, since the remote is not expected to exist yet (but it can be
+// created through the forge API).
+func (i *Index) CreateRepo(ctx context.Context, rawRemoteURL string, opts NewRepoOptions) (*Repo, error) {
+	repo, newRepo, err := i.lookupRepo(rawRemoteURL)
+	if err != nil {
+		return nil, err
+	}
+	if repo != nil {
+		return nil, fmt.Errorf("%s is already in the index at %s", rawRemoteURL, repo.AbsolutePath())
+	}
+
+	if opts.CreateOnForge {
+		err := i.tree.createOnForge(ctx, newRepo.Remotes["origin"].URLs[0], opts.Private)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = newRepo.Init()
+	if err != nil {
+		return nil, err
+	}
+	i.Repos = append(i.Repos, &newRepo)
+	newRepo.runHooksAfterCheckout(ctx)
+	return &newRepo, i.Write()
+}
+
+// createOnForge creates the repo for the given remote URL through the
+// forge API that is configured for its host.
+func (t *RTree) createOnForge(ctx context.Context, remoteURL RemoteURL, private bool) error {
+	host, repoPath, err := splitHostAndPath(remoteURL.CanonicalURL())
+	if err != nil {
+		return err
+	}
+	client, err := t.Config.forgeClient(host)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		return fmt.Errorf("no forge API configured for %s (see `forges` in %s)", host, t.ConfigPath)
+	}
+
+	fullName := strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	_, err = client.CreateRepo(ctx, fullName, private)
+	if err != nil {
+		return fmt.Errorf("cannot create %s on %s: %w", fullName, host, err)
+	}
+	t.ui.ShowProgress(fmt.Sprintf("created %s on %s", fullName, host))
+	return nil
+}
+
+func commandNew(index *Index, url string, opts NewRepoOptions) error {
+	//make sure that stdout is not used for anything but the result
+	index.tree.ui.StdoutProtected = true
+
+	repo, err := index.CreateRepo(context.Background(), url, opts)
+	if err != nil {
+		return err
+	}
+	index.tree.ui.ShowResult(repo.AbsolutePath())
+	return nil
+}
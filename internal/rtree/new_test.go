@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+var testNewRepo = &Repo{
+	CheckoutPath: "github.com/me/new",
+	Remotes: map[string]Remote{
+		"origin": {URLs: []RemoteURL{"https://github.com/me/new"}},
+	},
+}
+
+func TestNewRepo(t *testing.T) {
+	target := filepath.Join(testRootPath, "github.com/me/new")
+	Test{
+		Args:         []string{"new", "gh:me/new"},
+		Index:        testIndexWithTwoRepos,
+		ExpectOutput: target + "\n",
+		ExpectExecution: Recorded(
+			"git init "+target,
+			"@"+target+" git remote add origin https://github.com/me/new",
+		),
+		ExpectIndex: &Index{Repos: []*Repo{
+			testIndexWithTwoRepos.Repos[0],
+			testIndexWithTwoRepos.Repos[1],
+			testNewRepo,
+		}},
+	}.Run(t)
+}
+
+func TestNewRepoAlreadyInIndex(t *testing.T) {
+	Test{
+		Args:          []string{"new", "gh:git/git"},
+		Index:         testIndexWithTwoRepos,
+		ExpectFailure: true,
+		ExpectError:   "!! gh:git/git is already in the index at " + filepath.Join(testRootPath, "github.com/git/git") + "\n",
+	}.Run(t)
+}
+
+func TestNewRepoOnForge(t *testing.T) {
+	withForge(t, "github.com", "github", map[string]string{
+		"/user":       `{"login":"me"}`,
+		"/user/repos": `{"full_name":"me/new"}`,
+	})
+
+	target := filepath.Join(testRootPath, "github.com/me/new")
+	Test{
+		Args:         []string{"new", "--forge", "--private", "gh:me/new"},
+		Index:        testIndexWithTwoRepos,
+		ExpectOutput: target + "\n",
+		ExpectError:  ">> created me/new on github.com\n",
+		ExpectExecution: Recorded(
+			"git init "+target,
+			"@"+target+" git remote add origin https://github.com/me/new",
+		),
+		ExpectIndex: &Index{Repos: []*Repo{
+			testIndexWithTwoRepos.Repos[0],
+			testIndexWithTwoRepos.Repos[1],
+			testNewRepo,
+		}},
+	}.Run(t)
+}
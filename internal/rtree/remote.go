@@ -8,14 +8,16 @@ import (
 	"net/url"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 )
 
 // RemoteURL is the URL of a remote of a Git repository.
 type RemoteURL string
 
-// ParseRemoteURL parses the given remote URL by substituting aliases defined in
-// the system-wide and user-global Git config. For example, with
+// ParseRemoteURL parses the given remote URL by substituting the given aliases
+// (usually those defined in the system-wide and user-global Git config, see
+// RTree.RemoteAliases). For example, with
 //
 //	$ cat /etc/gitconfig
 //	[url "git://github.com/"]
@@ -23,9 +25,18 @@ type RemoteURL string
 //
 // and the input "gh:foo/bar", the result has a canonical URL of
 // "git://github.com/foo/bar".
-func ParseRemoteURL(input string) RemoteURL {
+func ParseRemoteURL(input string, aliases []*RemoteAlias) RemoteURL {
+	return RemoteURL(expandAlias(input, false, aliases))
+}
+
+// expandAlias substitutes the longest matching alias of the given kind
+// (either "insteadOf" or "pushInsteadOf") in the input.
+func expandAlias(input string, push bool, aliases []*RemoteAlias) string {
 	var best *RemoteAlias
-	for _, current := range RemoteAliases {
+	for _, current := range aliases {
+		if current.Push != push {
+			continue
+		}
 		if strings.HasPrefix(input, current.Alias) {
 			if best == nil || len(best.Alias) < len(current.Alias) {
 				best = current
@@ -33,9 +44,28 @@ func ParseRemoteURL(input string) RemoteURL {
 		}
 	}
 	if best == nil {
-		return RemoteURL(input)
+		return input
 	}
-	return RemoteURL(best.Replacement + strings.TrimPrefix(input, best.Alias))
+	return best.Replacement + strings.TrimPrefix(input, best.Alias)
+}
+
+// contractAlias is the reverse of expandAlias.
+func contractAlias(input string, push bool, aliases []*RemoteAlias) string {
+	var best *RemoteAlias
+	for _, current := range aliases {
+		if current.Push != push {
+			continue
+		}
+		if strings.HasPrefix(input, current.Replacement) {
+			if best == nil || len(best.Replacement) < len(current.Replacement) {
+				best = current
+			}
+		}
+	}
+	if best == nil {
+		return input
+	}
+	return best.Alias + strings.TrimPrefix(input, best.Replacement)
 }
 
 // CanonicalURL returns the URL where the remote will be fetched from.
@@ -43,23 +73,64 @@ func (u RemoteURL) CanonicalURL() string {
 	return string(u)
 }
 
+// PushURL returns the URL where the remote will be pushed to, which differs
+// from CanonicalURL() if a "url.<base>.pushInsteadOf" directive applies.
+func (u RemoteURL) PushURL(aliases []*RemoteAlias) string {
+	return expandAlias(string(u), true, aliases)
+}
+
 // CompactURL returns the most compact representation of this remote URL,
-// obtained by substituting the longest matching alias defined in the
-// system-wide or user-global Git config. This function is mostly the reverse of
-// ParseRemoteURL().
-func (u RemoteURL) CompactURL() string {
-	var best *RemoteAlias
-	for _, current := range RemoteAliases {
-		if strings.HasPrefix(string(u), current.Replacement) {
-			if best == nil || len(best.Replacement) < len(current.Replacement) {
-				best = current
-			}
+// obtained by substituting the longest matching alias from the given list.
+// This function is mostly the reverse of ParseRemoteURL().
+//
+// If this URL looks like the result of a "url.<base>.pushInsteadOf" rewrite,
+// that rewrite is reversed first, since the Git config declares both forms
+// to refer to the same remote. For example, with
+//
+//	[url "git@github.com:"]
+//	pushInsteadOf = https://github.com/
+//	[url "https://github.com/"]
+//	insteadOf = gh:
+//
+// both "git@github.com:foo/bar" and "https://github.com/foo/bar" compact into
+// "gh:foo/bar". The result is therefore only suitable for display; use
+// ConfigURL() for commands that write the URL into a repo's configuration.
+func (u RemoteURL) CompactURL(aliases []*RemoteAlias) string {
+	return contractAlias(contractAlias(string(u), true, aliases), false, aliases)
+}
+
+// ConfigURL returns the form of this remote URL that is written into the
+// configuration of a repo. This is like CompactURL(), but "pushInsteadOf"
+// rewrites are not reversed, since that could change where the remote is
+// fetched from (in the example above, from "git@github.com:foo/bar" to
+// "https://github.com/foo/bar").
+func (u RemoteURL) ConfigURL(aliases []*RemoteAlias) string {
+	return contractAlias(string(u), false, aliases)
+}
+
+// MatchesURL returns whether both URLs refer to the same remote, either
+// directly or after applying "url.<base>.pushInsteadOf" rewrites. URLs for
+// the same host and path are considered equivalent regardless of how they
+// reach that host, so e.g. "https://github.com/foo/bar" matches
+// "git@github.com:foo/bar.git".
+func (u RemoteURL) MatchesURL(other RemoteURL, aliases []*RemoteAlias) bool {
+	otherKeys := other.equivalenceKeys(aliases)
+	for _, key := range u.equivalenceKeys(aliases) {
+		if slices.Contains(otherKeys, key) {
+			return true
 		}
 	}
-	if best == nil {
-		return string(u)
+	return false
+}
+
+// equivalenceKeys returns the keys that MatchesURL() compares: Two URLs match
+// if they have at least one key in common.
+func (u RemoteURL) equivalenceKeys(aliases []*RemoteAlias) []string {
+	keys := []string{equivalenceKey(u.CanonicalURL())}
+	if pushKey := equivalenceKey(u.PushURL(aliases)); pushKey != keys[0] {
+		keys = append(keys, pushKey)
 	}
-	return best.Alias + strings.TrimPrefix(string(u), best.Replacement)
+	return keys
 }
 
 // This regex recognizes the scp-like syntax for git remotes
@@ -67,38 +138,66 @@ func (u RemoteURL) CompactURL() string {
 // section of man:git-clone(1).
 var scpSyntaxRx = regexp.MustCompile(`^(?:[^/@:]+@)?([^/:]+\.[^/:]+):(.+)$`)
 
+// Like scpSyntaxRx, but also for hostnames without dots (e.g.
+// "myserver:repos/foo"). This is only tried for URLs without a scheme, since
+// it would match "https://..." as well.
+var scpSyntaxDotlessRx = regexp.MustCompile(`^(?:[^/@:]+@)?([^/:]+):(.+)$`)
+
 // CheckoutPath derives the checkout path for a remote URL.
 //
 //	RemoteURL("https://example.org/foo/bar") -> "example.org/foo/bar"
 //	RemoteURL("git@example.org:foo/bar.git") -> "example.org/foo/bar"
 func (u RemoteURL) CheckoutPath() (string, error) {
-	stripped := strings.TrimSuffix(u.CanonicalURL(), ".git")
+	host, path, err := splitHostAndPath(u.CanonicalURL())
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(host, path), nil
+}
+
+// splitHostAndPath extracts the hostname and the path to the repo from a
+// remote URL, discarding everything else (scheme, user, port, ".git" suffix,
+// trailing slashes).
+func splitHostAndPath(input string) (host, path string, err error) {
+	stripped := strings.TrimSuffix(strings.TrimRight(input, "/"), ".git")
 
 	match := scpSyntaxRx.FindStringSubmatch(stripped)
+	if match == nil && !strings.Contains(stripped, "://") {
+		match = scpSyntaxDotlessRx.FindStringSubmatch(stripped)
+	}
 	if match != nil {
 		//match[1] is the hostname, match[2] is the path to the repo
-		return filepath.Join(match[1], match[2]), nil
+		return match[1], match[2], nil
 	}
 
 	parsed, err := url.Parse(stripped)
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+	if parsed.Opaque != "" {
+		//e.g. "mailto:foo" (the scp-like forms were handled above); there is no
+		//host to speak of
+		return "", parsed.Opaque, nil
 	}
-	return filepath.Join(parsed.Hostname(), parsed.Path), nil
+	return parsed.Hostname(), parsed.Path, nil
+}
+
+// equivalenceKey returns a string that is identical for all URLs that differ
+// only in scheme, user, port, ".git" suffix, trailing slashes or the case of
+// the hostname. Unparseable URLs, and URLs without a host or path (e.g. local
+// paths), are only equivalent to themselves.
+func equivalenceKey(input string) string {
+	host, path, err := splitHostAndPath(input)
+	if err != nil || host == "" || strings.Trim(path, "/") == "" {
+		return input
+	}
+	return strings.ToLower(host) + "/" + strings.Trim(path, "/")
 }
 
 // MarshalJSON implements the json.Marshaler interface.
 func (u RemoteURL) MarshalJSON() ([]byte, error) {
-	//store URLs in the index in the canonical format
+	//store URLs in the index in the canonical format (when reading the index,
+	//URLs are parsed again by readIndex, since aliases might have been used
+	//when editing the index file manually)
 	return json.Marshal(u.CanonicalURL())
 }
-
-// UnmarshalJSON implements the json.Unmarshaler interface.
-func (u *RemoteURL) UnmarshalJSON(buf []byte) error {
-	var s string
-	err := json.Unmarshal(buf, &s)
-	if err == nil {
-		*u = ParseRemoteURL(s)
-	}
-	return err
-}
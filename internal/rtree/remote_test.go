@@ -3,7 +3,10 @@
 
 package rtree
 
-import "testing"
+import (
+	"slices"
+	"testing"
+)
 
 var remoteAliasesForExpansionTest = []*RemoteAlias{
 	{Alias: "gh:f:", Replacement: "https://github.com/foo/"},
@@ -23,9 +26,8 @@ var testExpansions = map[string]RemoteURL{
 }
 
 func TestParseRemoteURL(t *testing.T) {
-	RemoteAliases = remoteAliasesForExpansionTest
 	for input, expected := range testExpansions {
-		actual := ParseRemoteURL(input)
+		actual := ParseRemoteURL(input, remoteAliasesForExpansionTest)
 		if actual != expected {
 			t.Errorf("expected %q to expand into %q, but got %q", input, expected, actual)
 		}
@@ -43,11 +45,155 @@ var testContractions = map[RemoteURL]string{
 }
 
 func TestCompactRemoteURL(t *testing.T) {
-	RemoteAliases = remoteAliasesForExpansionTest
 	for input, expected := range testContractions {
-		actual := input.CompactURL()
+		actual := input.CompactURL(remoteAliasesForExpansionTest)
 		if actual != expected {
 			t.Errorf("expected %q to contract into %q, but got %q", input, expected, actual)
 		}
 	}
 }
+
+func TestParseRemoteAliases(t *testing.T) {
+	out := "system\tfile:/etc/gitconfig\turl.https://github.com/.insteadof=gh:\n" +
+		"global\tfile:/home/user/.gitconfig\tuser.name=Jane Doe\n" +
+		"global\tfile:/home/user/.config/git/work.inc\turl.git@git.example.com:.insteadof=work:\n" +
+		"global\tfile:/home/user/.gitconfig\turl.git@github.com:.pushinsteadof=https://github.com/\n" +
+		"local\tfile:.git/config\turl.https://example.org/.insteadof=ex:\n"
+	expected := []RemoteAlias{
+		{Alias: "gh:", Replacement: "https://github.com/", Origin: "file:/etc/gitconfig"},
+		{Alias: "work:", Replacement: "git@git.example.com:", Origin: "file:/home/user/.config/git/work.inc"},
+		{Alias: "https://github.com/", Replacement: "git@github.com:", Push: true, Origin: "file:/home/user/.gitconfig"},
+	}
+
+	actual := parseRemoteAliases(out)
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %d aliases, but got %d", len(expected), len(actual))
+	}
+	for idx, alias := range actual {
+		if *alias != expected[idx] {
+			t.Errorf("expected alias %#v, but got %#v", expected[idx], *alias)
+		}
+	}
+}
+
+var remoteAliasesForPushTest = []*RemoteAlias{
+	{Alias: "gh:", Replacement: "https://github.com/"},
+	{Alias: "https://github.com/", Replacement: "git@github.com:", Push: true},
+}
+
+func TestPushInsteadOf(t *testing.T) {
+	aliases := remoteAliasesForPushTest
+
+	//pushInsteadOf does not apply when parsing
+	if actual := ParseRemoteURL("https://github.com/foo/bar", aliases); actual != "https://github.com/foo/bar" {
+		t.Errorf("expected ParseRemoteURL to ignore pushInsteadOf, but got %q", actual)
+	}
+
+	url := ParseRemoteURL("gh:foo/bar", aliases)
+	if actual := url.PushURL(aliases); actual != "git@github.com:foo/bar" {
+		t.Errorf("expected push URL %q, but got %q", "git@github.com:foo/bar", actual)
+	}
+	for _, input := range []RemoteURL{"https://github.com/foo/bar", "git@github.com:foo/bar"} {
+		if actual := input.CompactURL(aliases); actual != "gh:foo/bar" {
+			t.Errorf("expected %q to contract into %q, but got %q", input, "gh:foo/bar", actual)
+		}
+		if !url.MatchesURL(input, aliases) || !input.MatchesURL(url, aliases) {
+			t.Errorf("expected %q to match %q", input, url)
+		}
+	}
+	//only insteadOf is reversed for URLs that go into a repo's configuration,
+	//to keep fetching over the same transport
+	for input, expected := range map[RemoteURL]string{
+		"https://github.com/foo/bar": "gh:foo/bar",
+		"git@github.com:foo/bar":     "git@github.com:foo/bar",
+	} {
+		if actual := input.ConfigURL(aliases); actual != expected {
+			t.Errorf("expected %q to appear as %q in the repo configuration, but got %q", input, expected, actual)
+		}
+	}
+	if url.MatchesURL("git@github.com:foo/qux", aliases) {
+		t.Errorf("expected %q to not match %q", "git@github.com:foo/qux", url)
+	}
+}
+
+func TestAliasesCommand(t *testing.T) {
+	defer func(saved []*RemoteAlias) { testAliases = saved }(testAliases)
+	testAliases = []*RemoteAlias{
+		{Alias: "gh:", Replacement: "https://github.com/", Origin: "file:/etc/gitconfig"},
+		{Alias: "https://github.com/", Replacement: "git@github.com:", Push: true},
+	}
+
+	Test{
+		Args:  []string{"aliases"},
+		Index: testIndexWithTwoRepos,
+		ExpectOutput: "gh: -> https://github.com/ (insteadOf in file:/etc/gitconfig)\n" +
+			"https://github.com/ -> git@github.com: (pushInsteadOf)\n",
+	}.Run(t)
+}
+
+func TestURLEquivalence(t *testing.T) {
+	testCases := []struct {
+		Left, Right RemoteURL
+		Equivalent  bool
+	}{
+		{"https://github.com/foo/bar", "git@github.com:foo/bar.git", true},
+		{"https://github.com/foo/bar", "ssh://git@github.com/foo/bar", true},
+		{"https://github.com/foo/bar/", "git://GitHub.com/foo/bar.git", true},
+		{"https://user@github.com:443/foo/bar", "https://github.com/foo/bar", true},
+		{"https://github.com/foo/bar", "https://github.com/foo/baz", false},
+		{"https://github.com/foo/bar", "https://gitlab.com/foo/bar", false},
+		{"https://github.com/foo/bar", "https://github.com/Foo/bar", false}, //only the hostname is case-insensitive
+		//scp-like syntax with hostnames without dots
+		{"myserver:repos/foo", "ssh://git@myserver/repos/foo.git", true},
+		{"myserver:repos/foo", "otherserver:repos/bar", false},
+		{"localhost:x", "localhost:y", false},
+		{"git@myserver:foo", "git@myserver:bar", false},
+		//URLs without host are only equivalent to themselves
+		{"file:///srv/git/foo", "file:///srv/git/foo", true},
+		{"file:///srv/git/foo", "file:///srv/git/bar", false},
+		{"/srv/git/foo", "file:///srv/git/bar", false},
+	}
+	for _, tc := range testCases {
+		if actual := tc.Left.MatchesURL(tc.Right, testAliases); actual != tc.Equivalent {
+			t.Errorf("expected MatchesURL(%q, %q) = %t, but got %t", tc.Left, tc.Right, tc.Equivalent, actual)
+		}
+	}
+}
+
+func TestSameOriginPairs(t *testing.T) {
+	tree := &RTree{RemoteAliases: []*RemoteAlias{
+		{Alias: "https://mirror.example.org/", Replacement: "git@example.org:", Push: true},
+	}}
+	repo := func(checkoutPath string, url RemoteURL) *Repo {
+		r := &Repo{CheckoutPath: checkoutPath, tree: tree}
+		if url != "" {
+			r.Remotes = map[string]Remote{"origin": {URLs: []RemoteURL{url}}}
+		}
+		return r
+	}
+	index := Index{tree: tree, Repos: []*Repo{
+		repo("a/bar", "https://github.com/foo/bar"),
+		repo("b/bar", "git@github.com:foo/bar.git"),
+		repo("mirror/qux", "https://mirror.example.org/qux"), //only the push URL matches the next one
+		repo("main/qux", "git@example.org:qux.git"),
+		repo("a/bar", "ssh://git@github.com/foo/bar"), //duplicate entry, not a separate checkout
+		repo("c/baz", "https://github.com/foo/baz"),
+		repo("no/origin", ""),
+	}}
+
+	var expected [][2]*Repo
+	for idx, repo := range index.Repos {
+		for _, other := range index.Repos[idx+1:] {
+			if repo.CheckoutPath != other.CheckoutPath && repo.HasSameOriginAs(*other) {
+				expected = append(expected, [2]*Repo{repo, other})
+			}
+		}
+	}
+	if len(expected) != 3 {
+		t.Fatalf("expected the test setup to have 3 pairs, but got %d", len(expected))
+	}
+	actual := index.sameOriginPairs()
+	if !slices.Equal(actual, expected) {
+		t.Errorf("expected pairs %v, but got %v", expected, actual)
+	}
+}
@@ -4,11 +4,15 @@
 package rtree
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
-	"strings"
+	"runtime"
+	"slices"
+	"sync"
 
 	"git.xyrillian.de/gofu/internal/cli"
 )
@@ -21,6 +25,18 @@ type Repo struct {
 	//remote URLs (as they appear in the .git/config of the repo, i.e. possibly
 	//abbreviated).
 	Remotes map[string]Remote `json:"remotes"`
+	//RootCommits caches the commits without parents in this repo, for
+	//detecting forks by shared history.
+	RootCommits []string `json:"root_commits,omitempty"`
+	//VCS is empty for Git repos.
+	VCS VCS `json:"vcs,omitempty"`
+	//ImportedFrom is the absolute path where `rtree import` found this repo.
+	//The compatibility symlink that is left there is outside the RootPath, so
+	//this is how `rtree doctor` finds it.
+	ImportedFrom string `json:"imported_from,omitempty"`
+
+	//tree is the RTree that this repo belongs to.
+	tree *RTree
 }
 
 // Remote describes a remote that is configured in a Repo.
@@ -30,7 +46,7 @@ type Remote struct {
 
 // AbsolutePath returns the absolute CheckoutPath of this repo.
 func (r Repo) AbsolutePath() string {
-	return filepath.Join(RootPath, r.CheckoutPath)
+	return filepath.Join(r.tree.RootPath, r.CheckoutPath)
 }
 
 // GitDirPath returns the path of the .git directory of this repo.
@@ -38,62 +54,89 @@ func (r Repo) GitDirPath() string {
 	return filepath.Join(r.AbsolutePath(), ".git")
 }
 
+// MetadataDirPath returns the path of the directory that the VCS of this repo
+// keeps its data in, e.g. the .git directory for Git repos.
+func (r Repo) MetadataDirPath() string {
+	return filepath.Join(r.AbsolutePath(), r.backend().MetadataDir())
+}
+
+// HasGitDir returns whether this repo can be worked on with Git commands
+// directly. This is not the case for Mercurial repos and Jujutsu repos that
+// are not colocated.
+func (r Repo) HasGitDir() bool {
+	return r.VCS == VCSGit || r.VCS == VCSJujutsuColocated
+}
+
 // CompactURLs returns all URLs for this remote in their compact form.
-func (r Remote) CompactURLs() []string {
+func (r Remote) CompactURLs(aliases []*RemoteAlias) []string {
 	result := make([]string, len(r.URLs))
 	for idx, url := range r.URLs {
-		result[idx] = url.CompactURL()
+		result[idx] = url.CompactURL(aliases)
 	}
 	return result
 }
 
+// HasSameOriginAs returns whether both repos have an "origin" remote with
+// equivalent URLs, i.e. whether they are checkouts of the same repository.
+func (r Repo) HasSameOriginAs(other Repo) bool {
+	origin, ok := r.Remotes["origin"]
+	if !ok || len(origin.URLs) == 0 {
+		return false
+	}
+	otherOrigin, ok := other.Remotes["origin"]
+	if !ok || len(otherOrigin.URLs) == 0 {
+		return false
+	}
+	return origin.URLs[0].MatchesURL(otherOrigin.URLs[0], r.tree.RemoteAliases)
+}
+
 // NewRepoFromAbsolutePath initializes a Repo instance by scanning the existing
 // checkout at the given path.
-func NewRepoFromAbsolutePath(path string, normalizeRemoteURLs bool) (repo Repo, err error) {
-	repo.CheckoutPath, err = filepath.Rel(RootPath, path)
+func (t *RTree) NewRepoFromAbsolutePath(path string, normalizeRemoteURLs bool) (repo Repo, err error) {
+	repo.tree = t
+	repo.CheckoutPath, err = filepath.Rel(t.RootPath, path)
 	if err != nil {
 		return
 	}
+	repo.VCS, _ = detectVCS(path)
+	repo.Remotes, err = repo.readRemotes(normalizeRemoteURLs)
+	return
+}
 
-	//list remotes
-	out, err := cli.Interface.CaptureStdout(cli.Command{
-		Program: []string{"git", "config", "-l"},
-		WorkDir: path,
-	})
+// readRemotes lists the remotes that are configured in the checkout.
+func (r Repo) readRemotes(normalizeRemoteURLs bool) (map[string]Remote, error) {
+	pairs, err := r.backend().ReadRemotes(r.AbsolutePath())
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	repo.Remotes = make(map[string]Remote)
-	for line := range strings.SplitSeq(out, "\n") {
-		match := remoteConfigRx.FindStringSubmatch(line)
-		if match == nil {
-			continue
-		}
+	remotes := make(map[string]Remote)
+	for _, pair := range pairs {
 		var (
-			name = match[1]
+			name = pair[0]
 			url  RemoteURL
 		)
 		if normalizeRemoteURLs {
-			url = ParseRemoteURL(match[2])
+			url = ParseRemoteURL(pair[1], r.tree.RemoteAliases)
 		} else {
-			url = RemoteURL(match[2]) // straight cast without processing
+			url = RemoteURL(pair[1]) // straight cast without processing
 		}
-		if remote, ok := repo.Remotes[name]; ok {
+		if remote, ok := remotes[name]; ok {
 			remote.URLs = append(remote.URLs, url)
-			repo.Remotes[name] = remote
+			remotes[name] = remote
 		} else {
-			repo.Remotes[name] = Remote{URLs: []RemoteURL{url}}
+			remotes[name] = Remote{URLs: []RemoteURL{url}}
 		}
 	}
-	return
+	return remotes, nil
 }
 
 // NewRepoFromRemoteURL initializes a Repo instance for checking out a remote
 // for the first time. The checkout does not happen until Checkout() is called.
-func NewRepoFromRemoteURL(remoteURL RemoteURL) (Repo, error) {
+func (t *RTree) NewRepoFromRemoteURL(remoteURL RemoteURL) (Repo, error) {
 	checkoutPath, err := remoteURL.CheckoutPath()
 	return Repo{
+		tree:         t,
 		CheckoutPath: checkoutPath,
 		Remotes: map[string]Remote{
 			"origin": {
@@ -107,37 +150,58 @@ var remoteConfigRx = regexp.MustCompile(`remote\.([^=]+)\.url=(.+)`)
 
 // ForeachPhysicalRepo walks over the repository tree, executing the action
 // function once for every repo encountered (but *not* for repos contained
-// within other repos, e.g. submodules).
-func ForeachPhysicalRepo(action func(repo Repo) error) error {
-	return filepath.Walk(RootPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		//look for repos, i.e. directories containing a .git directory
-		if !info.IsDir() {
-			return nil
-		}
-		_, err = os.Stat(filepath.Join(path, ".git"))
-		if err != nil {
-			return nil
-		}
-
-		//appears to be a repo
-		repo, err := NewRepoFromAbsolutePath(path, true)
+// within other repos, e.g. submodules). Directories can be excluded from the
+// search in the config (see ScanConfig).
+func (t *RTree) ForeachPhysicalRepo(action func(repo Repo) error) error {
+	repoPaths, err := t.findPhysicalRepos()
+	if err != nil {
+		return err
+	}
+	for _, path := range repoPaths {
+		repo, err := t.NewRepoFromAbsolutePath(path, true)
 		if err == nil {
 			err = action(repo)
 		}
 		if err != nil {
 			return err
 		}
-		//do not traverse further down into submodules etc.
-		return filepath.SkipDir
-	})
+	}
+	return nil
 }
 
-// Checkout creates the repo in the given path with the given remotes. The
-// working copy will only be initialized if there is an "origin" remote.
-func (r Repo) Checkout() error {
+// foreachRepoConcurrently calls the action for each repo, with as many
+// actions running at once as there are CPUs. Since the actions run
+// concurrently, they should store their results at the given index instead of
+// producing output directly.
+func foreachRepoConcurrently(repos []*Repo, action func(idx int, repo *Repo)) {
+	var (
+		wg        sync.WaitGroup
+		semaphore = make(chan struct{}, runtime.NumCPU())
+	)
+	for idx, repo := range repos {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			//after Ctrl-C, the remaining actions are skipped (their commands
+			//would fail anyway, see cli.ErrInterrupted)
+			if !repo.tree.ui.Interrupted() {
+				action(idx, repo)
+			}
+			<-semaphore
+		}()
+	}
+	wg.Wait()
+}
+
+// Checkout creates the repo in the given path with the given remotes, using
+// the repo's VCS. The working copy will only be initialized if there is an
+// "origin" remote. The caller shall run the hooks from the config afterwards,
+// once the repo has been added to the index (see runHooksAfterCheckout).
+// Commands that talk to the remotes are interrupted when the given context is
+// canceled.
+func (r Repo) Checkout(ctx context.Context) error {
+	backend := r.backend()
 	//check if we have an "origin" remote to clone from
 	var originURL RemoteURL
 	for remoteName, remote := range r.Remotes {
@@ -148,18 +212,21 @@ func (r Repo) Checkout() error {
 	}
 
 	if originURL == "" {
-		err := cli.Interface.Run(cli.Command{
-			Program: []string{"git", "init", r.AbsolutePath()},
-		})
+		err := backend.Init(r.AbsolutePath())
 		if err != nil {
 			return err
 		}
-		cli.Interface.ShowWarning(`will not checkout anything since there is no remote named "origin"`)
+		r.tree.ui.ShowWarning(`will not checkout anything since there is no remote named "origin"`)
 	} else {
-		err := cli.Interface.Run(cli.Command{
-			Program: []string{"git", "clone", originURL.CanonicalURL(), r.AbsolutePath()},
-		})
+		_, err := os.Lstat(r.AbsolutePath())
+		existedBefore := err == nil
+		err = backend.Clone(ctx, r.AbsolutePath(), originURL)
 		if err != nil {
+			//a partial clone (e.g. if the clone was interrupted and the VCS
+			//did not clean up after itself) would block the next attempt
+			if !existedBefore {
+				r.removePartialCheckout()
+			}
 			return err
 		}
 	}
@@ -168,19 +235,13 @@ func (r Repo) Checkout() error {
 	for remoteName, remote := range r.Remotes {
 		for idx, url := range remote.URLs {
 			if idx == 0 && remoteName != "origin" {
-				err := cli.Interface.Run(cli.Command{
-					Program: []string{"git", "remote", "add", remoteName, url.CanonicalURL()},
-					WorkDir: r.AbsolutePath(),
-				})
+				err := backend.AddRemote(r.AbsolutePath(), remoteName, url.CanonicalURL())
 				if err != nil {
 					return err
 				}
 				remotesAdded = true
 			} else if idx > 0 {
-				err := cli.Interface.Run(cli.Command{
-					Program: []string{"git", "remote", "set-url", "--add", remoteName, url.CanonicalURL()},
-					WorkDir: r.AbsolutePath(),
-				})
+				err := backend.AddRemoteURL(r.AbsolutePath(), remoteName, url.CanonicalURL())
 				if err != nil {
 					return err
 				}
@@ -189,20 +250,71 @@ func (r Repo) Checkout() error {
 		}
 	}
 	if remotesAdded {
-		return cli.Interface.Run(cli.Command{
-			Program: []string{"git", "remote", "update"},
-			WorkDir: r.AbsolutePath(),
-		})
+		err := backend.FetchRemotes(ctx, r.AbsolutePath(), "")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runHooksAfterCheckout runs the hooks from the config for a repo that has
+// just been checked out or initialized. At this point, the repo is already in
+// the index, so failing hooks are reported as a warning instead of failing
+// the whole operation. The user can retry them with `rtree hooks run`.
+func (r Repo) runHooksAfterCheckout(ctx context.Context) {
+	err := r.RunHooks(ctx)
+	if err != nil {
+		r.tree.ui.ShowWarning(fmt.Sprintf(
+			"hooks failed for %s: %s (retry with `rtree hooks run %s`)",
+			r.AbsolutePath(), err.Error(), r.AbsolutePath(),
+		))
 	}
+}
 
+// removePartialCheckout is used by Checkout() when the clone has failed.
+func (r Repo) removePartialCheckout() {
+	_, err := os.Lstat(r.AbsolutePath())
+	if err != nil {
+		return //nothing left behind
+	}
+	err = r.tree.fs.RemoveAll(r.AbsolutePath())
+	if err == nil {
+		r.tree.ui.ShowWarning("removed partial clone at " + r.AbsolutePath())
+	} else {
+		r.tree.ui.ShowWarning("cannot remove partial clone: " + err.Error())
+	}
+}
+
+// Init creates an empty repo in the given path with the given remotes, using
+// the repo's VCS. Unlike Checkout(), nothing is fetched from the remotes.
+// Like with Checkout(), the caller shall run the hooks afterwards.
+func (r Repo) Init() error {
+	backend := r.backend()
+	err := backend.Init(r.AbsolutePath())
+	if err != nil {
+		return err
+	}
+	for _, remoteName := range sortedRemoteNames(r.Remotes) {
+		for idx, url := range r.Remotes[remoteName].URLs {
+			if idx == 0 {
+				err = backend.AddRemote(r.AbsolutePath(), remoteName, url.CanonicalURL())
+			} else {
+				err = backend.AddRemoteURL(r.AbsolutePath(), remoteName, url.CanonicalURL())
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
 // Exec implements the meat of the `rtree exec` command. It returns
 // true iff the command exited successfully.
 func (r Repo) Exec(cmdline ...string) error {
-	cli.Interface.ShowProgress(r.AbsolutePath())
-	return cli.Interface.Run(cli.Command{
+	r.tree.ui.ShowProgress(r.AbsolutePath())
+	return r.tree.ui.Run(cli.Command{
 		Program: cmdline,
 		WorkDir: r.AbsolutePath(),
 	})
@@ -212,8 +324,8 @@ func (r Repo) Exec(cmdline ...string) error {
 // from the old to the new checkoutPath. If makeSymlink is given, a symlink will
 // be created from the old to the new location.
 func (r *Repo) Move(checkoutPath string, makeSymlink bool) error {
-	sourcePath := filepath.Join(RootPath, r.CheckoutPath)
-	targetPath := filepath.Join(RootPath, checkoutPath)
+	sourcePath := filepath.Join(r.tree.RootPath, r.CheckoutPath)
+	targetPath := filepath.Join(r.tree.RootPath, checkoutPath)
 
 	//ensure that target does not exist
 	_, err := os.Lstat(targetPath)
@@ -225,13 +337,13 @@ func (r *Repo) Move(checkoutPath string, makeSymlink bool) error {
 	}
 
 	//prepare directory to move repo into
-	err = os.MkdirAll(filepath.Dir(targetPath), 0755)
+	err = r.tree.fs.MkdirAll(filepath.Dir(targetPath), 0755)
 	if err != nil {
 		return err
 	}
 
 	//move directory
-	err = os.Rename(sourcePath, targetPath)
+	err = r.tree.fs.Rename(sourcePath, targetPath)
 	if err != nil {
 		return err
 	}
@@ -239,54 +351,63 @@ func (r *Repo) Move(checkoutPath string, makeSymlink bool) error {
 
 	//if requested, make compatibility symlink
 	if makeSymlink {
-		return os.Symlink(targetPath, sourcePath)
+		return r.tree.fs.Symlink(targetPath, sourcePath)
 	}
 	return nil
 }
 
-// ReformatRemoteURLs rewrites the remote URLs in this repo's .git/config into
-// their canonical forms.
+// ReformatRemoteURLs rewrites the remote URLs in this repo's configuration
+// into their canonical forms. Remotes that are already in canonical form are
+// left alone, so that no commands need to be run for them. If the VCS cannot
+// rewrite a remote, a warning is shown and the remote is left as it is.
 func (r Repo) ReformatRemoteURLs() error {
-	// NOTE: This is a bit convoluted because the specific case of updating URLs
-	// for a remote with multiple URLs requires multiple steps. First, we clear
-	// out all non-primary URLs, and then re-add them after updating the primary URL.
-	actualRepo, err := NewRepoFromAbsolutePath(r.AbsolutePath(), false)
+	actualRemotes, err := r.readRemotes(false)
 	if err != nil {
 		return err
 	}
 
 	for remoteName, remote := range r.Remotes {
-		actualRemote := actualRepo.Remotes[remoteName]
-
-		if len(actualRemote.URLs) > 1 {
-			for _, url := range actualRemote.URLs[1:] {
-				err := cli.Interface.Run(cli.Command{
-					Program: []string{"git", "remote", "set-url", "--delete", remoteName, url.CanonicalURL()},
-					WorkDir: r.AbsolutePath(),
-				})
-				if err != nil {
-					return err
-				}
-			}
+		actualRemote := actualRemotes[remoteName]
+		if slices.Equal(actualRemote.URLs, remote.URLs) {
+			continue
 		}
-
-		err := cli.Interface.Run(cli.Command{
-			Program: []string{"git", "remote", "set-url", remoteName, remote.URLs[0].CanonicalURL()},
-			WorkDir: r.AbsolutePath(),
-		})
-		if err != nil {
+		err := r.reformatRemoteURLs(remoteName, actualRemote, remote)
+		switch {
+		case errors.Is(err, errors.ErrUnsupported):
+			r.tree.ui.ShowWarning(err.Error())
+		case err != nil:
 			return err
 		}
+	}
+	return nil
+}
 
-		if len(remote.URLs) > 1 {
-			for _, url := range remote.URLs[1:] {
-				err := cli.Interface.Run(cli.Command{
-					Program: []string{"git", "remote", "set-url", "--add", remoteName, url.CanonicalURL()},
-					WorkDir: r.AbsolutePath(),
-				})
-				if err != nil {
-					return err
-				}
+// reformatRemoteURLs is the part of ReformatRemoteURLs() that rewrites a
+// single remote.
+func (r Repo) reformatRemoteURLs(remoteName string, actualRemote, remote Remote) error {
+	// NOTE: This is a bit convoluted because the specific case of updating URLs
+	// for a remote with multiple URLs requires multiple steps. First, we clear
+	// out all non-primary URLs, and then re-add them after updating the primary URL.
+	backend := r.backend()
+	if len(actualRemote.URLs) > 1 {
+		for _, url := range actualRemote.URLs[1:] {
+			err := backend.DeleteRemoteURL(r.AbsolutePath(), remoteName, url.CanonicalURL())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err := backend.SetRemoteURL(r.AbsolutePath(), remoteName, remote.URLs[0].CanonicalURL())
+	if err != nil {
+		return err
+	}
+
+	if len(remote.URLs) > 1 {
+		for _, url := range remote.URLs[1:] {
+			err := backend.AddRemoteURL(r.AbsolutePath(), remoteName, url.CanonicalURL())
+			if err != nil {
+				return err
 			}
 		}
 	}
@@ -0,0 +1,9 @@
+
+# auto-cd wrapper: `rtree get` changes into the repository instead of printing its path
+function rtree --wraps rtree --description 'rtree with auto-cd for `rtree get`'
+    if test (count $argv) -eq 2 -a "$argv[1]" = get
+        set -l dir (command rtree get $argv[2]); and cd $dir
+    else
+        command rtree $argv
+    end
+end
@@ -0,0 +1,10 @@
+
+# auto-cd wrapper: `rtree get` changes into the repository instead of printing its path
+rtree() {
+  if [ "$1" = get ] && [ $# -eq 2 ]; then
+    local dir
+    dir="$(command rtree get "$2")" && cd "$dir"
+  else
+    command rtree "$@"
+  fi
+}
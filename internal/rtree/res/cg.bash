@@ -0,0 +1,34 @@
+# shell integration for rtree (generated by `rtree shell-init bash`)
+
+# cg = "cd to git repository"
+cg() {
+  local dir
+  dir="$(command rtree get "$1")" && cd "$dir"
+}
+
+_rtree_complete_candidates() {
+  # complete the word under the cursor, but keep colons (as in "gh:foo/bar")
+  # inside the word regardless of $COMP_WORDBREAKS
+  local cur="${COMP_LINE:0:$COMP_POINT}"
+  cur="${cur##* }"
+  local IFS=$'\n'
+  COMPREPLY=( $(compgen -W "$(command rtree complete 2>/dev/null)" -- "$cur") )
+  local prefix="${cur%"${cur##*:}"}"
+  COMPREPLY=( "${COMPREPLY[@]#"$prefix"}" )
+}
+
+_rtree_complete_rtree() {
+  if [ "$COMP_CWORD" -eq 1 ]; then
+    COMPREPLY=( $(compgen -W "@SUBCOMMANDS@" -- "${COMP_WORDS[1]}") )
+    return
+  fi
+  case "${COMP_WORDS[1]}" in
+    get|drop)     _rtree_complete_candidates ;;
+    import|which) COMPREPLY=( $(compgen -d -- "${COMP_WORDS[COMP_CWORD]}") ) ;;
+    shell-init)
+      COMPREPLY=( $(compgen -W "bash zsh fish --auto-cd" -- "${COMP_WORDS[COMP_CWORD]}") ) ;;
+  esac
+}
+
+complete -F _rtree_complete_candidates cg
+complete -F _rtree_complete_rtree rtree
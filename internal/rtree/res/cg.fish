@@ -0,0 +1,13 @@
+# shell integration for rtree (generated by `rtree shell-init fish`)
+
+# cg = "cd to git repository"
+function cg --description 'cd to git repository'
+    set -l dir (command rtree get $argv[1]); and cd $dir
+end
+
+complete -c cg -f -a '(command rtree complete 2>/dev/null)'
+
+set -l __rtree_subcommands @SUBCOMMANDS@
+complete -c rtree -f -n "not __fish_seen_subcommand_from $__rtree_subcommands" -a "$__rtree_subcommands"
+complete -c rtree -f -n '__fish_seen_subcommand_from get drop' -a '(command rtree complete 2>/dev/null)'
+complete -c rtree -f -n '__fish_seen_subcommand_from shell-init' -a 'bash zsh fish --auto-cd'
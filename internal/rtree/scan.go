@@ -0,0 +1,171 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// treeScanner finds all repos below the RootPath. Directories are read in
+// parallel by a fixed number of workers, since most of the time is spent
+// waiting for the filesystem. Only the directory listings are used to
+// recognize repos, so no further syscalls are needed per directory.
+type treeScanner struct {
+	rootPath string
+	config   ScanConfig
+
+	mutex sync.Mutex
+	//signaled when jobs are added to the queue or when the scan is complete
+	cond *sync.Cond
+	//directories that still need to be read
+	queue []scanJob
+	//number of jobs that are in the queue or being worked on
+	pending   int
+	repoPaths []string
+	err       error
+}
+
+// scanJob is a directory that treeScanner needs to read.
+type scanJob struct {
+	dirPath string
+	depth   int
+}
+
+// findPhysicalRepos returns the absolute paths of all repos below the
+// RootPath (but *not* of repos contained within other repos, e.g.
+// submodules), in the order in which a depth-first walk would find them.
+func (t *RTree) findPhysicalRepos() ([]string, error) {
+	s := &treeScanner{
+		rootPath: t.RootPath,
+		config:   t.Config.Scan,
+		queue:    []scanJob{{t.RootPath, 0}},
+		pending:  1,
+	}
+	s.cond = sync.NewCond(&s.mutex)
+	var wg sync.WaitGroup
+	for range 4 * runtime.GOMAXPROCS(0) {
+		wg.Go(s.work)
+	}
+	wg.Wait()
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	//sort by path elements, since e.g. "foo-bar" < "foo/bar", but a walk
+	//visits "foo/bar" first
+	slices.SortFunc(s.repoPaths, func(left, right string) int {
+		return slices.Compare(strings.Split(left, "/"), strings.Split(right, "/"))
+	})
+	return s.repoPaths, nil
+}
+
+// work runs in each worker goroutine. It takes jobs from the queue until all
+// directories have been read.
+func (s *treeScanner) work() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for {
+		for len(s.queue) == 0 && s.pending > 0 {
+			s.cond.Wait()
+		}
+		if s.pending == 0 {
+			return
+		}
+		job := s.queue[len(s.queue)-1]
+		s.queue = s.queue[:len(s.queue)-1]
+
+		s.mutex.Unlock()
+		subdirJobs, repoPath, err := s.scan(job)
+		s.mutex.Lock()
+
+		switch {
+		case err != nil:
+			if s.err == nil {
+				s.err = err
+			}
+		case repoPath != "":
+			s.repoPaths = append(s.repoPaths, repoPath)
+		}
+		//after an error, the result is discarded anyway, so do not bother
+		//with the rest of the tree
+		if s.err == nil {
+			s.queue = append(s.queue, subdirJobs...)
+			s.pending += len(subdirJobs)
+		}
+		s.pending--
+		if s.pending == 0 || len(subdirJobs) > 0 {
+			s.cond.Broadcast()
+		}
+	}
+}
+
+// scan reads a single directory. If it is a repo, its path is returned.
+// Otherwise, jobs for its subdirectories are returned.
+func (s *treeScanner) scan(job scanJob) (subdirJobs []scanJob, repoPath string, err error) {
+	entries, err := os.ReadDir(job.dirPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	//look for repos, i.e. directories containing a .git, .jj or .hg directory
+	names := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		names[entry.Name()] = true
+	}
+	if _, isRepo := detectVCSBy(func(name string) bool { return names[name] }); isRepo {
+		//do not traverse further down into submodules etc.
+		return nil, job.dirPath, nil
+	}
+
+	if !s.config.descendsFrom(job.depth) {
+		return nil, "", nil
+	}
+	for _, entry := range entries {
+		//symlinks are not followed (entry.IsDir() is false for them)
+		if !entry.IsDir() {
+			continue
+		}
+		subdirPath := filepath.Join(job.dirPath, entry.Name())
+		if s.isIgnored(subdirPath) {
+			continue
+		}
+		subdirJobs = append(subdirJobs, scanJob{subdirPath, job.depth + 1})
+	}
+	return subdirJobs, "", nil
+}
+
+func (s *treeScanner) isIgnored(dirPath string) bool {
+	relPath, err := filepath.Rel(s.rootPath, dirPath)
+	if err != nil {
+		return false
+	}
+	return s.config.isIgnored(relPath)
+}
+
+// isIgnored returns whether the directory at the given path (relative to the
+// root path) matches one of the Ignore patterns.
+func (c ScanConfig) isIgnored(relPath string) bool {
+	for _, pattern := range c.Ignore {
+		subject := relPath
+		if !strings.Contains(pattern, "/") {
+			subject = path.Base(relPath)
+		}
+		if ok, _ := path.Match(pattern, subject); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// descendsFrom returns whether subdirectories of a directory at the given
+// depth (0 for the root path itself) are searched for repos.
+func (c ScanConfig) descendsFrom(depth int) bool {
+	return c.MaxDepth <= 0 || depth < c.MaxDepth
+}
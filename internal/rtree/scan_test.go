@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFindPhysicalRepos(t *testing.T) {
+	rootPath := t.TempDir()
+	for _, dirPath := range []string{
+		"github.com/foo/bar/.git",
+		"github.com/foo/bar/vendor/submodule/.git", //inside another repo
+		"github.com/foo/bar-baz/.jj",
+		"github.com/foo/bar-baz/node_modules/dep/.git", //inside another repo
+		"github.com/foo/node_modules/dep/.git",         //ignored by name
+		"example.org/build/tmp/.git",                   //ignored by path
+		"example.org/repo/.hg",
+		"example.org/a/b/too-deep/.git", //beyond MaxDepth
+	} {
+		mustMkdirAll(t, filepath.Join(rootPath, dirPath))
+	}
+	//symlinks are not followed
+	err := os.Symlink(filepath.Join(rootPath, "github.com/foo/bar"), filepath.Join(rootPath, "github.com/foo/link"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tree := &RTree{
+		RootPath: rootPath,
+		Config: &Configuration{Scan: ScanConfig{
+			Ignore:   []string{"node_modules", "example.org/build"},
+			MaxDepth: 3,
+		}},
+	}
+	actual, err := tree.findPhysicalRepos()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []string{
+		filepath.Join(rootPath, "example.org/repo"),
+		filepath.Join(rootPath, "github.com/foo/bar"),
+		filepath.Join(rootPath, "github.com/foo/bar-baz"),
+	}
+	if !slices.Equal(actual, expected) {
+		t.Errorf("expected repos %#v, but got %#v", expected, actual)
+	}
+}
+
+func TestFindPhysicalReposWithError(t *testing.T) {
+	tree := &RTree{
+		RootPath: filepath.Join(t.TempDir(), "missing"),
+		Config:   &Configuration{},
+	}
+	_, err := tree.findPhysicalRepos()
+	if !os.IsNotExist(err) {
+		t.Errorf("expected a not-exist error, but got %v", err)
+	}
+}
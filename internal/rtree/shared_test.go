@@ -5,13 +5,16 @@ package rtree
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 
@@ -21,16 +24,36 @@ import (
 // Path to a directory where tests can put their index files.
 var indexTmpDir = filepath.Join(os.TempDir(), fmt.Sprintf("rtree-test-%d", os.Getpid()))
 
+// Settings for the RTree that Test.Run() executes on. Tests may change these
+// temporarily.
+var (
+	testRootPath     = "/unittest/gopath/src"
+	testOldIndexPath string
+	testConfig       = &Configuration{}
+	testAliases      = []*RemoteAlias{
+		{Alias: "gh:", Replacement: "https://github.com/"},
+		{Alias: "my/", Replacement: "git@git.example.com:"},
+	}
+)
+
+// testTreeOptions returns the Options for the RTree that Test.Run() executes
+// on, with the index file in indexTmpDir.
+func testTreeOptions(t *testing.T) Options {
+	return Options{
+		RootPath:      testRootPath,
+		IndexPath:     filepath.Join(indexTmpDir, t.Name()+".json"),
+		OldIndexPath:  testOldIndexPath,
+		ConfigPath:    filepath.Join(indexTmpDir, "config.json"), //not read since Config is given
+		HistoryPath:   filepath.Join(indexTmpDir, t.Name()+".history.json"),
+		Config:        testConfig,
+		RemoteAliases: testAliases,
+	}
+}
+
 func TestMain(m *testing.M) {
 	//make sure that test does not accidentally access user's actual rtree or index
 	os.Setenv("HOME", "")
 	os.Setenv("GOPATH", "")
-	//setup test configuration
-	RootPath = "/unittest/gopath/src"
-	RemoteAliases = []*RemoteAlias{
-		{Alias: "gh:", Replacement: "https://github.com/"},
-		{Alias: "my/", Replacement: "git@git.example.com:"},
-	}
 
 	exitCode := m.Run()
 
@@ -54,23 +77,27 @@ type Test struct {
 }
 
 func (test Test) Run(t *testing.T) {
+	opts := testTreeOptions(t)
+
 	//write index file, if any
-	IndexPath = filepath.Join(indexTmpDir, t.Name()+".json")
 	if test.Index.Repos != nil {
-		err := test.Index.Write()
+		err := writeIndexFixture(opts.IndexPath, test.Index)
 		if err != nil {
-			t.Fatalf("%s: cannot write index to %s: %s", t.Name(), IndexPath, err.Error())
+			t.Fatalf("%s: cannot write index to %s: %s", t.Name(), opts.IndexPath, err.Error())
 		}
 	}
 
-	//setup cli.Interface for test
+	//setup RTree for test
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 	cs := CommandSimulator{Cmd: test.ExpectExecution}
-	cli.SetupInterface(bytes.NewReader([]byte(test.Input)), &stdout, &stderr, cs.Next)
+	tree, err := newWithUI(opts, cli.NewImplementation(bytes.NewReader([]byte(test.Input)), &stdout, &stderr, cs.Next))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
 
 	//check exit code
-	exitCode := Exec(test.Args)
+	exitCode := tree.exec(test.Args)
 	switch {
 	case exitCode == 0 && test.ExpectFailure:
 		t.Errorf("%s: expected failure, but returned success", t.Name())
@@ -93,17 +120,21 @@ func (test Test) Run(t *testing.T) {
 	if test.ExpectIndex != nil {
 		idx = test.ExpectIndex
 	}
-	expectedIdxStr, err := json.MarshalIndent(idx, "", "  ")
+	expectedIdx := *idx
+	if expectedIdx.Version == 0 {
+		expectedIdx.Version = IndexVersion
+	}
+	expectedIdxStr, err := json.MarshalIndent(expectedIdx, "", "  ")
 	if err != nil {
 		t.Fatal(err.Error())
 	}
-	actualIdxStr, err := os.ReadFile(IndexPath)
+	actualIdxStr, err := os.ReadFile(opts.IndexPath)
 	if err != nil {
-		t.Fatalf("%s: could not read index from %s: %s", t.Name(), IndexPath, err.Error())
+		t.Fatalf("%s: could not read index from %s: %s", t.Name(), opts.IndexPath, err.Error())
 	}
 	if string(expectedIdxStr) != string(actualIdxStr) {
 		t.Errorf("%s: index does not match expectation after test; diff follows", t.Name())
-		cmd := exec.Command("diff", "-u", "-", IndexPath)
+		cmd := exec.Command("diff", "-u", "-", opts.IndexPath)
 		cmd.Stdin = bytes.NewReader([]byte(expectedIdxStr))
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
@@ -114,16 +145,47 @@ func (test Test) Run(t *testing.T) {
 	}
 }
 
+// writeIndexFixture writes the given index in the same layout as
+// Index.Write(), but without requiring the index to belong to an RTree.
+func writeIndexFixture(path string, index Index) error {
+	if index.Version == 0 {
+		index.Version = IndexVersion
+	}
+	sort.Slice(index.Repos, func(i, j int) bool {
+		return index.Repos[i].CheckoutPath < index.Repos[j].CheckoutPath
+	})
+	buf, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf, 0644)
+}
+
 type RecordedCommand struct {
 	Cmd    cli.Command
 	Stdout string
 	Stderr string
 	Fails  bool
+	//If set, the command fails like DefaultCommandRunner does when the
+	//command exceeds its Timeout, or when we get interrupted by SIGINT.
+	TimesOut    bool
+	Interrupted bool
+	//If not nil, this is called when the command is executed, e.g. to
+	//simulate files that the command leaves behind.
+	SideEffect func()
+	//If set, the arguments and the workdir of Cmd are patterns for
+	//path.Match, e.g. for temporary directories with random names.
+	Glob bool
 }
 
 // Recorded is a shortcut function for initializing a []RecordedCommand. It
 // splits each line on whitespace to obtain the command line of that command,
-// and recognizes a leading "@/some/path" to set the workdir.
+// and recognizes a leading "@/some/path" to set the workdir, followed by
+// leading "KEY=value" arguments to set environment variables.
 //
 // This function can only be used for RecordedCommands without output that do not fail.
 func Recorded(lines ...string) (cs []RecordedCommand) {
@@ -134,6 +196,10 @@ func Recorded(lines ...string) (cs []RecordedCommand) {
 			cs[idx].Cmd.WorkDir = workDir
 			cmdline = cmdline[1:]
 		}
+		for strings.Contains(cmdline[0], "=") {
+			cs[idx].Cmd.Env = append(cs[idx].Cmd.Env, cmdline[0])
+			cmdline = cmdline[1:]
+		}
 		cs[idx].Cmd.Program = cmdline
 	}
 	return
@@ -150,6 +216,11 @@ type CommandSimulator struct {
 }
 
 func (s *CommandSimulator) Next(c cli.Command, stdin io.Reader, stdout, stderr io.Writer) error {
+	//like DefaultCommandRunner, do not start commands for a canceled context
+	if c.Context != nil && c.Context.Err() != nil {
+		return fmt.Errorf("command %#v: %w", strings.Join(c.Program, " "), context.Cause(c.Context))
+	}
+
 	//take next RecordedCommand from list
 	if s.idx >= len(s.Cmd) {
 		return errors.New("got command to execute, but recorded commands have been exhausted")
@@ -158,19 +229,40 @@ func (s *CommandSimulator) Next(c cli.Command, stdin io.Reader, stdout, stderr i
 	s.idx++
 
 	//check if the given Command matches the expectation
-	if !areStringListsEqual(sc.Cmd.Program, c.Program) {
+	matches := areStringListsEqual
+	if sc.Glob {
+		matches = areStringListsMatching
+	}
+	if !matches(sc.Cmd.Program, c.Program) {
 		return fmt.Errorf("expected command %#v, but got %#v",
 			strings.Join(sc.Cmd.Program, " "), strings.Join(c.Program, " "),
 		)
 	}
-	if sc.Cmd.WorkDir != c.WorkDir {
+	if !matches([]string{sc.Cmd.WorkDir}, []string{c.WorkDir}) {
 		return fmt.Errorf("expected command workdir %s, but got %s", sc.Cmd.WorkDir, c.WorkDir)
 	}
+	if !areStringListsEqual(sc.Cmd.Env, c.Env) {
+		return fmt.Errorf("expected command environment %#v, but got %#v", sc.Cmd.Env, c.Env)
+	}
+	if sc.Cmd.Timeout != c.Timeout {
+		return fmt.Errorf("expected command timeout %s, but got %s", sc.Cmd.Timeout, c.Timeout)
+	}
 
+	if sc.SideEffect != nil {
+		sc.SideEffect()
+	}
 	stdout.Write([]byte(sc.Stdout))
 	stderr.Write([]byte(sc.Stderr))
-	if sc.Fails {
+	switch {
+	case sc.Fails:
 		return fmt.Errorf("command %#v has failed", strings.Join(c.Program, " "))
+	case sc.TimesOut:
+		if c.Timeout == 0 {
+			return fmt.Errorf("command %#v cannot time out since it has no timeout", strings.Join(c.Program, " "))
+		}
+		return fmt.Errorf("command %#v: %w", strings.Join(c.Program, " "), cli.ErrTimeout)
+	case sc.Interrupted:
+		return fmt.Errorf("command %#v: %w", strings.Join(c.Program, " "), cli.ErrInterrupted)
 	}
 	return nil
 }
@@ -186,3 +278,15 @@ func areStringListsEqual(a []string, b []string) bool {
 	}
 	return true
 }
+
+func areStringListsMatching(patterns []string, values []string) bool {
+	if len(patterns) != len(values) {
+		return false
+	}
+	for idx, pattern := range patterns {
+		if ok, _ := path.Match(pattern, values[idx]); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"git.xyrillian.de/gofu/internal/cli"
+)
+
+var (
+	//go:embed res/cg.bash
+	shellInitBash string
+	//go:embed res/cg.zsh
+	shellInitZsh string
+	//go:embed res/cg.fish
+	shellInitFish string
+	//go:embed res/autocd.sh
+	shellInitAutoCDPosix string
+	//go:embed res/autocd.fish
+	shellInitAutoCDFish string
+)
+
+// shellCompletionSubcommands are the subcommands that the shell completions
+// offer. They are inserted into the scripts in place of @SUBCOMMANDS@.
+var shellCompletionSubcommands = []string{
+	"get", "new", "get-all", "drop", "index", "repos", "remotes", "aliases", "recent",
+	"import", "each", "grep", "log", "which", "doctor", "check-remotes", "sync-heads",
+	"hooks", "cache", "backup", "restore-backup", "shell-init",
+}
+
+// commandShellInit implements `rtree shell-init`. The output is meant to be
+// eval'd by the user's shell, e.g. `eval "$(rtree shell-init bash)"`. Unlike
+// most other commands, this does not need the index.
+func commandShellInit(ui *cli.Implementation, shell string, autoCD bool) error {
+	var script, autoCDScript string
+	switch shell {
+	case "bash":
+		script, autoCDScript = shellInitBash, shellInitAutoCDPosix
+	case "zsh":
+		script, autoCDScript = shellInitZsh, shellInitAutoCDPosix
+	case "fish":
+		script, autoCDScript = shellInitFish, shellInitAutoCDFish
+	default:
+		return fmt.Errorf("unsupported shell: %q (supported shells are bash, zsh and fish)", shell)
+	}
+
+	script = strings.ReplaceAll(script, "@SUBCOMMANDS@", strings.Join(shellCompletionSubcommands, " "))
+	if autoCD {
+		script += autoCDScript
+	}
+	ui.ShowResult(script)
+	return nil
+}
+
+// commandComplete implements `rtree complete`, which is not intended for
+// interactive use. It lists all arguments that `rtree get` understands, for
+// use by the shell completions from `rtree shell-init`. Since this runs on
+// every <Tab>, errors are not reported (see RTree.exec).
+func commandComplete(index *Index) {
+	seen := make(map[string]bool)
+	var items []string
+	add := func(item string) {
+		if item != "" && !seen[item] {
+			seen[item] = true
+			items = append(items, item)
+		}
+	}
+
+	for _, repo := range index.Repos {
+		add(repo.CheckoutPath)
+		for _, remote := range repo.Remotes {
+			for _, url := range remote.URLs {
+				add(url.CompactURL(index.tree.RemoteAliases))
+			}
+		}
+	}
+	for _, alias := range index.tree.RemoteAliases {
+		add(alias.Alias)
+	}
+	index.tree.ui.ShowResultsSorted(items)
+}
@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	Test{
+		Args:  []string{"complete"},
+		Index: testIndexWithTwoRepos,
+		ExpectOutput: strings.Join([]string{
+			"gh:",
+			"gh:foo/bar",
+			"gh:git/git",
+			"github.com/foo/bar",
+			"github.com/git/git",
+			"my/",
+		}, "\n") + "\n",
+	}.Run(t)
+}
+
+func TestShellInitUnknownShell(t *testing.T) {
+	Test{
+		Args:          []string{"shell-init", "csh"},
+		Index:         testIndexWithTwoRepos,
+		ExpectFailure: true,
+		ExpectError:   "!! unsupported shell: \"csh\" (supported shells are bash, zsh and fish)\n",
+	}.Run(t)
+}
+
+func TestCompleteFailsQuietly(t *testing.T) {
+	Test{
+		Args:          []string{"complete"},
+		Index:         Index{Version: 9999, Repos: []*Repo{}},
+		ExpectFailure: true,
+		ExpectIndex:   &Index{Version: 9999, Repos: []*Repo{}},
+	}.Run(t)
+}
+
+func TestCompleteDoesNotMigrateIndex(t *testing.T) {
+	original := `{"repos":[{"path":"github.com/git/git","remotes":{"origin":{"urls":["https://github.com/git/git"]}}}]}`
+	indexPath := writeTestIndexFile(t, original)
+
+	stdout, stderr := execReadOnly(t, "complete")
+	if stdout != "gh:\ngh:git/git\ngithub.com/git/git\nmy/\n" || stderr != "" {
+		t.Errorf("unexpected output from `rtree complete`: stdout = %q, stderr = %q", stdout, stderr)
+	}
+	expectFileContents(t, indexPath, original)
+}
+
+func TestShellInitScripts(t *testing.T) {
+	//`rtree shell-init` does not read the index, so it works even if the index is unusable
+	writeTestIndexFile(t, `{"version": 9999, "repos": []}`)
+
+	subcommands := "get new get-all drop index repos remotes aliases recent import each grep log which doctor " +
+		"check-remotes sync-heads hooks cache backup restore-backup shell-init"
+	testCases := []struct {
+		args          []string
+		expectedLines []string
+	}{
+		{
+			args: []string{"shell-init", "bash"},
+			expectedLines: []string{
+				"# shell integration for rtree (generated by `rtree shell-init bash`)",
+				`    COMPREPLY=( $(compgen -W "` + subcommands + `" -- "${COMP_WORDS[1]}") )`,
+				"complete -F _rtree_complete_candidates cg",
+				"complete -F _rtree_complete_rtree rtree",
+			},
+		},
+		{
+			args: []string{"shell-init", "zsh"},
+			expectedLines: []string{
+				"# shell integration for rtree (generated by `rtree shell-init zsh`)",
+				"    compadd " + subcommands,
+				"  compdef _rtree_complete_cg cg",
+				"  compdef _rtree_complete_rtree rtree",
+			},
+		},
+		{
+			args: []string{"shell-init", "fish"},
+			expectedLines: []string{
+				"# shell integration for rtree (generated by `rtree shell-init fish`)",
+				"set -l __rtree_subcommands " + subcommands,
+				"complete -c cg -f -a '(command rtree complete 2>/dev/null)'",
+			},
+		},
+		{
+			args: []string{"shell-init", "zsh", "--auto-cd"},
+			expectedLines: []string{
+				"    compadd " + subcommands,
+				`    dir="$(command rtree get "$2")" && cd "$dir"`,
+			},
+		},
+		{
+			args: []string{"shell-init", "fish", "--auto-cd"},
+			expectedLines: []string{
+				"set -l __rtree_subcommands " + subcommands,
+				"function rtree --wraps rtree --description 'rtree with auto-cd for `rtree get`'",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		stdout, stderr := execReadOnly(t, tc.args...)
+		if stderr != "" {
+			t.Errorf("expected no errors from %q, but got %q", tc.args, stderr)
+		}
+		if strings.Contains(stdout, "@SUBCOMMANDS@") {
+			t.Errorf("expected the placeholder to be replaced in the output of %q", tc.args)
+		}
+		lines := strings.Split(stdout, "\n")
+		for _, expected := range tc.expectedLines {
+			if !slices.Contains(lines, expected) {
+				t.Errorf("expected the output of %q to contain the line %q, but got:\n%s", tc.args, expected, stdout)
+			}
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"git.xyrillian.de/gofu/internal/cli"
+)
+
+// HeadCheck compares the default branch of a remote (i.e. the branch that
+// its HEAD points to) with what the local repo believes it to be.
+type HeadCheck struct {
+	Repo       *Repo
+	RemoteName string
+	//RemoteHead is the branch that the remote's HEAD points to.
+	RemoteHead string
+	//LocalHead is the branch that refs/remotes/<remote>/HEAD points to, or
+	//empty if that ref does not exist.
+	LocalHead string
+	//Err is set if the remote could not be queried.
+	Err error
+}
+
+// IsOutdated returns whether refs/remotes/<remote>/HEAD needs to be updated.
+// If that ref does not exist at all, it is not considered outdated, since Git
+// does not need it (see IsUnset).
+func (c HeadCheck) IsOutdated() bool {
+	return c.Err == nil && c.RemoteHead != "" && c.LocalHead != "" && c.RemoteHead != c.LocalHead
+}
+
+// IsUnset returns whether refs/remotes/<remote>/HEAD does not exist, even
+// though the remote has a default branch. This is common for remotes that
+// were added with `git remote add` instead of being cloned.
+func (c HeadCheck) IsUnset() bool {
+	return c.Err == nil && c.RemoteHead != "" && c.LocalHead == ""
+}
+
+// CheckHeads queries the HEAD of all remotes of all Git repos concurrently.
+// The results are in the order of the index. Jujutsu and Mercurial repos are
+// skipped, since they do not have a checked-out branch in the Git sense.
+func (i *Index) CheckHeads() []HeadCheck {
+	var repos []*Repo
+	for _, repo := range i.Repos {
+		if repo.VCS == VCSGit {
+			repos = append(repos, repo)
+		}
+	}
+
+	results := make([][]HeadCheck, len(repos))
+	foreachRepoConcurrently(repos, func(idx int, repo *Repo) {
+		for _, remoteName := range sortedRemoteNames(repo.Remotes) {
+			results[idx] = append(results[idx], repo.checkHead(remoteName))
+		}
+	})
+
+	var checks []HeadCheck
+	for _, result := range results {
+		checks = append(checks, result...)
+	}
+	return checks
+}
+
+func (r *Repo) checkHead(remoteName string) HeadCheck {
+	c := HeadCheck{Repo: r, RemoteName: remoteName}
+	repoPath := r.AbsolutePath()
+
+	stdout, stderr, err := r.tree.ui.CaptureOutput(cli.Command{
+		Program:  []string{"git", "ls-remote", "--symref", remoteName, "HEAD"},
+		WorkDir:  repoPath,
+		Env:      unattendedGitEnv,
+		Timeout:  remoteCheckTimeout,
+		ReadOnly: true,
+	})
+	if err != nil {
+		c.Err = fmt.Errorf("cannot query HEAD of remote %q in %s: %s", remoteName, repoPath, gitErrorMessage(stderr, err))
+		return c
+	}
+	//the output looks like "ref: refs/heads/main\tHEAD\n<sha>\tHEAD\n"
+	for line := range strings.SplitSeq(stdout, "\n") {
+		target, ok := strings.CutPrefix(line, "ref: refs/heads/")
+		if ok {
+			c.RemoteHead = strings.TrimSuffix(target, "\tHEAD")
+			break
+		}
+	}
+
+	//if refs/remotes/<remote>/HEAD does not exist, this fails without output
+	stdout, _, _ = r.tree.ui.CaptureOutput(cli.Command{
+		Program:  []string{"git", "symbolic-ref", "--quiet", "refs/remotes/" + remoteName + "/HEAD"},
+		WorkDir:  repoPath,
+		ReadOnly: true,
+	})
+	c.LocalHead = strings.TrimPrefix(strings.TrimSpace(stdout), "refs/remotes/"+remoteName+"/")
+	return c
+}
+
+// currentBranch returns the checked-out branch, as well as the remote and the
+// branch on that remote which it tracks. All return values are empty if HEAD
+// is detached, and the latter two are empty if the branch does not track any
+// remote branch.
+func (r Repo) currentBranch() (branch, remoteName, remoteBranch string) {
+	out, err := r.tree.ui.CaptureStdout(cli.Command{
+		Program:  []string{"git", "symbolic-ref", "--quiet", "--short", "HEAD"},
+		WorkDir:  r.AbsolutePath(),
+		ReadOnly: true,
+	})
+	branch = strings.TrimSpace(out)
+	if err != nil || branch == "" {
+		return "", "", ""
+	}
+	out, err = r.tree.ui.CaptureStdout(cli.Command{
+		Program:  []string{"git", "for-each-ref", "--format=%(upstream:remotename) %(upstream:remoteref)", "refs/heads/" + branch},
+		WorkDir:  r.AbsolutePath(),
+		ReadOnly: true,
+	})
+	if err != nil {
+		return branch, "", ""
+	}
+	remoteName, remoteRef, _ := strings.Cut(strings.TrimSpace(out), " ")
+	return branch, remoteName, strings.TrimPrefix(remoteRef, "refs/heads/")
+}
+
+// isClean returns whether the repo's worktree has no uncommitted changes.
+func (r Repo) isClean() (bool, error) {
+	out, err := r.tree.ui.CaptureStdout(cli.Command{
+		Program:  []string{"git", "status", "--porcelain"},
+		WorkDir:  r.AbsolutePath(),
+		ReadOnly: true,
+	})
+	return strings.TrimSpace(out) == "", err
+}
+
+// commandSyncHeads implements `rtree sync-heads`. For each remote whose
+// default branch has changed (e.g. from "master" to "main"), it offers to
+// update refs/remotes/<remote>/HEAD. If the checked-out branch tracks the old
+// default branch, it also offers to rename the branch and make it track the
+// new default branch. Like `rtree doctor`, it exits non-zero if problems
+// remain. Remotes without refs/remotes/<remote>/HEAD are only reported, since
+// that is not a problem.
+func commandSyncHeads(index *Index) int {
+	ui := index.tree.ui
+	exitCode := 0
+	for _, c := range index.CheckHeads() {
+		if c.Err != nil {
+			ui.ShowWarning(c.Err.Error())
+			exitCode = 1
+			continue
+		}
+		if c.IsUnset() {
+			ui.ShowProgress(fmt.Sprintf(
+				"refs/remotes/%s/HEAD is not set in %s (run `git remote set-head %s %s` to set it)",
+				c.RemoteName, c.Repo.AbsolutePath(), c.RemoteName, c.RemoteHead,
+			))
+			continue
+		}
+		if !c.IsOutdated() {
+			continue
+		}
+		switch err := c.sync(ui); {
+		case err == errNotFixed:
+			exitCode = 1
+		case err != nil:
+			ui.ShowError(err.Error())
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+// errNotFixed is returned by HeadCheck.sync when the user declined a fix.
+var errNotFixed = errors.New("problem was not fixed")
+
+func (c HeadCheck) sync(ui *cli.Implementation) error {
+	repo := c.Repo
+	repoPath := repo.AbsolutePath()
+	ui.ShowWarning(fmt.Sprintf("default branch of remote %q in %s is %q (was %q)", c.RemoteName, repoPath, c.RemoteHead, c.LocalHead))
+
+	ok, err := ui.Confirm(fmt.Sprintf(">> Update refs/remotes/%s/HEAD?", c.RemoteName))
+	if err != nil || !ok {
+		return notFixed(err)
+	}
+	//the new default branch needs to be fetched before HEAD can point to it
+	//(and the old one is usually gone, so we can clean up after it)
+	err = repo.tree.runIn(repoPath, "git", "fetch", "--quiet", "--prune", c.RemoteName)
+	if err == nil {
+		err = repo.tree.runIn(repoPath, "git", "remote", "set-head", c.RemoteName, c.RemoteHead)
+	}
+	if err != nil {
+		return err
+	}
+
+	//only the checked-out branch is considered, and only if it tracks the old
+	//default branch
+	branch, remoteName, remoteBranch := repo.currentBranch()
+	if remoteName != c.RemoteName || remoteBranch != c.LocalHead {
+		return nil
+	}
+	clean, err := repo.isClean()
+	if err != nil {
+		return err
+	}
+	if !clean {
+		ui.ShowWarning(fmt.Sprintf("branch %q in %s tracks %s/%s, but cannot be changed since the worktree is not clean", branch, repoPath, c.RemoteName, c.LocalHead))
+		return errNotFixed
+	}
+
+	newBranch := branch
+	question := fmt.Sprintf(">> Make branch %q track %s/%s?", branch, c.RemoteName, c.RemoteHead)
+	if branch == c.LocalHead {
+		newBranch = c.RemoteHead
+		question = fmt.Sprintf(">> Rename branch %q to %q and make it track %s/%s?", branch, newBranch, c.RemoteName, c.RemoteHead)
+	}
+	ok, err = ui.Confirm(question)
+	if err != nil || !ok {
+		return notFixed(err)
+	}
+	if newBranch != branch {
+		err := repo.tree.runIn(repoPath, "git", "branch", "--move", branch, newBranch)
+		if err != nil {
+			return err
+		}
+	}
+	return repo.tree.runIn(repoPath, "git", "branch", "--set-upstream-to="+c.RemoteName+"/"+c.RemoteHead, newBranch)
+}
+
+func notFixed(err error) error {
+	if err != nil {
+		return err
+	}
+	return errNotFixed
+}
@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyncHeads(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	rootPath := withTemporaryRootPath(t)
+	upstreamPath := filepath.Join(t.TempDir(), "upstream")
+	mustRunGit(t, "init", "--quiet", "--initial-branch=master", upstreamPath)
+	mustCommit(t, upstreamPath, "me@example.org", "2026-01-01T00:00:00Z", "initial commit")
+
+	//the upstream renames its default branch after we cloned it
+	repoPath := filepath.Join(rootPath, "example.org/foo")
+	upstreamURL := RemoteURL("file://" + upstreamPath)
+	mustRunGit(t, "clone", "--quiet", string(upstreamURL), repoPath)
+	mustRunGit(t, "-C", upstreamPath, "branch", "--move", "master", "main")
+
+	opts := testTreeOptions(t)
+	opts.Stdin = strings.NewReader("true\ntrue\n")
+	var stderr bytes.Buffer
+	opts.Stderr = &stderr
+	err := writeIndexFixture(opts.IndexPath, Index{Repos: []*Repo{{
+		CheckoutPath: "example.org/foo",
+		Remotes:      map[string]Remote{"origin": {URLs: []RemoteURL{upstreamURL}}},
+	}}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tree, err := New(opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exitCode := tree.exec([]string{"sync-heads"})
+	if exitCode != 0 {
+		t.Errorf("expected exit code 0, but got %d (stderr: %q)", exitCode, stderr.String())
+	}
+	for _, expected := range []string{
+		`default branch of remote "origin" in ` + repoPath + ` is "main" (was "master")`,
+		`Rename branch "master" to "main" and make it track origin/main?`,
+	} {
+		if !strings.Contains(stderr.String(), expected) {
+			t.Errorf("expected stderr to contain %q, but got %q", expected, stderr.String())
+		}
+	}
+
+	index, errs := tree.readIndex()
+	if len(errs) > 0 {
+		t.Fatal(errs[0].Error())
+	}
+	checks := index.CheckHeads()
+	if len(checks) != 1 || checks[0].IsOutdated() || checks[0].LocalHead != "main" {
+		t.Errorf("expected origin/HEAD to be updated, but got %#v", checks)
+	}
+	branch, remoteName, remoteBranch := index.Repos[0].currentBranch()
+	if branch != "main" || remoteName != "origin" || remoteBranch != "main" {
+		t.Errorf("expected branch main to track origin/main, but got branch %q tracking %s/%s", branch, remoteName, remoteBranch)
+	}
+}
+
+func TestSyncHeadsWithUnsetHead(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	rootPath := withTemporaryRootPath(t)
+	upstreamPath := filepath.Join(t.TempDir(), "upstream")
+	mustRunGit(t, "init", "--quiet", "--initial-branch=main", upstreamPath)
+	mustCommit(t, upstreamPath, "me@example.org", "2026-01-01T00:00:00Z", "initial commit")
+
+	//remotes that were added with `git remote add` do not have a HEAD ref
+	repoPath := filepath.Join(rootPath, "example.org/foo")
+	upstreamURL := RemoteURL("file://" + upstreamPath)
+	mustRunGit(t, "clone", "--quiet", string(upstreamURL), repoPath)
+	mustRunGit(t, "-C", repoPath, "remote", "set-head", "origin", "--delete")
+
+	opts := testTreeOptions(t)
+	var stderr bytes.Buffer
+	opts.Stderr = &stderr
+	err := writeIndexFixture(opts.IndexPath, Index{Repos: []*Repo{{
+		CheckoutPath: "example.org/foo",
+		Remotes:      map[string]Remote{"origin": {URLs: []RemoteURL{upstreamURL}}},
+	}}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tree, err := New(opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	//this is only reported, and does not count as a problem
+	exitCode := tree.exec([]string{"sync-heads"})
+	if exitCode != 0 {
+		t.Errorf("expected exit code 0, but got %d (stderr: %q)", exitCode, stderr.String())
+	}
+	expected := ">> refs/remotes/origin/HEAD is not set in " + repoPath + " (run `git remote set-head origin main` to set it)\n"
+	if stderr.String() != expected {
+		t.Errorf("expected stderr %q, but got %q", expected, stderr.String())
+	}
+}
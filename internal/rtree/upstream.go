@@ -0,0 +1,106 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"git.xyrillian.de/gofu/internal/cli"
+)
+
+// OfferForkParent looks up the origin of the given repo on its forge (if a
+// forge API is configured for its host). If the repo is a fork, the user is
+// offered to add the parent repo as the "upstream" remote, or to clone it into
+// its own checkout path.
+//
+// Errors from the forge API are only reported as warnings, since this is only
+// a convenience feature.
+func (i *Index) OfferForkParent(ctx context.Context, repo *Repo) error {
+	origin, ok := repo.Remotes["origin"]
+	if !ok || len(origin.URLs) == 0 {
+		return nil
+	}
+	if _, exists := repo.Remotes["upstream"]; exists {
+		return nil
+	}
+	originURL := origin.URLs[0]
+	host, repoPath, err := splitHostAndPath(originURL.CanonicalURL())
+	if err != nil {
+		return nil
+	}
+	client, err := i.tree.Config.forgeClient(host)
+	if err != nil || client == nil {
+		return err
+	}
+
+	info, err := client.GetRepo(ctx, strings.Trim(repoPath, "/"))
+	if err != nil {
+		i.tree.ui.ShowWarning(fmt.Sprintf("cannot check whether %s is a fork: %s", originURL.CompactURL(i.tree.RemoteAliases), err.Error()))
+		return nil
+	}
+	if info.Parent == nil {
+		return nil
+	}
+
+	//use the same transport for the parent as for the origin
+	parentURLStr := info.Parent.CloneURL
+	if info.Parent.SSHURL != "" && !strings.HasPrefix(originURL.CanonicalURL(), "http") {
+		parentURLStr = info.Parent.SSHURL
+	}
+	parentURL := RemoteURL(strings.TrimSuffix(parentURLStr, ".git"))
+	parentRepo, err := i.tree.NewRepoFromRemoteURL(parentURL)
+	if err != nil {
+		return err
+	}
+
+	choices := []cli.Choice{
+		{Return: "upstream", Shortcut: 'u', Text: `add as remote "upstream"`},
+	}
+	isParentCheckedOut := false
+	for _, other := range i.Repos {
+		if other.HasSameOriginAs(parentRepo) {
+			isParentCheckedOut = true
+		}
+	}
+	if !isParentCheckedOut {
+		choices = append(choices, cli.Choice{Return: "clone", Shortcut: 'c', Text: "clone to " + parentRepo.AbsolutePath()})
+	}
+	choices = append(choices, cli.Choice{Return: "skip", Shortcut: 's', Text: "skip"})
+
+	selection, err := i.tree.ui.Query(
+		fmt.Sprintf("%s is a fork of %s. What to do?", originURL.CompactURL(i.tree.RemoteAliases), parentURL.CompactURL(i.tree.RemoteAliases)),
+		choices...,
+	)
+	if err != nil {
+		return err
+	}
+
+	switch selection {
+	case "upstream":
+		err := repo.backend().AddRemote(repo.AbsolutePath(), "upstream", parentURL.ConfigURL(i.tree.RemoteAliases))
+		if err != nil {
+			return err
+		}
+		err = repo.backend().FetchRemotes(ctx, repo.AbsolutePath(), "upstream")
+		if err != nil {
+			return err
+		}
+		repo.Remotes["upstream"] = Remote{URLs: []RemoteURL{parentURL}}
+	case "clone":
+		_, err := os.Stat(parentRepo.AbsolutePath())
+		if err == nil {
+			return fmt.Errorf("%s already exists (if there is a repo there, try `rtree index`)", parentRepo.AbsolutePath())
+		}
+		err = parentRepo.Checkout(ctx)
+		if err != nil {
+			return err
+		}
+		i.Repos = append(i.Repos, &parentRepo)
+		parentRepo.runHooksAfterCheckout(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// withForge configures a forge API for the given host that serves the given
+// JSON payloads (keyed by request path, with or without query string) for the
+// duration of a test.
+func withForge(t *testing.T, host, forgeType string, responses map[string]string) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, ok := responses[r.URL.EscapedPath()+"?"+r.URL.RawQuery]
+		if !ok {
+			payload, ok = responses[r.URL.EscapedPath()]
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(payload))
+	}))
+	t.Cleanup(s.Close)
+
+	savedConfig := testConfig
+	testConfig = &Configuration{
+		Forges: map[string]ForgeConfig{
+			host: {Type: forgeType, APIURL: s.URL, Token: "secret"},
+		},
+	}
+	t.Cleanup(func() { testConfig = savedConfig })
+}
+
+func TestGetForkAddsUpstream(t *testing.T) {
+	withForge(t, "github.com", "github", map[string]string{
+		"/repos/me/repo": `{"full_name":"me/repo","fork":true,"parent":{"full_name":"another/repo","clone_url":"https://github.com/another/repo"}}`,
+	})
+
+	target := filepath.Join(testRootPath, "/github.com/me/repo")
+	Test{
+		Args:         []string{"get", "gh:me/repo"},
+		Index:        testIndexWithTwoRepos,
+		Input:        "u\n",
+		ExpectOutput: target + "\n",
+		ExpectError:  "gh:me/repo is a fork of gh:another/repo. What to do? -> add as remote \"upstream\"\n",
+		ExpectExecution: Recorded(
+			"git clone https://github.com/me/repo "+target,
+			"@"+target+" git rev-list --max-parents=0 --all",
+			"@"+target+" git remote add upstream gh:another/repo",
+			"@"+target+" git remote update upstream",
+		),
+		ExpectIndex: &Index{
+			Repos: []*Repo{
+				testIndexWithTwoRepos.Repos[0],
+				testIndexWithTwoRepos.Repos[1],
+				{
+					CheckoutPath: "github.com/me/repo",
+					Remotes: map[string]Remote{
+						"origin":   {URLs: []RemoteURL{"https://github.com/me/repo"}},
+						"upstream": {URLs: []RemoteURL{"https://github.com/another/repo"}},
+					},
+				},
+			},
+		},
+	}.Run(t)
+}
+
+func TestGetForkClonesParent(t *testing.T) {
+	withForge(t, "github.com", "github", map[string]string{
+		"/repos/me/repo": `{"full_name":"me/repo","fork":true,"parent":{"full_name":"another/repo","clone_url":"https://github.com/another/repo"}}`,
+	})
+
+	target := filepath.Join(testRootPath, "/github.com/me/repo")
+	parentTarget := filepath.Join(testRootPath, "/github.com/another/repo")
+	Test{
+		Args:         []string{"get", "gh:me/repo"},
+		Index:        testIndexWithTwoRepos,
+		Input:        "c\n",
+		ExpectOutput: target + "\n",
+		ExpectError:  "gh:me/repo is a fork of gh:another/repo. What to do? -> clone to " + parentTarget + "\n",
+		ExpectExecution: Recorded(
+			"git clone https://github.com/me/repo "+target,
+			"@"+target+" git rev-list --max-parents=0 --all",
+			"git clone https://github.com/another/repo "+parentTarget,
+		),
+		ExpectIndex: &Index{
+			Repos: []*Repo{
+				{
+					CheckoutPath: "github.com/another/repo",
+					Remotes: map[string]Remote{
+						"origin": {URLs: []RemoteURL{"https://github.com/another/repo"}},
+					},
+				},
+				testIndexWithTwoRepos.Repos[0],
+				testIndexWithTwoRepos.Repos[1],
+				{
+					CheckoutPath: "github.com/me/repo",
+					Remotes: map[string]Remote{
+						"origin": {URLs: []RemoteURL{"https://github.com/me/repo"}},
+					},
+				},
+			},
+		},
+	}.Run(t)
+}
@@ -0,0 +1,351 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"git.xyrillian.de/gofu/internal/cli"
+)
+
+// VCS identifies the version control system that a repo is managed with.
+type VCS string
+
+const (
+	//VCSGit is the default, so it is not written into the index explicitly.
+	VCSGit VCS = ""
+	//VCSJujutsu is a Jujutsu repo whose Git repo is hidden inside the .jj directory.
+	VCSJujutsu VCS = "jj"
+	//VCSJujutsuColocated is a Jujutsu repo with a .git directory next to the .jj directory.
+	VCSJujutsuColocated VCS = "jj-colocated"
+	//VCSMercurial is a Mercurial repo.
+	VCSMercurial VCS = "hg"
+)
+
+// detectVCS checks which VCS manages the repo at the given path. Returns false
+// if the directory does not look like a repo.
+func detectVCS(path string) (VCS, bool) {
+	return detectVCSBy(func(name string) bool {
+		_, err := os.Stat(filepath.Join(path, name))
+		return err == nil
+	})
+}
+
+// detectVCSBy is like detectVCS, but takes a function that checks whether the
+// directory contains an entry with the given name.
+func detectVCSBy(exists func(name string) bool) (VCS, bool) {
+	switch {
+	case exists(".jj") && exists(".git"):
+		return VCSJujutsuColocated, true
+	case exists(".jj"):
+		return VCSJujutsu, true
+	case exists(".hg"):
+		return VCSMercurial, true
+	case exists(".git"):
+		return VCSGit, true
+	default:
+		return VCSGit, false
+	}
+}
+
+// vcsBackend contains all VCS-specific operations on repos. Features that
+// operate directly on Git objects (fork detection, the object cache, backups)
+// only work for repos that have a .git directory.
+type vcsBackend interface {
+	//MetadataDir is the directory that marks a checkout, e.g. ".git".
+	MetadataDir() string
+	//ReadRemotes lists pairs of remote name and URL in the checkout at the
+	//given path. A remote with multiple URLs appears multiple times.
+	ReadRemotes(repoPath string) ([][2]string, error)
+	//Clone and FetchRemotes talk to remotes, so they can take a long time.
+	//Their commands are interrupted when the given context is canceled.
+	Clone(ctx context.Context, repoPath string, url RemoteURL) error
+	Init(repoPath string) error
+	//AddRemote and the other methods for editing remotes take URLs in the
+	//form in which they shall appear in the repo's configuration.
+	AddRemote(repoPath, name, url string) error
+	//AddRemoteURL adds another URL to an existing remote.
+	AddRemoteURL(repoPath, name, url string) error
+	DeleteRemoteURL(repoPath, name, url string) error
+	RemoveRemote(repoPath, name string) error
+	SetRemoteURL(repoPath, name, url string) error
+	//FetchRemotes fetches the given remote, or all remotes if name is empty.
+	FetchRemotes(ctx context.Context, repoPath, name string) error
+	StatusCommand() []string
+}
+
+// backend returns the implementation of VCS-specific operations for this repo.
+func (r Repo) backend() vcsBackend {
+	switch r.VCS {
+	case VCSJujutsu:
+		return jjBackend{r.tree, false}
+	case VCSJujutsuColocated:
+		return jjBackend{r.tree, true}
+	case VCSMercurial:
+		return hgBackend{r.tree}
+	default:
+		return gitBackend{r.tree}
+	}
+}
+
+func (t *RTree) runIn(repoPath string, cmdline ...string) error {
+	return t.ui.Run(cli.Command{Program: cmdline, WorkDir: repoPath})
+}
+
+// runInContext is like runIn, but interrupts the command when the given
+// context is canceled.
+func (t *RTree) runInContext(ctx context.Context, repoPath string, cmdline ...string) error {
+	return t.ui.Run(cli.Command{Program: cmdline, WorkDir: repoPath, Context: ctx})
+}
+
+// gitBackend implements vcsBackend for Git.
+type gitBackend struct {
+	tree *RTree
+}
+
+func (gitBackend) MetadataDir() string { return ".git" }
+
+func (b gitBackend) ReadRemotes(repoPath string) ([][2]string, error) {
+	//reading the config file directly is much faster than asking Git, but if
+	//that does not work for any reason, Git will know what to do (or at least
+	//report a useful error)
+	remotes, err := readGitRemotes(repoPath)
+	if err == nil {
+		return remotes, nil
+	}
+
+	out, err := b.tree.ui.CaptureStdout(cli.Command{
+		Program:  []string{"git", "config", "-l"},
+		WorkDir:  repoPath,
+		ReadOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result [][2]string
+	for line := range strings.SplitSeq(out, "\n") {
+		match := remoteConfigRx.FindStringSubmatch(line)
+		if match != nil {
+			result = append(result, [2]string{match[1], match[2]})
+		}
+	}
+	return result, nil
+}
+
+func (b gitBackend) Clone(ctx context.Context, repoPath string, url RemoteURL) error {
+	cmdline := []string{"git", "clone"}
+	if cachePath := b.tree.prepareCacheFor(ctx, url); cachePath != "" {
+		cmdline = append(cmdline, "--reference-if-able", cachePath)
+		if !b.tree.Config.Cache.ShareObjects {
+			cmdline = append(cmdline, "--dissociate")
+		}
+	}
+	return b.tree.runInContext(ctx, "", append(cmdline, url.CanonicalURL(), repoPath)...)
+}
+
+func (b gitBackend) Init(repoPath string) error {
+	return b.tree.runIn("", "git", "init", repoPath)
+}
+
+func (b gitBackend) AddRemote(repoPath, name, url string) error {
+	return b.tree.runIn(repoPath, "git", "remote", "add", name, url)
+}
+
+func (b gitBackend) AddRemoteURL(repoPath, name, url string) error {
+	return b.tree.runIn(repoPath, "git", "remote", "set-url", "--add", name, url)
+}
+
+func (b gitBackend) DeleteRemoteURL(repoPath, name, url string) error {
+	return b.tree.runIn(repoPath, "git", "remote", "set-url", "--delete", name, url)
+}
+
+func (b gitBackend) RemoveRemote(repoPath, name string) error {
+	return b.tree.runIn(repoPath, "git", "remote", "remove", name)
+}
+
+func (b gitBackend) SetRemoteURL(repoPath, name, url string) error {
+	return b.tree.runIn(repoPath, "git", "remote", "set-url", name, url)
+}
+
+func (b gitBackend) FetchRemotes(ctx context.Context, repoPath, name string) error {
+	if name == "" {
+		return b.tree.runInContext(ctx, repoPath, "git", "remote", "update")
+	}
+	return b.tree.runInContext(ctx, repoPath, "git", "remote", "update", name)
+}
+
+func (gitBackend) StatusCommand() []string { return []string{"git", "status"} }
+
+// jjBackend implements vcsBackend for Jujutsu.
+type jjBackend struct {
+	tree      *RTree
+	colocated bool
+}
+
+func (jjBackend) MetadataDir() string { return ".jj" }
+
+func (b jjBackend) ReadRemotes(repoPath string) ([][2]string, error) {
+	out, err := b.tree.ui.CaptureStdout(cli.Command{
+		Program:  []string{"jj", "git", "remote", "list"},
+		WorkDir:  repoPath,
+		ReadOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result [][2]string
+	for line := range strings.SplitSeq(out, "\n") {
+		name, url, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if ok {
+			result = append(result, [2]string{name, strings.TrimSpace(url)})
+		}
+	}
+	return result, nil
+}
+
+func (b jjBackend) Clone(ctx context.Context, repoPath string, url RemoteURL) error {
+	return b.tree.runInContext(ctx, "", "jj", "git", "clone", b.colocateFlag(), url.CanonicalURL(), repoPath)
+}
+
+func (b jjBackend) Init(repoPath string) error {
+	return b.tree.runIn("", "jj", "git", "init", b.colocateFlag(), repoPath)
+}
+
+func (b jjBackend) colocateFlag() string {
+	if b.colocated {
+		return "--colocate"
+	}
+	return "--no-colocate"
+}
+
+func (b jjBackend) AddRemote(repoPath, name, url string) error {
+	return b.tree.runIn(repoPath, "jj", "git", "remote", "add", name, url)
+}
+
+func (b jjBackend) AddRemoteURL(repoPath, name, url string) error {
+	b.tree.ui.ShowWarning(fmt.Sprintf("cannot add %s to remote %q in %s: jj does not support multiple URLs per remote", url, name, repoPath))
+	return nil
+}
+
+func (b jjBackend) DeleteRemoteURL(repoPath, name, url string) error {
+	//there can only be one URL per remote, see AddRemoteURL
+	return fmt.Errorf("cannot delete %s from remote %q in %s: %w for jj", url, name, repoPath, errors.ErrUnsupported)
+}
+
+func (b jjBackend) RemoveRemote(repoPath, name string) error {
+	return b.tree.runIn(repoPath, "jj", "git", "remote", "remove", name)
+}
+
+func (b jjBackend) SetRemoteURL(repoPath, name, url string) error {
+	return b.tree.runIn(repoPath, "jj", "git", "remote", "set-url", name, url)
+}
+
+func (b jjBackend) FetchRemotes(ctx context.Context, repoPath, name string) error {
+	if name == "" {
+		return b.tree.runInContext(ctx, repoPath, "jj", "git", "fetch", "--all-remotes")
+	}
+	return b.tree.runInContext(ctx, repoPath, "jj", "git", "fetch", "--remote", name)
+}
+
+func (jjBackend) StatusCommand() []string { return []string{"jj", "status"} }
+
+// hgBackend implements vcsBackend for Mercurial. Mercurial calls remotes
+// "paths". The path called "default" is presented to the rest of rtree as
+// "origin", since that remote determines the checkout path.
+type hgBackend struct {
+	tree *RTree
+}
+
+func (hgBackend) MetadataDir() string { return ".hg" }
+
+func hgPathName(remoteName string) string {
+	if remoteName == "origin" {
+		return "default"
+	}
+	return remoteName
+}
+
+func (b hgBackend) ReadRemotes(repoPath string) ([][2]string, error) {
+	out, err := b.tree.ui.CaptureStdout(cli.Command{
+		Program:  []string{"hg", "paths"},
+		WorkDir:  repoPath,
+		ReadOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result [][2]string
+	for line := range strings.SplitSeq(out, "\n") {
+		name, url, ok := strings.Cut(line, " = ")
+		//skip sub-options like "default:pushurl"
+		if !ok || strings.Contains(name, ":") {
+			continue
+		}
+		if name == "default" {
+			name = "origin"
+		}
+		result = append(result, [2]string{name, strings.TrimSpace(url)})
+	}
+	return result, nil
+}
+
+func (b hgBackend) Clone(ctx context.Context, repoPath string, url RemoteURL) error {
+	return b.tree.runInContext(ctx, "", "hg", "clone", url.CanonicalURL(), repoPath)
+}
+
+func (b hgBackend) Init(repoPath string) error {
+	return b.tree.runIn("", "hg", "init", repoPath)
+}
+
+func (b hgBackend) AddRemote(repoPath, name, url string) error {
+	//hg has no command for this, but repeated sections in hgrc are merged
+	hgrcPath := filepath.Join(repoPath, ".hg/hgrc")
+	section := fmt.Sprintf("\n[paths]\n%s = %s\n", hgPathName(name), url)
+	return b.tree.fs.AppendFile(hgrcPath, []byte(section), 0644)
+}
+
+func (b hgBackend) AddRemoteURL(repoPath, name, url string) error {
+	b.tree.ui.ShowWarning(fmt.Sprintf("cannot add %s to remote %q in %s: hg does not support multiple URLs per path", url, name, repoPath))
+	return nil
+}
+
+func (b hgBackend) DeleteRemoteURL(repoPath, name, url string) error {
+	//there can only be one URL per path, see AddRemoteURL
+	return fmt.Errorf("cannot delete %s from path %q in %s: %w for hg", url, hgPathName(name), repoPath, errors.ErrUnsupported)
+}
+
+func (b hgBackend) RemoveRemote(repoPath, name string) error {
+	//see SetRemoteURL
+	return fmt.Errorf("cannot remove path %q in %s: %w for hg (please edit .hg/hgrc manually)", hgPathName(name), repoPath, errors.ErrUnsupported)
+}
+
+func (b hgBackend) SetRemoteURL(repoPath, name, url string) error {
+	//hg has no command for changing paths, and rewriting the hgrc could
+	//mangle comments and includes, so the URLs are left as they are
+	return fmt.Errorf("cannot change path %q in %s to %s: %w for hg (please edit .hg/hgrc manually)", hgPathName(name), repoPath, url, errors.ErrUnsupported)
+}
+
+func (b hgBackend) FetchRemotes(ctx context.Context, repoPath, name string) error {
+	if name != "" {
+		return b.tree.runInContext(ctx, repoPath, "hg", "pull", hgPathName(name))
+	}
+	remotes, err := b.ReadRemotes(repoPath)
+	if err != nil {
+		return err
+	}
+	for _, remote := range remotes {
+		err := b.tree.runInContext(ctx, repoPath, "hg", "pull", hgPathName(remote[0]))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (hgBackend) StatusCommand() []string { return []string{"hg", "status"} }
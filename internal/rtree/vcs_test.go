@@ -0,0 +1,85 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIndexOtherVCS(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	for _, dirPath := range []string{"github.com/jj/plain/.jj", "hg.example.org/foo/.hg"} {
+		err := os.MkdirAll(filepath.Join(rootPath, dirPath), 0755)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	jjPath := filepath.Join(rootPath, "github.com/jj/plain")
+	hgPath := filepath.Join(rootPath, "hg.example.org/foo")
+
+	jjRemotes := RecordedCommand{
+		Cmd:    Recorded("@" + jjPath + " jj git remote list")[0].Cmd,
+		Stdout: "origin https://github.com/jj/plain\n",
+	}
+	hgRemotes := RecordedCommand{
+		Cmd: Recorded("@" + hgPath + " hg paths")[0].Cmd,
+		Stdout: "default = https://hg.example.org/foo\n" +
+			"default:pushurl = ssh://hg.example.org/foo\n" +
+			"mirror = https://mirror.example.org/foo\n",
+	}
+
+	Test{
+		Args:            []string{"index"},
+		Index:           Index{Repos: []*Repo{}},
+		ExpectExecution: []RecordedCommand{jjRemotes, hgRemotes, jjRemotes, hgRemotes},
+		ExpectIndex: &Index{
+			Repos: []*Repo{
+				{
+					CheckoutPath: "github.com/jj/plain",
+					Remotes: map[string]Remote{
+						"origin": {URLs: []RemoteURL{"https://github.com/jj/plain"}},
+					},
+					VCS: VCSJujutsu,
+				},
+				{
+					CheckoutPath: "hg.example.org/foo",
+					Remotes: map[string]Remote{
+						"mirror": {URLs: []RemoteURL{"https://mirror.example.org/foo"}},
+						"origin": {URLs: []RemoteURL{"https://hg.example.org/foo"}},
+					},
+					VCS: VCSMercurial,
+				},
+			},
+		},
+	}.Run(t)
+}
+
+func TestRestoreOtherVCS(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	repo := &Repo{
+		CheckoutPath: "github.com/jj/colocated",
+		Remotes: map[string]Remote{
+			"origin": {URLs: []RemoteURL{"https://github.com/jj/colocated"}},
+		},
+		VCS: VCSJujutsuColocated,
+	}
+
+	repoPath := filepath.Join(rootPath, repo.CheckoutPath)
+
+	Test{
+		Args:        []string{"index"},
+		Input:       "r\n",
+		Index:       Index{Repos: []*Repo{repo}},
+		ExpectError: "repository " + repoPath + " has been deleted -> restore from gh:jj/colocated\n",
+		ExpectExecution: []RecordedCommand{
+			Recorded("jj git clone --colocate https://github.com/jj/colocated " + repoPath)[0],
+			{
+				Cmd:    Recorded("@" + repoPath + " jj git remote list")[0].Cmd,
+				Stdout: "origin https://github.com/jj/colocated\n",
+			},
+		},
+	}.Run(t)
+}
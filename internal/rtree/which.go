@@ -0,0 +1,124 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// FindRepoByPath locates the index entry for the repo containing the given
+// path. Symlinks are resolved first, so paths below the compatibility
+// symlinks left behind by Repo.Move() are recognized as well. Returns nil if
+// the path is not inside any indexed repo, or an error if it is not below the
+// RootPath at all.
+func (i *Index) FindRepoByPath(dirPath string) (*Repo, error) {
+	absPath, err := filepath.Abs(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	absPath, err = filepath.EvalSymlinks(absPath)
+	if err != nil {
+		return nil, err
+	}
+	rootPath, err := filepath.EvalSymlinks(i.tree.RootPath)
+	if err != nil {
+		return nil, err
+	}
+	relPath, err := filepath.Rel(rootPath, absPath)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, "../") {
+		return nil, fmt.Errorf("%s is not below the root path %s", dirPath, i.tree.RootPath)
+	}
+
+	reposByPath := make(map[string]*Repo, len(i.Repos))
+	for _, repo := range i.Repos {
+		reposByPath[repo.CheckoutPath] = repo
+	}
+
+	//walk upwards until we find a directory that is an indexed repo
+	for ; relPath != "."; relPath = filepath.Dir(relPath) {
+		if repo, ok := reposByPath[relPath]; ok {
+			return repo, nil
+		}
+	}
+	return nil, nil
+}
+
+// commandWhich implements `rtree which`.
+func commandWhich(index *Index, dirPath string) error {
+	if dirPath == "" {
+		var err error
+		dirPath, err = os.Getwd()
+		if err != nil {
+			return err
+		}
+	}
+
+	repo, err := index.FindRepoByPath(dirPath)
+	if err != nil {
+		return err
+	}
+	if repo == nil {
+		return fmt.Errorf("%s is not inside a repo that is tracked by rtree", dirPath)
+	}
+
+	var lines []string
+	lines = append(lines, "checkout path: "+repo.AbsolutePath())
+	for _, remoteName := range sortedRemoteNames(repo.Remotes) {
+		for _, url := range repo.Remotes[remoteName].URLs {
+			lines = append(lines, fmt.Sprintf("remote %s: %s = %s", remoteName, url.CompactURL(index.tree.RemoteAliases), url.CanonicalURL()))
+		}
+	}
+
+	actualRepo, err := repo.tree.NewRepoFromAbsolutePath(repo.AbsolutePath(), true)
+	if err != nil {
+		return err
+	}
+	diffs := diffRemotes(repo.Remotes, actualRepo.Remotes, repo.tree.RemoteAliases)
+	if len(diffs) == 0 {
+		lines = append(lines, "on-disk remotes: same as in index")
+	} else {
+		lines = append(lines, "on-disk remotes: differ from index (run `rtree index` to update the index)")
+		for _, diff := range diffs {
+			lines = append(lines, "  "+diff)
+		}
+	}
+
+	index.tree.ui.ShowResult(strings.Join(lines, "\n"))
+	return nil
+}
+
+func sortedRemoteNames(remotes map[string]Remote) []string {
+	names := make([]string, 0, len(remotes))
+	for name := range remotes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// diffRemotes describes all differences between the remotes of an index entry
+// and those found on disk, one line per difference.
+func diffRemotes(indexed, actual map[string]Remote, aliases []*RemoteAlias) (diffs []string) {
+	for _, name := range sortedRemoteNames(indexed) {
+		actualRemote, exists := actual[name]
+		switch {
+		case !exists:
+			diffs = append(diffs, fmt.Sprintf("remote %s is only in the index", name))
+		case !slices.Equal(indexed[name].URLs, actualRemote.URLs):
+			diffs = append(diffs, fmt.Sprintf("remote %s has URLs %s in the index, but %s on disk",
+				name, strings.Join(indexed[name].CompactURLs(aliases), " "), strings.Join(actualRemote.CompactURLs(aliases), " ")))
+		}
+	}
+	for _, name := range sortedRemoteNames(actual) {
+		if _, exists := indexed[name]; !exists {
+			diffs = append(diffs, fmt.Sprintf("remote %s is only on disk", name))
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,78 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package rtree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withTemporaryRootPath points testRootPath to an empty temporary directory for
+// the duration of a test, for tests that need to inspect the filesystem.
+func withTemporaryRootPath(t *testing.T) string {
+	savedRootPath := testRootPath
+	testRootPath = t.TempDir()
+	t.Cleanup(func() { testRootPath = savedRootPath })
+	return testRootPath
+}
+
+func TestWhich(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	repoPath := filepath.Join(rootPath, "github.com/git/git")
+	err := os.MkdirAll(filepath.Join(repoPath, ".git"), 0755)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = os.MkdirAll(filepath.Join(repoPath, "Documentation"), 0755)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	//compatibility symlink as left behind by `rtree import`
+	oldPath := filepath.Join(rootPath, "../old-git-checkout")
+	err = os.Symlink(repoPath, oldPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(oldPath)
+
+	for _, dirPath := range []string{repoPath, filepath.Join(repoPath, "Documentation"), oldPath} {
+		Test{
+			Args:  []string{"which", dirPath},
+			Index: testIndexWithTwoRepos,
+			ExpectOutput: "checkout path: " + repoPath + "\n" +
+				"remote origin: gh:git/git = https://github.com/git/git\n" +
+				"on-disk remotes: differ from index (run `rtree index` to update the index)\n" +
+				"  remote origin has URLs gh:git/git in the index, but gh:git/git.git on disk\n" +
+				"  remote upstream is only on disk\n",
+			ExpectExecution: []RecordedCommand{{
+				Cmd: Recorded("@" + repoPath + " git config -l")[0].Cmd,
+				Stdout: "remote.origin.url=https://github.com/git/git.git\n" +
+					"remote.upstream.url=https://git.kernel.org/pub/scm/git/git.git\n",
+			}},
+		}.Run(t)
+	}
+}
+
+func TestWhichUntracked(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	Test{
+		Args:          []string{"which", rootPath},
+		Index:         testIndexWithTwoRepos,
+		ExpectFailure: true,
+		ExpectError:   "!! " + rootPath + " is not inside a repo that is tracked by rtree\n",
+	}.Run(t)
+}
+
+func TestWhichOutsideRootPath(t *testing.T) {
+	rootPath := withTemporaryRootPath(t)
+	for _, dirPath := range []string{filepath.Dir(rootPath), t.TempDir()} {
+		Test{
+			Args:          []string{"which", dirPath},
+			Index:         testIndexWithTwoRepos,
+			ExpectFailure: true,
+			ExpectError:   "!! " + dirPath + " is not below the root path " + rootPath + "\n",
+		}.Run(t)
+	}
+}
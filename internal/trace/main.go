@@ -0,0 +1,98 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"git.xyrillian.de/gofu/internal/cli"
+)
+
+// Exec executes the trace applet and returns an exit code (0 for success, >0 for error).
+func Exec(args []string) int {
+	if len(args) != 1 || args[0] != "last" {
+		os.Stderr.Write([]byte("Usage: gofu trace last\n"))
+		return 1
+	}
+
+	path, err := cli.TraceLogPath()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR: cannot locate trace log: "+err.Error())
+		return 1
+	}
+	var entries []cli.TraceEntry
+	//the last session may have started before the log was rotated
+	for _, p := range []string{path + ".1", path} {
+		e, err := readTraceLog(p)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR: "+err.Error())
+			return 1
+		}
+		entries = append(entries, e...)
+	}
+	if len(entries) == 0 {
+		fmt.Fprintf(os.Stderr, "ERROR: no commands in %s (run with %s=file to record them)\n", path, cli.TraceEnvVar)
+		return 1
+	}
+
+	last := entries[len(entries)-1]
+	fmt.Printf("%s (started %s)\n", strings.Join(last.Invocation, " "), sessionStart(entries, last.Session).Format(time.DateTime))
+	var (
+		count, failed int
+		total         time.Duration
+	)
+	for _, e := range entries {
+		if e.Session != last.Session {
+			continue
+		}
+		os.Stdout.Write([]byte(e.String()))
+		count++
+		total += e.Duration
+		if e.ExitCode != 0 {
+			failed++
+		}
+	}
+	fmt.Printf("%d commands (%d failed) in %.3fs\n", count, failed, total.Seconds())
+	return 0
+}
+
+// readTraceLog reads all entries from the given trace log file. A missing
+// file counts as empty. Lines that cannot be parsed (e.g. if a write was
+// interrupted) are skipped.
+func readTraceLog(path string) ([]cli.TraceEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []cli.TraceEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e cli.TraceEntry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// sessionStart returns when the first command of the given session was run.
+func sessionStart(entries []cli.TraceEntry, session string) time.Time {
+	for _, e := range entries {
+		if e.Session == session {
+			return e.StartedAt
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"git.xyrillian.de/gofu/internal/cli"
+)
+
+func writeTraceLog(t *testing.T, path string, entries ...cli.TraceEntry) {
+	var lines []string
+	for _, e := range entries {
+		buf, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		lines = append(lines, string(buf)+"\n")
+	}
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err == nil {
+		err = os.WriteFile(path, []byte(strings.Join(lines, "")), 0600)
+	}
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+// captureExec runs Exec with the given args and returns its exit code and
+// what it printed on stdout.
+func captureExec(t *testing.T, args ...string) (int, string) {
+	f, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer f.Close()
+	origStdout := os.Stdout
+	os.Stdout = f
+	exitCode := Exec(args)
+	os.Stdout = origStdout
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	buf, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return exitCode, string(buf)
+}
+
+func TestTraceLastAcrossRotation(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	path, err := cli.TraceLogPath()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
+	entry := func(session string, offset time.Duration, exitCode int, program ...string) cli.TraceEntry {
+		return cli.TraceEntry{
+			Session:    session,
+			Invocation: []string{"rtree", session},
+			StartedAt:  start.Add(offset),
+			Program:    program,
+			Duration:   500 * time.Millisecond,
+			ExitCode:   exitCode,
+		}
+	}
+	//the last session started before the log was rotated, so its first
+	//command is in trace.log.1
+	writeTraceLog(t, path+".1",
+		entry("old", 0, 0, "git", "status"),
+		entry("last", time.Minute, 0, "git", "fetch"),
+	)
+	writeTraceLog(t, path,
+		entry("last", time.Minute+time.Second, 1, "git", "pull"),
+	)
+
+	exitCode, output := captureExec(t, "last")
+	if exitCode != 0 {
+		t.Errorf("expected exit code 0, but got %d", exitCode)
+	}
+	expected := "rtree last (started 2026-01-02 03:05:05)\n" +
+		"03:05:05   0.500s exit 0: git fetch\n" +
+		"03:05:06   0.500s exit 1: git pull\n" +
+		"2 commands (1 failed) in 1.000s\n"
+	if output != expected {
+		t.Errorf("expected output %q, but got %q", expected, output)
+	}
+}
+
+func TestTraceLastWithoutLog(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	exitCode, output := captureExec(t, "last")
+	if exitCode != 1 || output != "" {
+		t.Errorf("expected exit code 1 without output, but got %d and %q", exitCode, output)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"git.xyrillian.de/gofu/internal/mdedit"
 	"git.xyrillian.de/gofu/internal/prompt"
 	"git.xyrillian.de/gofu/internal/rtree"
+	"git.xyrillian.de/gofu/internal/trace"
 )
 
 func main() {
@@ -34,6 +35,8 @@ func execApplet(applet string, args []string, allowGofu bool) int {
 		return prompt.Exec(args)
 	case "rtree":
 		return rtree.Exec(args)
+	case "trace":
+		return trace.Exec(args)
 	default:
 		fmt.Fprintln(os.Stderr, "ERROR: unknown applet: "+applet)
 		return 255
@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2026 Stefan Majewsky <majewsky@gmx.net>
+// SPDX-License-Identifier: GPL-3.0-only
+
+// Package rtree makes the repository tree management of the `rtree` applet
+// available to other Go programs. Everything is configured through the Options
+// given to New, so several RTree instances can be used side by side:
+//
+//	opts, err := rtree.DefaultOptions() //same settings as the `rtree` command
+//	if err != nil {
+//		return err
+//	}
+//	tree, err := rtree.New(opts)
+//	if err != nil {
+//		return err
+//	}
+//	repo, err := tree.Get(ctx, "https://github.com/git/git")
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Println(repo.AbsolutePath())
+//
+// Unlike the `rtree` command, the methods of RTree never prompt the user.
+// Output from subprocesses is discarded unless Options.Stdout and
+// Options.Stderr are given.
+package rtree
+
+import (
+	"git.xyrillian.de/gofu/internal/cli"
+	impl "git.xyrillian.de/gofu/internal/rtree"
+)
+
+type (
+	// RTree is a repository tree and its index.
+	RTree = impl.RTree
+	// Options contains the settings for New.
+	Options = impl.Options
+	// Configuration contains the settings from the rtree config file.
+	Configuration = impl.Configuration
+	// Repo is an entry in the index.
+	Repo = impl.Repo
+	// Remote is a remote that is configured in a Repo.
+	Remote = impl.Remote
+	// RemoteURL is the URL of a Remote.
+	RemoteURL = impl.RemoteURL
+	// RemoteAlias is an alias that can be used in remote URLs.
+	RemoteAlias = impl.RemoteAlias
+	// RebuildResult is returned by RTree.Rebuild.
+	RebuildResult = impl.RebuildResult
+	// NewRepoOptions contains the options for RTree.Create.
+	NewRepoOptions = impl.NewRepoOptions
+	// Command is a subprocess that is executed by a CommandRunner.
+	Command = cli.Command
+	// CommandRunner executes subprocesses. It can be given in Options to
+	// intercept all subprocesses, e.g. for logging.
+	CommandRunner = cli.CommandRunner
+)
+
+// New creates an RTree instance with the given settings.
+func New(opts Options) (*RTree, error) {
+	return impl.New(opts)
+}
+
+// DefaultOptions returns the settings that the `rtree` command uses.
+func DefaultOptions() (Options, error) {
+	return impl.DefaultOptions()
+}